@@ -0,0 +1,556 @@
+// Package accessors generates the GetPropNames, GetPropValue and
+// SetPropValue methods that let jsval access the properties of structs
+// without using reflection.
+package accessors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const jsvalImportPath = "github.com/lestrrat-go/jsval"
+
+// Generator generates accessor methods for the struct types declared
+// in a set of Go source files
+type Generator struct {
+	types []string
+}
+
+// NewGenerator creates a new Generator
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// Types specifies the names of the struct types to generate accessors
+// for. If unspecified, accessors are generated for all of the struct
+// types declared in the source files.
+func (g *Generator) Types(l ...string) *Generator {
+	g.types = append(g.types, l...)
+	return g
+}
+
+// typeDecl is a type declared in one of the source files
+type typeDecl struct {
+	name string
+	expr ast.Expr
+	file *ast.File
+}
+
+// prop is a property of a struct, and the field that holds it
+type prop struct {
+	name      string
+	field     string   // selector of the field, relative to the receiver
+	nilChecks []string // selectors of embedded struct pointers that must not be nil
+	allocs    []alloc  // embedded struct pointers to allocate before setting the field
+	maybe     bool
+	omitEmpty string // kind of the field, if it has the omitempty option
+	asString  bool
+	basic     string // name of the predeclared type of the field, if any
+	depth     int
+	tagged    bool
+}
+
+type alloc struct {
+	field    string
+	typename string
+}
+
+type genctx struct {
+	decls      map[string]*typeDecl
+	jsonImport bool
+}
+
+// Process parses the Go source files, which must belong to the same
+// package, and writes the accessor methods for their struct types
+// to out.
+func (g *Generator) Process(out io.Writer, files ...string) error {
+	if len(files) == 0 {
+		return errors.New("no source files given")
+	}
+
+	fset := token.NewFileSet()
+	ctx := genctx{decls: make(map[string]*typeDecl)}
+	var pkgname string
+	var names []string
+	for _, fn := range files {
+		f, err := parser.ParseFile(fset, fn, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		if pkgname == "" {
+			pkgname = f.Name.Name
+		} else if pkgname != f.Name.Name {
+			return errors.New("source files belong to different packages: " + pkgname + " and " + f.Name.Name)
+		}
+
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				ctx.decls[ts.Name.Name] = &typeDecl{name: ts.Name.Name, expr: ts.Type, file: f}
+				if _, ok := ts.Type.(*ast.StructType); ok {
+					names = append(names, ts.Name.Name)
+				}
+			}
+		}
+	}
+
+	if len(g.types) > 0 {
+		names = nil
+		for _, name := range g.types {
+			decl, ok := ctx.decls[name]
+			if !ok {
+				return errors.New("type " + name + " is not declared in the source files")
+			}
+			if _, ok := decl.expr.(*ast.StructType); !ok {
+				return errors.New("type " + name + " is not a struct")
+			}
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return errors.New("no struct types found in the source files")
+	}
+	sort.Strings(names)
+
+	body := bytes.Buffer{}
+	for _, name := range names {
+		props, err := ctx.structProps(ctx.decls[name])
+		if err != nil {
+			return err
+		}
+		ctx.generateAccessors(&body, name, props)
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "// Code generated by jsval accessors. DO NOT EDIT.")
+	fmt.Fprintf(&buf, "\n\npackage %s", pkgname)
+	fmt.Fprintf(&buf, "\n\nimport (")
+	if ctx.jsonImport {
+		fmt.Fprintf(&buf, "\n%s", strconv.Quote("encoding/json"))
+	}
+	fmt.Fprintf(&buf, "\n%s", strconv.Quote("errors"))
+	fmt.Fprintf(&buf, "\n\n%s", strconv.Quote(jsvalImportPath))
+	fmt.Fprintf(&buf, "\n)")
+	buf.Write(body.Bytes())
+
+	fsrc, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(fsrc)
+	return err
+}
+
+// structProps returns the properties of a struct, in the order that
+// their fields are declared. As with encoding/json, if more than one
+// field is named the same, the least nested one wins, and tagged
+// fields win over untagged ones.
+func (ctx *genctx) structProps(decl *typeDecl) ([]prop, error) {
+	candidates := map[string][]prop{}
+	var order []string
+	err := ctx.extractProps(decl, "", nil, nil, 1, map[string]struct{}{}, func(p prop) {
+		if _, ok := candidates[p.name]; !ok {
+			order = append(order, p.name)
+		}
+		candidates[p.name] = append(candidates[p.name], p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var props []prop
+	for _, name := range order {
+		if p, ok := dominantProp(candidates[name]); ok {
+			props = append(props, p)
+		}
+	}
+	return props, nil
+}
+
+func dominantProp(l []prop) (prop, bool) {
+	var best []prop
+	for _, p := range l {
+		switch {
+		case len(best) == 0 || p.depth < best[0].depth:
+			best = []prop{p}
+		case p.depth == best[0].depth:
+			best = append(best, p)
+		}
+	}
+
+	if len(best) == 1 {
+		return best[0], true
+	}
+
+	var tagged []prop
+	for _, p := range best {
+		if p.tagged {
+			tagged = append(tagged, p)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return prop{}, false
+}
+
+func (ctx *genctx) extractProps(decl *typeDecl, prefix string, nilChecks []string, allocs []alloc, depth int, visited map[string]struct{}, fn func(prop)) error {
+	if _, ok := visited[decl.name]; ok {
+		return nil
+	}
+	visited[decl.name] = struct{}{}
+	defer delete(visited, decl.name)
+
+	st := decl.expr.(*ast.StructType)
+	for _, field := range st.Fields.List {
+		var tag string
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(s).Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i > -1 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		if len(field.Names) == 0 { // embedded field
+			typ, isPtr := field.Type, false
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ, isPtr = star.X, true
+			}
+
+			fieldName, err := embeddedFieldName(typ)
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				if ident, ok := typ.(*ast.Ident); ok {
+					if edecl, ok := ctx.decls[ident.Name]; ok {
+						if _, ok := edecl.expr.(*ast.StructType); ok {
+							sel := prefix + "." + fieldName
+							nc, al := nilChecks, allocs
+							if isPtr {
+								nc = append(append([]string(nil), nilChecks...), sel)
+								al = append(append([]alloc(nil), allocs...), alloc{field: sel, typename: ident.Name})
+							}
+							if err := ctx.extractProps(edecl, sel, nc, al, depth+1, visited, fn); err != nil {
+								return err
+							}
+							continue
+						}
+					}
+				}
+
+				if ctx.isStruct(typ) {
+					return errors.New("cannot generate accessors for " + decl.name + ": embedded struct " + fieldName + " is not declared in the source files")
+				}
+			}
+
+			if !ast.IsExported(fieldName) {
+				continue
+			}
+			fn(ctx.makeProp(decl, field, fieldName, name, opts, prefix, nilChecks, allocs, depth))
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			fn(ctx.makeProp(decl, field, ident.Name, name, opts, prefix, nilChecks, allocs, depth))
+		}
+	}
+	return nil
+}
+
+func embeddedFieldName(typ ast.Expr) (string, error) {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t.Name, nil
+	case *ast.SelectorExpr:
+		return t.Sel.Name, nil
+	}
+	return "", errors.New("unsupported embedded field")
+}
+
+func (ctx *genctx) makeProp(decl *typeDecl, field *ast.Field, fieldName, name, opts, prefix string, nilChecks []string, allocs []alloc, depth int) prop {
+	p := prop{
+		name:      name,
+		field:     prefix + "." + fieldName,
+		nilChecks: nilChecks,
+		allocs:    allocs,
+		depth:     depth,
+		tagged:    name != "",
+		maybe:     isMaybe(field.Type, decl.file),
+	}
+	if p.name == "" {
+		p.name = fieldName
+	}
+	if ident, ok := field.Type.(*ast.Ident); ok && basicKind(ident.Name) != "" {
+		p.basic = ident.Name
+	}
+
+	kind := ctx.underlyingKind(field.Type, map[string]struct{}{})
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "omitempty":
+			if !p.maybe {
+				p.omitEmpty = kind
+			}
+		case "string":
+			switch kind {
+			case "bool", "string", "int", "uint", "float":
+				p.asString = true
+			}
+		}
+	}
+	return p
+}
+
+// isMaybe returns true if typ is one of the Maybe types of jsval
+func isMaybe(typ ast.Expr, file *ast.File) bool {
+	sel, ok := typ.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || x.Name != jsvalImportName(file) {
+		return false
+	}
+	return strings.HasPrefix(sel.Sel.Name, "Maybe") && sel.Sel.Name != "Maybe"
+}
+
+// jsvalImportName returns the name under which jsval is imported in
+// the file, or an empty string if it is not imported
+func jsvalImportName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || path != jsvalImportPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return "jsval"
+	}
+	return ""
+}
+
+func basicKind(name string) string {
+	switch name {
+	case "bool":
+		return "bool"
+	case "string":
+		return "string"
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	}
+	return ""
+}
+
+// underlyingKind returns the kind of the underlying type of typ, as
+// far as it can be determined from the source files. It returns an
+// empty string for structs and types declared in other packages.
+func (ctx *genctx) underlyingKind(typ ast.Expr, visited map[string]struct{}) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if k := basicKind(t.Name); k != "" {
+			return k
+		}
+		if t.Name == "error" {
+			return "nil"
+		}
+		decl, ok := ctx.decls[t.Name]
+		if !ok {
+			return ""
+		}
+		if _, ok := visited[t.Name]; ok {
+			return ""
+		}
+		visited[t.Name] = struct{}{}
+		return ctx.underlyingKind(decl.expr, visited)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "len"
+		}
+		return ""
+	case *ast.MapType:
+		return "len"
+	case *ast.StarExpr, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return "nil"
+	case *ast.ParenExpr:
+		return ctx.underlyingKind(t.X, visited)
+	}
+	return ""
+}
+
+func (ctx *genctx) isStruct(typ ast.Expr) bool {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if decl, ok := ctx.decls[t.Name]; ok {
+			_, ok := decl.expr.(*ast.StructType)
+			return ok
+		}
+		return false
+	case *ast.SelectorExpr:
+		// Types from other packages can't be inspected. Assume the worst
+		return true
+	}
+	return false
+}
+
+// emptyExpr returns the expression that reports whether the value of
+// the field is empty, as defined by encoding/json. If empty is false,
+// the expression reports whether the value is not empty instead.
+func emptyExpr(kind, field string, empty bool) string {
+	op := " == "
+	if !empty {
+		op = " != "
+	}
+	switch kind {
+	case "bool":
+		if empty {
+			return "!" + field
+		}
+		return field
+	case "string":
+		return field + op + `""`
+	case "int", "uint", "float":
+		return field + op + "0"
+	case "len":
+		return "len(" + field + ")" + op + "0"
+	case "nil":
+		return field + op + "nil"
+	}
+	return ""
+}
+
+// absentExpr returns the expression that is true if the property is
+// not present in the value
+func (p prop) absentExpr() string {
+	var l []string
+	for _, sel := range p.nilChecks {
+		l = append(l, "v"+sel+" == nil")
+	}
+	if p.maybe {
+		l = append(l, "!v"+p.field+".Valid()")
+	}
+	if x := emptyExpr(p.omitEmpty, "v"+p.field, true); x != "" {
+		l = append(l, x)
+	}
+	return strings.Join(l, " || ")
+}
+
+// presentExpr returns the expression that is true if the property is
+// present in the value
+func (p prop) presentExpr() string {
+	var l []string
+	for _, sel := range p.nilChecks {
+		l = append(l, "v"+sel+" != nil")
+	}
+	if p.maybe {
+		l = append(l, "v"+p.field+".Valid()")
+	}
+	if x := emptyExpr(p.omitEmpty, "v"+p.field, false); x != "" {
+		l = append(l, x)
+	}
+	return strings.Join(l, " && ")
+}
+
+func (ctx *genctx) generateAccessors(out io.Writer, name string, props []prop) {
+	fmt.Fprintf(out, "\n\n// GetPropNames returns the names of the properties that are present in %s", name)
+	fmt.Fprintf(out, "\nfunc (v %s) GetPropNames() ([]string, error) {", name)
+	fmt.Fprintf(out, "\nl := make([]string, 0, %d)", len(props))
+	for _, p := range props {
+		if cond := p.presentExpr(); cond != "" {
+			fmt.Fprintf(out, "\nif %s {", cond)
+			fmt.Fprintf(out, "\nl = append(l, %s)", strconv.Quote(p.name))
+			fmt.Fprintf(out, "\n}")
+		} else {
+			fmt.Fprintf(out, "\nl = append(l, %s)", strconv.Quote(p.name))
+		}
+	}
+	fmt.Fprintf(out, "\nreturn l, nil")
+	fmt.Fprintf(out, "\n}")
+
+	fmt.Fprintf(out, "\n\n// GetPropValue returns the value of the property pname of %s,", name)
+	fmt.Fprintf(out, "\n// or nil if it is not present")
+	fmt.Fprintf(out, "\nfunc (v %s) GetPropValue(pname string) (interface{}, error) {", name)
+	if len(props) > 0 {
+		fmt.Fprintf(out, "\nswitch pname {")
+		for _, p := range props {
+			fmt.Fprintf(out, "\ncase %s:", strconv.Quote(p.name))
+			if cond := p.absentExpr(); cond != "" {
+				fmt.Fprintf(out, "\nif %s {", cond)
+				fmt.Fprintf(out, "\nreturn nil, nil")
+				fmt.Fprintf(out, "\n}")
+			}
+			switch {
+			case p.maybe:
+				fmt.Fprintf(out, "\nreturn v%s.Value(), nil", p.field)
+			case p.asString:
+				ctx.jsonImport = true
+				fmt.Fprintf(out, "\nbuf, err := json.Marshal(v%s)", p.field)
+				fmt.Fprintf(out, "\nif err != nil {")
+				fmt.Fprintf(out, "\nreturn nil, err")
+				fmt.Fprintf(out, "\n}")
+				fmt.Fprintf(out, "\nreturn string(buf), nil")
+			default:
+				fmt.Fprintf(out, "\nreturn v%s, nil", p.field)
+			}
+		}
+		fmt.Fprintf(out, "\n}")
+	}
+	fmt.Fprintf(out, "\nreturn nil, nil")
+	fmt.Fprintf(out, "\n}")
+
+	fmt.Fprintf(out, "\n\n// SetPropValue sets the value of the property pname of %s", name)
+	fmt.Fprintf(out, "\nfunc (v *%s) SetPropValue(pname string, x interface{}) error {", name)
+	if len(props) > 0 {
+		fmt.Fprintf(out, "\nswitch pname {")
+		for _, p := range props {
+			fmt.Fprintf(out, "\ncase %s:", strconv.Quote(p.name))
+			for _, a := range p.allocs {
+				fmt.Fprintf(out, "\nif v%s == nil {", a.field)
+				fmt.Fprintf(out, "\nv%s = &%s{}", a.field, a.typename)
+				fmt.Fprintf(out, "\n}")
+			}
+			if p.basic != "" && !p.asString {
+				fmt.Fprintf(out, "\nif x, ok := x.(%s); ok {", p.basic)
+				fmt.Fprintf(out, "\nv%s = x", p.field)
+				fmt.Fprintf(out, "\nreturn nil")
+				fmt.Fprintf(out, "\n}")
+			}
+			fmt.Fprintf(out, "\nreturn jsval.AssignValue(&v%s, x)", p.field)
+		}
+		fmt.Fprintf(out, "\n}")
+	}
+	fmt.Fprintf(out, "\nreturn errors.New(%s + pname + %s)", strconv.Quote("unknown property '"), strconv.Quote("'"))
+	fmt.Fprintf(out, "\n}")
+}
@@ -0,0 +1,87 @@
+package accessors_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsval/accessors"
+	"github.com/stretchr/testify/assert"
+)
+
+const src = `package foo
+
+import js "github.com/lestrrat-go/jsval"
+
+type Base struct {
+	ID string ` + "`json:\"id\"`" + `
+}
+
+type Foo struct {
+	Base
+	Name  js.MaybeString ` + "`json:\"name\"`" + `
+	ID    int            ` + "`json:\"id\"`" + `
+	Flags []string       ` + "`json:\",omitempty\"`" + `
+}
+
+type Bar struct {
+	bytes.Buffer
+}
+
+type Baz string
+`
+
+func TestGenerator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsval-accessors")
+	if !assert.NoError(t, err, "TempDir should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "foo.go")
+	if !assert.NoError(t, ioutil.WriteFile(fn, []byte(src), 0644), "WriteFile should succeed") {
+		return
+	}
+
+	t.Run("Foo", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, accessors.NewGenerator().Types("Foo").Process(&buf, fn), "Process should succeed") {
+			return
+		}
+
+		code := buf.String()
+		for _, s := range []string{
+			"package foo\n",
+			"func (v Foo) GetPropNames() ([]string, error) {",
+			"func (v Foo) GetPropValue(pname string) (interface{}, error) {",
+			"func (v *Foo) SetPropValue(pname string, x interface{}) error {",
+			"if v.Name.Valid() {",
+			"if len(v.Flags) != 0 {",
+			"return v.ID, nil",
+			"return jsval.AssignValue(&v.Name, x)",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+
+		if !assert.False(t, strings.Contains(code, "v.Base.ID"), "shadowed field is not used") {
+			return
+		}
+		if !assert.False(t, strings.Contains(code, "func (v Base)"), "other types are skipped") {
+			return
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, typ := range []string{"Bar", "Baz", "Qux"} {
+			var buf bytes.Buffer
+			if !assert.Error(t, accessors.NewGenerator().Types(typ).Process(&buf, fn), "Process should fail for %s", typ) {
+				return
+			}
+		}
+	})
+}
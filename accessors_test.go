@@ -0,0 +1,89 @@
+package jsval_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+// reflectTestRecord has the same fields as accessorTestRecord, but
+// none of its generated accessor methods
+type reflectTestRecord accessorTestRecord
+
+func errorStrings(errs []error) []string {
+	l := make([]string, len(errs))
+	for i, err := range errs {
+		l[i] = err.Error()
+	}
+	sort.Strings(l)
+	return l
+}
+
+func TestGeneratedAccessors(t *testing.T) {
+	var _ jsval.GetPropNameser = accessorTestRecord{}
+	var _ jsval.GetPropValuer = accessorTestRecord{}
+	var _ jsval.SetPropValuer = &accessorTestRecord{}
+
+	c := jsval.Object().
+		AddProp(`id`, jsval.String().MinLength(1)).
+		AddProp(`name`, jsval.String().Default(`anonymous`)).
+		AddProp(`count`, jsval.Integer().Minimum(0)).
+		AddProp(`owner`, jsval.String().MinLength(1)).
+		AddProp(`version`, jsval.String().RegexpString(`^[0-9]+$`)).
+		AddProp(`tags`, jsval.Array().Items(jsval.String()).MinItems(1)).
+		AddProp(`Score`, jsval.Number().Maximum(1)).
+		AdditionalProperties(nil).
+		Required(`id`, `count`)
+	v := jsval.New().SetRoot(c)
+
+	records := []accessorTestRecord{
+		{ID: "foo", Count: jsval.MaybeInt{ValidFlag: true, Int: 1}},
+		{ID: "", Count: jsval.MaybeInt{ValidFlag: true, Int: -1}, Score: 2},
+		{ID: "foo", Name: "bar"},
+		{accessorTestOwner: &accessorTestOwner{Version: 3}, ID: "foo", Count: jsval.MaybeInt{ValidFlag: true}},
+		{accessorTestOwner: &accessorTestOwner{Owner: "baz"}, ID: "foo", Count: jsval.MaybeInt{ValidFlag: true}, Tags: []string{"a"}},
+	}
+
+	for _, rec := range records {
+		names, err := rec.GetPropNames()
+		if !assert.NoError(t, err, "GetPropNames should succeed") {
+			return
+		}
+		rrec := reflectTestRecord(rec)
+		expected := jsval.DefaultFieldNamesFromStruct(reflect.ValueOf(rrec))
+		sort.Strings(names)
+		sort.Strings(expected)
+		if !assert.Equal(t, expected, names, "property names match for %#v", rec) {
+			return
+		}
+
+		errs := errorStrings(v.ValidateAll(&rec))
+		if !assert.Equal(t, errorStrings(v.ValidateAll(&rrec)), errs, "errors match for %#v", rec) {
+			return
+		}
+
+		if !assert.Equal(t, rrec.Name, rec.Name, "defaults match") {
+			return
+		}
+	}
+
+	rec := accessorTestRecord{}
+	if !assert.NoError(t, rec.SetPropValue("owner", "foo"), "SetPropValue should succeed") {
+		return
+	}
+	if !assert.Equal(t, "foo", rec.Owner, "embedded struct is allocated") {
+		return
+	}
+	if !assert.NoError(t, rec.SetPropValue("count", float64(10)), "SetPropValue should succeed") {
+		return
+	}
+	if !assert.Equal(t, jsval.MaybeInt{ValidFlag: true, Int: 10}, rec.Count, "Maybe value is set") {
+		return
+	}
+	if !assert.Error(t, rec.SetPropValue("notes", "foo"), "unknown properties can't be set") {
+		return
+	}
+}
@@ -0,0 +1,22 @@
+package jsval_test
+
+import "github.com/lestrrat-go/jsval"
+
+// The accessor methods of these types are generated into
+// generated_accessors_test.go by `jsval accessors`
+
+type accessorTestOwner struct {
+	Owner   string `json:"owner"`
+	Version int    `json:"version,string,omitempty"`
+}
+
+type accessorTestRecord struct {
+	*accessorTestOwner
+	ID    string         `json:"id"`
+	Name  string         `json:"name,omitempty"`
+	Count jsval.MaybeInt `json:"count"`
+	Tags  []string       `json:"tags,omitempty"`
+	Score float64
+	Notes string `json:"-"`
+	notes string
+}
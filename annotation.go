@@ -0,0 +1,179 @@
+package jsval
+
+import (
+	"reflect"
+)
+
+// annotations records the object properties and array items that were
+// successfully evaluated by a constraint. They are used to find the
+// properties and items that `unevaluatedProperties` and `unevaluatedItems`
+// should be applied to. Subschemas are validated with l, the list of
+// the constraint that needs the annotations.
+type annotations struct {
+	l     *errorList
+	props map[string]struct{}
+	items map[int]struct{}
+}
+
+func newAnnotations(l *errorList) *annotations {
+	return &annotations{
+		l:     l,
+		props: make(map[string]struct{}),
+		items: make(map[int]struct{}),
+	}
+}
+
+// collect records the annotations produced by validating v against c.
+// Only constraints that apply to the same value (in-place applicators
+// such as allOf, anyOf, oneOf, if/then/else and $ref) are followed.
+// Subschemas that fail to validate do not contribute any annotations.
+func (a *annotations) collect(c Constraint, v interface{}) {
+	switch c.(type) {
+	case *ObjectConstraint:
+		c.(*ObjectConstraint).annotate(v, a)
+	case *ArrayConstraint:
+		c.(*ArrayConstraint).annotate(v, a)
+	case *AllConstraint:
+		for _, c1 := range c.(*AllConstraint).constraints {
+			a.collect(c1, v)
+		}
+	case *AnyConstraint:
+		for _, c1 := range c.(*AnyConstraint).constraints {
+			if a.l.try(c1, v) == nil {
+				a.collect(c1, v)
+			}
+		}
+	case *OneOfConstraint:
+		for _, c1 := range c.(*OneOfConstraint).constraints {
+			if a.l.try(c1, v) == nil {
+				a.collect(c1, v)
+			}
+		}
+	case *IfThenElseConstraint:
+		ic := c.(*IfThenElseConstraint)
+		if ic.cond == nil {
+			return
+		}
+		if a.l.try(ic.cond, v) == nil {
+			a.collect(ic.cond, v)
+			if ic.then != nil {
+				a.collect(ic.then, v)
+			}
+		} else if ic.els != nil {
+			a.collect(ic.els, v)
+		}
+	case *ReferenceConstraint:
+		if rc, err := c.(*ReferenceConstraint).Resolved(); err == nil {
+			a.collect(rc, v)
+		}
+	}
+}
+
+// isEmptyConstraint returns true if c is EmptyConstraint. As this is
+// what the builder uses when additionalProperties or additionalItems is
+// absent from the schema, it is not considered to evaluate anything.
+func isEmptyConstraint(c Constraint) bool {
+	_, ok := c.(emptyConstraint)
+	return ok
+}
+
+// annotate records the properties of v that this constraint evaluates
+func (o *ObjectConstraint) annotate(v interface{}, a *annotations) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonObject {
+		return
+	}
+
+	fields, err := o.getPropNames(rv)
+	if err != nil {
+		return
+	}
+
+	if o.unevaluatedProperties != nil {
+		for _, pname := range fields {
+			a.props[pname] = struct{}{}
+		}
+		return
+	}
+	o.annotateEvaluated(rv, fields, a)
+}
+
+// annotateEvaluated records the properties evaluated by everything but
+// the unevaluatedProperties constraint itself
+func (o *ObjectConstraint) annotateEvaluated(rv reflect.Value, fields []string, a *annotations) {
+	v := rv.Interface()
+	for _, pname := range fields {
+		if o.getPropConstraint(pname) != nil {
+			a.props[pname] = struct{}{}
+			continue
+		}
+		if o.matchesPatternProperty(pname) {
+			a.props[pname] = struct{}{}
+			continue
+		}
+		if c := o.additionalProperties; c != nil && !isEmptyConstraint(c) {
+			a.props[pname] = struct{}{}
+		}
+	}
+
+	for _, pname := range fields {
+		if depc := o.GetSchemaDependency(pname); depc != nil {
+			a.collect(depc, v)
+		}
+	}
+
+	for _, c := range o.siblings {
+		a.collect(c, v)
+	}
+}
+
+// annotate records the items of v that this constraint evaluates
+func (c *ArrayConstraint) annotate(v interface{}, a *annotations) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonArray {
+		return
+	}
+
+	if c.unevaluatedItems != nil {
+		for i := 0; i < rv.Len(); i++ {
+			a.items[i] = struct{}{}
+		}
+		return
+	}
+	c.annotateEvaluated(rv, a)
+}
+
+// annotateEvaluated records the items evaluated by everything but
+// the unevaluatedItems constraint itself
+func (c *ArrayConstraint) annotateEvaluated(rv reflect.Value, a *annotations) {
+	n := rv.Len()
+	switch {
+	case c.items != nil:
+		for i := 0; i < n; i++ {
+			a.items[i] = struct{}{}
+		}
+	case len(c.positionalItems) > 0:
+		lp := len(c.positionalItems)
+		for i := 0; i < n && i < lp; i++ {
+			a.items[i] = struct{}{}
+		}
+		if cadd := c.additionalItems; cadd != nil && !isEmptyConstraint(cadd) {
+			for i := lp; i < n; i++ {
+				a.items[i] = struct{}{}
+			}
+		}
+	}
+
+	if cc := c.contains; cc != nil {
+		for i := 0; i < n; i++ {
+			if a.l.try(cc, rv.Index(i).Interface()) == nil {
+				a.items[i] = struct{}{}
+			}
+		}
+	}
+
+	v := rv.Interface()
+	for _, sc := range c.siblings {
+		a.collect(sc, v)
+	}
+}
@@ -1,9 +1,8 @@
 package jsval
 
 import (
-	"errors"
-	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/lestrrat-go/pdebug"
 )
@@ -30,41 +29,72 @@ func (c *ArrayConstraint) Validate(v interface{}) (err error) {
 		}()
 	}
 
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Slice:
-	default:
-		var typ string
-		if rv == zeroval {
-			typ = "invalid"
-		} else {
-			typ = rv.Type().String()
-		}
-		return errors.New("value must be a slice (was: " + typ + ")")
+	l := errorList{}
+	c.validate(v, &l)
+	return l.err()
+}
+
+func (c *ArrayConstraint) validate(v interface{}, l *errorList) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonArray {
+		l.add(newValidationError("type", "array", v, "value is not an array (was: "+kind.String()+")"))
+		return
 	}
 
-	l := rv.Len()
+	n := rv.Len()
 
-	if mi := c.minItems; mi > -1 && l < mi {
-		return errors.New("fewer items than minItems")
+	if mi := c.minItems; mi > -1 && n < mi {
+		if l.add(newValidationError("minItems", mi, n, "fewer items than minItems")) {
+			return
+		}
 	}
 
-	if mi := c.maxItems; mi > -1 && l > mi {
-		return errors.New("more items than maxItems")
+	if mi := c.maxItems; mi > -1 && n > mi {
+		if l.add(newValidationError("maxItems", mi, n, "more items than maxItems")) {
+			return
+		}
 	}
 
-	var uitems map[string]struct{}
 	if c.uniqueItems {
 		pdebug.Printf("Check for unique items enabled")
-		uitems = make(map[string]struct{})
-		for i := 0; i < l; i++ {
+		// Items are bucketed by their hashes, so that only the items
+		// that are likely to be equal need to be compared
+		buckets := make(map[uint64][]interface{})
+		for i := 0; i < n; i++ {
 			iv := rv.Index(i).Interface()
-			kv := fmt.Sprintf("%s", iv)
-pdebug.Printf("unique? -> %s", kv)
-			if _, ok := uitems[kv]; ok {
-				return errors.New("duplicate element found")
+			h := hashJSON(iv)
+			duplicate := false
+			for _, seen := range buckets[h] {
+				if equalJSON(seen, iv) {
+					duplicate = true
+					break
+				}
+			}
+			if duplicate {
+				if l.add(withPointer(newValidationError("uniqueItems", true, iv, "duplicate element found"), strconv.Itoa(i))) {
+					return
+				}
+				continue
+			}
+			buckets[h] = append(buckets[h], iv)
+		}
+	}
+
+	if cc := c.contains; cc != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Checking if at least one item matches a spec")
+		}
+		found := false
+		for i := 0; i < n; i++ {
+			if l.try(cc, rv.Index(i).Interface()) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if l.add(newValidationError("contains", nil, v, "no element matches the contains constraint")) {
+				return
 			}
-			uitems[kv] = struct{}{}
 		}
 	}
 
@@ -74,44 +104,59 @@ pdebug.Printf("unique? -> %s", kv)
 		}
 		// if this is set, then all items must fulfill this.
 		// additional items are ignored
-		for i := 0; i < l; i++ {
+		for i := 0; i < n; i++ {
 			iv := rv.Index(i).Interface()
-			if err := celem.Validate(iv); err != nil {
-				return err
+			if l.checkAt(celem, iv, strconv.Itoa(i)) {
+				return
 			}
 		}
 	} else {
 		// otherwise, check the positional specs, and apply the
 		// additionalItems constraint
-		lv := rv.Len()
 		for i, cpos := range c.positionalItems {
-			if lv <= i {
+			if n <= i {
 				break
 			}
 			if pdebug.Enabled {
 				pdebug.Printf("Checking positional item at '%d'", i)
 			}
 			iv := rv.Index(i).Interface()
-			if err := cpos.Validate(iv); err != nil {
-				return err
+			if l.checkAt(cpos, iv, strconv.Itoa(i)) {
+				return
 			}
 		}
 
 		lp := len(c.positionalItems)
-		if lp > 0 && l > lp { // we got more than positional schemas
+		if lp > 0 && n > lp { // we got more than positional schemas
 			cadd := c.additionalItems
 			if cadd == nil { // you can't have additionalItems!
-				return errors.New("additional elements found in array")
+				l.add(withPointer(newValidationError("additionalItems", false, rv.Index(lp).Interface(), "additional elements found in array"), strconv.Itoa(lp)))
+				return
 			}
-			for i := lp - 1; i < l; i++ {
+			for i := lp; i < n; i++ {
 				iv := rv.Index(i).Interface()
-				if err := cadd.Validate(iv); err != nil {
-					return err
+				if l.checkAt(cadd, iv, strconv.Itoa(i)) {
+					return
 				}
 			}
 		}
 	}
-	return nil
+
+	if cu := c.unevaluatedItems; cu != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Checking unevaluated items")
+		}
+		a := newAnnotations(l)
+		c.annotateEvaluated(rv, a)
+		for i := 0; i < n; i++ {
+			if _, ok := a.items[i]; ok {
+				continue
+			}
+			if l.checkAt(cu, rv.Index(i).Interface(), strconv.Itoa(i)) {
+				return
+			}
+		}
+	}
 }
 
 // AdditionalItems specifies the constraint that additional items
@@ -134,6 +179,34 @@ func (c *ArrayConstraint) Items(ac Constraint) *ArrayConstraint {
 	return c
 }
 
+// Contains specifies the constraint that at least one of the items
+// in the array must be validated against.
+func (c *ArrayConstraint) Contains(ac Constraint) *ArrayConstraint {
+	c.contains = ac
+	return c
+}
+
+// UnevaluatedItems specifies the constraint that items not evaluated
+// by any other part of the schema must be validated against. An item
+// counts as evaluated if it is covered by `Items`, `PositionalItems`,
+// `AdditionalItems` (unless it is `EmptyConstraint`), matches `Contains`,
+// or is evaluated by any of the constraints given to `Siblings` that
+// the value successfully validates against.
+func (c *ArrayConstraint) UnevaluatedItems(ac Constraint) *ArrayConstraint {
+	c.unevaluatedItems = ac
+	return c
+}
+
+// Siblings specifies the constraints that are applied to the same value
+// alongside this constraint, such as those created from allOf, anyOf,
+// oneOf, if/then/else and $ref in the same schema. They are not
+// validated by this constraint, but the items that they evaluate are
+// excluded from `UnevaluatedItems`.
+func (c *ArrayConstraint) Siblings(l ...Constraint) *ArrayConstraint {
+	c.siblings = append(c.siblings, l...)
+	return c
+}
+
 // MinItems specifies the minimum number of items in the value.
 // If unspecified, the check is not performed.
 func (c *ArrayConstraint) MinItems(i int) *ArrayConstraint {
@@ -160,13 +233,10 @@ func (c *ArrayConstraint) PositionalItems(ac []Constraint) *ArrayConstraint {
 // UniqueItems specifies if the array can hold non-unique items.
 // When set to true, the validation will fail unless all of your
 // elements are unique.
-//
-// Caveat: Currently we stringify the elements before comparing
-// for uniqueness. This could/should be fixed.
 func (c *ArrayConstraint) UniqueItems(b bool) *ArrayConstraint {
 	if pdebug.Enabled {
 		pdebug.Printf("Setting uniqueItems = %t", b)
 	}
 	c.uniqueItems = b
 	return c
-}
\ No newline at end of file
+}
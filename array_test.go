@@ -2,6 +2,7 @@ package jsval_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -56,4 +57,41 @@ func TestArrayItemsReference(t *testing.T) {
 	if !assert.True(t, strings.Contains(code, "\tPositionalItems("), "Generated code should contain `.PositionalItems()`") {
 		return
 	}
-}
\ No newline at end of file
+}
+
+func TestArrayUniqueItems(t *testing.T) {
+	c := jsval.Array().UniqueItems(true)
+
+	valid := [][]interface{}{
+		{1, "1"},
+		{1, true},
+		{0, false, nil},
+		{"foo", "bar"},
+		{[]interface{}{1}, []interface{}{"1"}},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1, "b": 2}},
+	}
+	for _, v := range valid {
+		if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+			return
+		}
+	}
+
+	invalid := [][]interface{}{
+		{1, 1.0},
+		{int64(1), uint8(1)},
+		{float64(0.1), json.Number("0.1")},
+		{json.Number("100"), json.Number("1e2")},
+		{"foo", "bar", "foo"},
+		{[]interface{}{1, "a"}, []interface{}{1.0, "a"}},
+		{
+			map[string]interface{}{"a": 1, "b": []interface{}{true}},
+			map[string]interface{}{"b": []interface{}{true}, "a": 1.0},
+		},
+	}
+	for _, v := range invalid {
+		if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+			return
+		}
+	}
+}
@@ -15,6 +15,25 @@ func (dv defaultValue) DefaultValue() interface{} {
 	return dv.value
 }
 
+// Validate calls f(v), with no references followed yet
+func (f ConstraintFunc) Validate(v interface{}) error {
+	return f(v, ReferenceDepth{})
+}
+
+func (f ConstraintFunc) validate(v interface{}, l *errorList) {
+	l.add(f(v, ReferenceDepth{depth: l.depth, max: l.maxDepth}))
+}
+
+// HasDefault is a no op for this constraint
+func (f ConstraintFunc) HasDefault() bool {
+	return false
+}
+
+// DefaultValue is a no op for this constraint
+func (f ConstraintFunc) DefaultValue() interface{} {
+	return nil
+}
+
 func (nc emptyConstraint) Validate(_ interface{}) error {
 	return nil
 }
@@ -41,18 +60,10 @@ func (nc nullConstraint) Validate(v interface{}) (err error) {
 		defer g.End()
 	}
 
-	rv := reflect.ValueOf(v)
-	if rv == zeroval {
+	if _, kind := jsonValueOf(reflect.ValueOf(v)); kind == jsonNull {
 		return nil
 	}
-
-	switch rv.Kind() {
-	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
-		if rv.IsNil() {
-			return nil
-		}
-	}
-	return errors.New("value is not null")
+	return newValidationError("type", "null", v, "value is not null")
 }
 
 // Not creates a new NotConstraint. You must pass in the
@@ -79,12 +90,18 @@ func (nc NotConstraint) Validate(v interface{}) (err error) {
 		defer g.End()
 	}
 
+	l := errorList{}
+	nc.validate(v, &l)
+	return l.err()
+}
+
+func (nc NotConstraint) validate(v interface{}, l *errorList) {
 	if nc.child == nil {
-		return errors.New("'not' constraint does not have a child constraint")
+		l.add(errors.New("'not' constraint does not have a child constraint"))
+		return
 	}
 
-	if err := nc.child.Validate(v); err == nil {
-		return errors.New("'not' validation failed")
+	if l.try(nc.child, v) == nil {
+		l.add(newValidationError("not", nil, v, "'not' validation failed"))
 	}
-	return nil
 }
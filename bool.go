@@ -1,9 +1,6 @@
 package jsval
 
-import (
-	"errors"
-	"reflect"
-)
+import "reflect"
 
 // Boolean creates a new BooleanConsraint
 func Boolean() *BooleanConstraint {
@@ -19,11 +16,8 @@ func (bc *BooleanConstraint) Default(v interface{}) *BooleanConstraint {
 
 // Validate vaidates the value against the given value
 func (bc *BooleanConstraint) Validate(v interface{}) error {
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Bool:
-	default:
-		return errors.New("value is not a boolean")
+	if _, kind := jsonValueOf(reflect.ValueOf(v)); kind != jsonBoolean {
+		return newValidationError("type", "boolean", v, "value is not a boolean")
 	}
 	return nil
 }
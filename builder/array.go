@@ -1,6 +1,8 @@
 package builder
 
 import (
+	"errors"
+
 	"github.com/lestrrat-go/jsschema"
 	"github.com/lestrrat-go/jsval"
 	"github.com/lestrrat-go/pdebug"
@@ -18,7 +20,11 @@ func buildArrayConstraint(ctx *buildctx, c *jsval.ArrayConstraint, s *schema.Sch
 		}()
 	}
 
-	if items := s.Items; items != nil {
+	if ctx.D >= Draft202012 {
+		if err := buildPrefixItems(ctx, c, s); err != nil {
+			return err
+		}
+	} else if items := s.Items; items != nil {
 		if !items.TupleMode {
 			specs, err := buildFromSchema(ctx, items.Schemas[0])
 			if err != nil {
@@ -61,6 +67,28 @@ func buildArrayConstraint(ctx *buildctx, c *jsval.ArrayConstraint, s *schema.Sch
 		}
 	}
 
+	if cs, ok, err := extraSchema(s, "contains"); err != nil {
+		return err
+	} else if ok {
+		cc, err := buildFromSchema(ctx, cs)
+		if err != nil {
+			return err
+		}
+		c.Contains(cc)
+	}
+
+	if ctx.D >= Draft201909 {
+		if us, ok, err := extraSchema(s, "unevaluatedItems"); err != nil {
+			return err
+		} else if ok {
+			uc, err := buildFromSchema(ctx, us)
+			if err != nil {
+				return err
+			}
+			c.UnevaluatedItems(uc)
+		}
+	}
+
 	if s.MinItems.Initialized {
 		c.MinItems(s.MinItems.Val)
 	}
@@ -74,4 +102,51 @@ func buildArrayConstraint(ctx *buildctx, c *jsval.ArrayConstraint, s *schema.Sch
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// buildPrefixItems handles "prefixItems" and "items" as defined in
+// draft 2020-12, where "items" applies to the elements after those
+// covered by "prefixItems", and "additionalItems" no longer exists.
+func buildPrefixItems(ctx *buildctx, c *jsval.ArrayConstraint, s *schema.Schema) error {
+	var rest jsval.Constraint
+	if items := s.Items; items != nil {
+		if items.TupleMode {
+			return errors.New("invalid value for 'items': expected a schema")
+		}
+		ic, err := buildFromSchema(ctx, items.Schemas[0])
+		if err != nil {
+			return err
+		}
+		rest = ic
+	}
+
+	prefix, ok, err := extraSchemaList(s, "prefixItems")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if rest != nil {
+			c.Items(rest)
+		}
+		return nil
+	}
+
+	specs := make([]jsval.Constraint, len(prefix))
+	for i, espec := range prefix {
+		item, err := buildFromSchema(ctx, espec)
+		if err != nil {
+			return err
+		}
+		specs[i] = item
+	}
+	c.PositionalItems(specs)
+
+	if rest == nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Items after prefixItems will be allowed freely")
+		}
+		rest = jsval.EmptyConstraint
+	}
+	c.AdditionalItems(rest)
+	return nil
+}
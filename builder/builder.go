@@ -7,22 +7,45 @@ package builder
 import (
 	"errors"
 	"math"
+	"net/url"
 	"reflect"
 	"sort"
+	"strings"
 
-	"github.com/lestrrat-go/jsref"
 	"github.com/lestrrat-go/jsschema"
 	"github.com/lestrrat-go/jsval"
 	"github.com/lestrrat-go/pdebug"
 )
 
 // Builder builds Validator objects from JSON schemas
-type Builder struct{}
+type Builder struct {
+	baseURI string
+	cache   docCache
+	draft   Draft
+	formats *jsval.FormatRegistry
+	loader  Loader
+	structs *jsval.StructInfoRegistry
+}
 
 type buildctx struct {
 	V *jsval.JSVal
 	S *schema.Schema
 	R map[string]struct{}
+	P map[string]jsval.Constraint // constraints built for the references in R
+	D Draft
+	F *jsval.FormatRegistry
+	T *jsval.StructInfoRegistry
+	L func(string) (interface{}, error) // loads documents by URI
+	U map[string]string                 // reference keys to the URIs of their documents
+	J interface{}                       // the root document, which references are resolved in
+	X *schemaIndex                      // the identifiers in J and the loaded documents
+	// Base is the URI that reference keys are relative to, if any, and
+	// Doc is the URI of the root document if the keys are absolute.
+	// Scope is the URI that references in the schema being built are
+	// resolved against
+	Base  *url.URL
+	Doc   string
+	Scope *url.URL
 }
 
 // New creates a new builder object
@@ -30,6 +53,122 @@ func New() *Builder {
 	return &Builder{}
 }
 
+// SetDraft specifies the draft of the JSON Schema specification that
+// the schemas should be interpreted as. The default is DraftAuto,
+// which picks the draft from the "$schema" keyword of each schema.
+func (b *Builder) SetDraft(d Draft) *Builder {
+	b.draft = d
+	return b
+}
+
+// SetFormatRegistry specifies the FormatRegistry that the string
+// constraints in the validators built by this builder look up formats
+// in. If unspecified, jsval.DefaultFormatRegistry is used. If the
+// registry is in strict mode, building a schema that uses an unknown
+// format fails.
+func (b *Builder) SetFormatRegistry(r *jsval.FormatRegistry) *Builder {
+	b.formats = r
+	return b
+}
+
+// SetStructInfoRegistry specifies the StructInfoRegistry that the
+// object constraints in the validators built by this builder use to
+// map property names to struct fields. If unspecified,
+// jsval.DefaultStructInfoRegistry is used.
+func (b *Builder) SetStructInfoRegistry(r *jsval.StructInfoRegistry) *Builder {
+	b.structs = r
+	return b
+}
+
+// SetLoader specifies the Loader that documents referred to by
+// JSON references such as `{"$ref": "common.json#/definitions/address"}`
+// are loaded with. Loaded documents are cached by the builder. If
+// unspecified, only references within the schema can be resolved.
+func (b *Builder) SetLoader(l Loader) *Builder {
+	b.loader = l
+	return b
+}
+
+// SetBaseURI specifies the URI of the schema document, which relative
+// references to other documents are resolved against. Use `FileURI`
+// for files in the local filesystem.
+func (b *Builder) SetBaseURI(u string) *Builder {
+	b.baseURI = u
+	return b
+}
+
+// BuildURI loads the document at the URI u using the Loader, and
+// creates a new validator from the schema in it. If u has a fragment,
+// the schema at that JSON pointer in the document is used. References
+// are resolved relative to u.
+func (b *Builder) BuildURI(u string) (*jsval.JSVal, error) {
+	return b.buildURI(u, buildopts{})
+}
+
+// BuildShared creates a new validator from the schema at the URI u,
+// like BuildURI, for use by a `jsval.Registry`. Documents are looked
+// up with lookup first, and loaded with the Loader if they are not
+// found. The constraints of referenced schemas are stored in cm under
+// their absolute URIs, and those that are already there are reused,
+// so that validators built with the same cm share them.
+func (b *Builder) BuildShared(u string, lookup func(string) (interface{}, bool), cm *jsval.ConstraintMap) (*jsval.JSVal, error) {
+	return b.buildURI(u, buildopts{lookup: lookup, shared: cm})
+}
+
+func (b *Builder) buildURI(u string, opts buildopts) (*jsval.JSVal, error) {
+	doc, frag := u, ""
+	if i := strings.IndexByte(u, '#'); i > -1 {
+		doc, frag = u[:i], u[i+1:]
+	}
+
+	x, err := b.load(doc, opts.lookup)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("document " + doc + " is not a JSON object")
+	}
+	m = NormalizeSchema(m)
+
+	var sm interface{} = m
+	if frag != "" {
+		// The fragment is URI encoded, while the JSON pointer that it
+		// contains is not
+		if frag, err = url.PathUnescape(frag); err != nil {
+			return nil, errors.New("invalid fragment in " + u + ": " + err.Error())
+		}
+		if sm, err = lookupPointer(m, frag); err != nil {
+			return nil, err
+		}
+	}
+	sm = normalizeSubschema(sm)
+	mm, ok := sm.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("schema at " + u + " is not a JSON object")
+	}
+
+	s := schema.New()
+	if err := s.Extract(mm); err != nil {
+		return nil, err
+	}
+
+	opts.base = doc
+	opts.ptr = frag
+	return b.build(s, m, opts)
+}
+
+// load loads the document at the URI uri, looking it up with lookup
+// first, if given
+func (b *Builder) load(uri string, lookup func(string) (interface{}, bool)) (interface{}, error) {
+	if lookup != nil {
+		if doc, ok := lookup(uri); ok {
+			return doc, nil
+		}
+	}
+	return b.cache.load(b.loader, uri)
+}
+
 // Build creates a new validator from the specified schema
 func (b *Builder) Build(s *schema.Schema) (v *jsval.JSVal, err error) {
 	if pdebug.Enabled {
@@ -70,11 +209,94 @@ func (b *Builder) BuildWithCtx(s *schema.Schema, jsctx interface{}) (v *jsval.JS
 		return nil, errors.New("nil schema")
 	}
 
-	v = jsval.New()
+	return b.build(s, jsctx, buildopts{base: b.baseURI})
+}
+
+// buildopts holds the parameters of build that vary between the
+// methods of Builder
+type buildopts struct {
+	base   string                           // URI of the document that the schema is in
+	ptr    string                           // JSON pointer to the schema in the document
+	lookup func(string) (interface{}, bool) // see BuildShared
+	shared *jsval.ConstraintMap             // see BuildShared
+}
+
+func (b *Builder) build(s *schema.Schema, jsctx interface{}, opts buildopts) (*jsval.JSVal, error) {
+	draft := b.draft
+	if draft == DraftAuto {
+		draft = DetectDraft(s.SchemaRef)
+		if draft == DraftAuto {
+			draft = Draft07
+		}
+	}
+	if pdebug.Enabled {
+		pdebug.Printf("Building schema as %s", draft)
+	}
+
+	v := jsval.New()
+	if opts.shared != nil {
+		v.ConstraintMap = opts.shared
+	}
 	ctx := buildctx{
 		V: v,
 		S: s,
 		R: map[string]struct{}{}, // names of references used
+		P: map[string]jsval.Constraint{},
+		D: draft,
+		F: b.formats,
+		T: b.structs,
+		U: map[string]string{},
+	}
+	ctx.L = func(uri string) (interface{}, error) {
+		doc, err := b.load(uri, opts.lookup)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := doc.(map[string]interface{}); ok {
+			doc = NormalizeSchema(m)
+		}
+		return doc, nil
+	}
+	ctx.Scope = &url.URL{}
+	if opts.base != "" {
+		u, err := url.Parse(opts.base)
+		if err != nil {
+			return nil, errors.New("invalid base URI: " + err.Error())
+		}
+		u.Fragment = ""
+		ctx.Scope = u
+
+		// Shared constraints are keyed by absolute URIs, so that
+		// documents do not get mixed up
+		if opts.shared != nil {
+			ctx.Doc = u.String()
+		} else {
+			ctx.Base = u
+		}
+	}
+	if jsctx == nil {
+		jsctx = s
+	}
+	ctx.J = jsctx
+
+	root := location{doc: ctx.Doc, ptr: opts.ptr}
+	rootKey := root.key(ctx.Base)
+	if opts.ptr != "" {
+		x, err := ctx.index()
+		if err != nil {
+			return nil, err
+		}
+		ctx.Scope = x.scope(root)
+	}
+
+	if opts.shared != nil {
+		if c, err := opts.shared.GetReference(rootKey); err == nil {
+			return v.SetTitle(s.Title).SetDescription(s.Description).SetRoot(c), nil
+		}
+	}
+
+	if err := checkReferences(&ctx, root); err != nil {
+		return nil, err
 	}
 
 	c, err := buildFromSchema(&ctx, s)
@@ -82,9 +304,10 @@ func (b *Builder) BuildWithCtx(s *schema.Schema, jsctx interface{}) (v *jsval.JS
 		return nil, err
 	}
 
-	if _, ok := ctx.R["#"]; ok {
-		v.SetReference("#", c)
-		delete(ctx.R, "#")
+	if _, ok := ctx.R[rootKey]; ok || opts.shared != nil {
+		ctx.P[rootKey] = c
+		setAnnotation(v, rootKey, s)
+		delete(ctx.R, rootKey)
 	}
 
 	// Now, resolve references that were used in the schema
@@ -92,22 +315,38 @@ func (b *Builder) BuildWithCtx(s *schema.Schema, jsctx interface{}) (v *jsval.JS
 		if pdebug.Enabled {
 			pdebug.Printf("Checking references now")
 		}
-		if jsctx == nil {
-			jsctx = s
-		}
 
-		r := jsref.New()
 		for ref := range ctx.R {
-			if err := compileReferences(&ctx, r, v, ref, jsctx); err != nil {
+			if err := compileReferences(&ctx, v, ref); err != nil {
 				return nil, err
 			}
 		}
 	}
-	v.SetRoot(c)
+
+	// The constraints are only added once all of them have been built,
+	// so that a ConstraintMap shared with other validators is left as
+	// is if building fails
+	for ref, c := range ctx.P {
+		v.SetReference(ref, c)
+	}
+	v.SetTitle(s.Title).SetDescription(s.Description).SetRoot(c)
 	return v, nil
 }
 
-func compileReferences(ctx *buildctx, r *jsref.Resolver, v *jsval.JSVal, ref string, jsctx interface{}) error {
+// setAnnotation records the title and the description of s, which
+// the reference ref resolves to, so that code can be generated with
+// meaningful names and comments
+func setAnnotation(v *jsval.JSVal, ref string, s *schema.Schema) {
+	if s.Title == "" && s.Description == "" {
+		return
+	}
+	v.SetAnnotation(ref, jsval.Annotation{Title: s.Title, Description: s.Description})
+}
+
+func compileReferences(ctx *buildctx, v *jsval.JSVal, ref string) error {
+	if _, ok := ctx.P[ref]; ok {
+		return nil
+	}
 	if _, err := v.GetReference(ref); err == nil {
 		if pdebug.Enabled {
 			pdebug.Printf("Already resolved constraints for reference '%s'", ref)
@@ -119,7 +358,19 @@ func compileReferences(ctx *buildctx, r *jsref.Resolver, v *jsval.JSVal, ref str
 		pdebug.Printf("Building constraints for reference '%s'", ref)
 	}
 
-	thing, err := r.Resolve(jsctx, ref)
+	// The key of a reference is the (relative) URI of the document that
+	// the schema is in, followed by the URI encoded JSON pointer to it
+	doc := ctx.U[ref]
+	ptr, err := url.PathUnescape(ref[strings.IndexByte(ref, '#')+1:])
+	if err != nil {
+		return errors.New("invalid reference " + ref + ": " + err.Error())
+	}
+
+	x, err := ctx.index()
+	if err != nil {
+		return err
+	}
+	thing, err := x.get(location{doc: doc, ptr: ptr})
 	if err != nil {
 		return err
 	}
@@ -128,25 +379,29 @@ func compileReferences(ctx *buildctx, r *jsref.Resolver, v *jsval.JSVal, ref str
 		pdebug.Printf("'%s' resolves to the main schema", ref)
 	}
 
-	var s1 *schema.Schema
-	switch thing.(type) {
-	case *schema.Schema:
-		s1 = thing.(*schema.Schema)
-	case map[string]interface{}:
-		s1 = schema.New()
-		if err := s1.Extract(thing.(map[string]interface{})); err != nil {
-			return err
-		}
+	m, ok := normalizeSubschema(thing).(map[string]interface{})
+	if !ok {
+		return errors.New("reference " + ref + " does not refer to a schema")
+	}
+	s1 := schema.New()
+	if err := s1.Extract(m); err != nil {
+		return err
 	}
 
+	// References in the referenced schema are resolved against the
+	// scope that it is in, rather than where it was referred from
+	saved := ctx.Scope
+	ctx.Scope = x.scope(location{doc: doc, ptr: ptr})
 	c1, err := buildFromSchema(ctx, s1)
+	ctx.Scope = saved
 	if err != nil {
 		return err
 	}
 
-	v.SetReference(ref, c1)
+	ctx.P[ref] = c1
+	setAnnotation(v, ref, s1)
 	for ref := range ctx.R {
-		if err := compileReferences(ctx, r, v, ref, jsctx); err != nil {
+		if err := compileReferences(ctx, v, ref); err != nil {
 			return err
 		}
 	}
@@ -154,19 +409,29 @@ func compileReferences(ctx *buildctx, r *jsref.Resolver, v *jsval.JSVal, ref str
 }
 
 func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error) {
-	if ref := s.Reference; ref != "" {
+	if id := schemaID(ctx.D, s); id != "" {
+		saved := ctx.Scope
+		ctx.Scope = resolveScope(ctx.Scope, id)
+		defer func() { ctx.Scope = saved }()
+	}
+
+	ct := jsval.All()
+
+	if s.Reference != "" {
 		c := jsval.Reference(ctx.V)
 		if err := buildReferenceConstraint(ctx, c, s); err != nil {
 			return nil, err
 		}
-		ctx.R[ref] = struct{}{}
-		return c, nil
-	}
 
-	ct := jsval.All()
+		// Prior to draft 2019-09, all other keywords next to "$ref"
+		// are ignored
+		if ctx.D < Draft201909 {
+			return c, nil
+		}
+		ct.Add(c)
+	}
 
-	switch {
-	case s.Not != nil:
+	if s.Not != nil {
 		if pdebug.Enabled {
 			pdebug.Printf("Not constraint")
 		}
@@ -175,7 +440,9 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 			return nil, err
 		}
 		ct.Add(jsval.Not(c1))
-	case len(s.AllOf) > 0:
+	}
+
+	if len(s.AllOf) > 0 {
 		if pdebug.Enabled {
 			pdebug.Printf("AllOf constraint")
 		}
@@ -188,7 +455,9 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 			ac.Add(c1)
 		}
 		ct.Add(ac.Reduce())
-	case len(s.AnyOf) > 0:
+	}
+
+	if len(s.AnyOf) > 0 {
 		if pdebug.Enabled {
 			pdebug.Printf("AnyOf constraint")
 		}
@@ -201,7 +470,9 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 			ac.Add(c1)
 		}
 		ct.Add(ac.Reduce())
-	case len(s.OneOf) > 0:
+	}
+
+	if len(s.OneOf) > 0 {
 		if pdebug.Enabled {
 			pdebug.Printf("OneOf constraint")
 		}
@@ -216,6 +487,29 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 		ct.Add(oc.Reduce())
 	}
 
+	if v, ok := s.Extras["const"]; ok {
+		if pdebug.Enabled {
+			pdebug.Printf("Const constraint")
+		}
+		ct.Add(jsval.Const(v))
+	}
+
+	if _, ok := s.Extras["if"]; ok {
+		if pdebug.Enabled {
+			pdebug.Printf("If/Then/Else constraint")
+		}
+		ic, err := buildConditionalConstraint(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		ct.Add(ic)
+	}
+
+	// The constraints built so far are applied to the same value as
+	// the type specific constraints below. unevaluatedProperties and
+	// unevaluatedItems need to know about them
+	siblings := ct.Constraints()
+
 	var sts schema.PrimitiveTypes
 	if l := len(s.Type); l > 0 {
 		sts = make(schema.PrimitiveTypes, l)
@@ -262,12 +556,18 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 				if err := buildArrayConstraint(ctx, ac, s); err != nil {
 					return nil, err
 				}
+				if _, ok := s.Extras["unevaluatedItems"]; ok && ctx.D >= Draft201909 && len(siblings) > 0 {
+					ac.Siblings(siblings...)
+				}
 				c = ac
 			case schema.ObjectType:
 				oc := jsval.Object()
 				if err := buildObjectConstraint(ctx, oc, s); err != nil {
 					return nil, err
 				}
+				if _, ok := s.Extras["unevaluatedProperties"]; ok && ctx.D >= Draft201909 && len(siblings) > 0 {
+					oc.Siblings(siblings...)
+				}
 				c = oc
 			case schema.NullType:
 				c = jsval.NullConstraint
@@ -277,6 +577,17 @@ func buildFromSchema(ctx *buildctx, s *schema.Schema) (jsval.Constraint, error)
 			tct.Add(c)
 		}
 		ct.Add(tct.Reduce())
+
+		// Object and array constraints do not check enumerations
+		// by themselves
+		if len(s.Enum) > 0 {
+			for _, st := range sts {
+				if st == schema.ObjectType || st == schema.ArrayType {
+					ct.Add(jsval.Enum(s.Enum...))
+					break
+				}
+			}
+		}
 	} else {
 		// All else failed, check if we have some enumeration?
 		if len(s.Enum) > 0 {
@@ -330,6 +641,13 @@ func guessSchemaType(s *schema.Schema) schema.PrimitiveTypes {
 		sts = append(sts, schema.BooleanType)
 	}
 
+	if schemaLooksLikeNull(s) {
+		if pdebug.Enabled {
+			pdebug.Printf("Looks like it could be a null...")
+		}
+		sts = append(sts, schema.NullType)
+	}
+
 	if pdebug.Enabled {
 		pdebug.Printf("Guessed types: %#v", sts)
 	}
@@ -366,6 +684,16 @@ func schemaLooksLikeObject(s *schema.Schema) bool {
 		return true
 	}
 
+	if len(s.Dependencies.Names) > 0 || len(s.Dependencies.Schemas) > 0 {
+		return true
+	}
+
+	for _, name := range []string{"propertyNames", "dependentRequired", "dependentSchemas", "unevaluatedProperties"} {
+		if _, ok := s.Extras[name]; ok {
+			return true
+		}
+	}
+
 	for _, v := range s.Enum {
 		rv := reflect.ValueOf(v)
 		switch rv.Kind() {
@@ -406,6 +734,12 @@ func schemaLooksLikeArray(s *schema.Schema) bool {
 		return true
 	}
 
+	for _, name := range []string{"contains", "prefixItems", "unevaluatedItems"} {
+		if _, ok := s.Extras[name]; ok {
+			return true
+		}
+	}
+
 	for _, v := range s.Enum {
 		rv := reflect.ValueOf(v)
 		switch rv.Kind() {
@@ -503,4 +837,14 @@ func schemaLooksLikeBool(s *schema.Schema) bool {
 	}
 
 	return false
-}
\ No newline at end of file
+}
+
+func schemaLooksLikeNull(s *schema.Schema) bool {
+	for _, v := range s.Enum {
+		if v == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,10 +1,12 @@
 package builder
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
 	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,4 +34,130 @@ func TestGuessType(t *testing.T) {
 			return
 		}
 	}
-}
\ No newline at end of file
+}
+func TestNormalizeSchema(t *testing.T) {
+	src := map[string]interface{}{
+		"exclusiveMinimum": float64(5),
+		"minimum":          float64(1),
+		"exclusiveMaximum": float64(10),
+		"maximum":          float64(8),
+		"properties": map[string]interface{}{
+			"foo": false,
+			"bar": true,
+		},
+		"const": true,
+	}
+
+	expected := map[string]interface{}{
+		"exclusiveMinimum": true,
+		"minimum":          float64(5),
+		"maximum":          float64(8),
+		"properties": map[string]interface{}{
+			"foo": map[string]interface{}{"not": map[string]interface{}{}},
+			"bar": map[string]interface{}{},
+		},
+		"const": true,
+	}
+
+	if !assert.Equal(t, expected, NormalizeSchema(src), "normalized schema matches") {
+		return
+	}
+
+	if !assert.Equal(t, false, src["properties"].(map[string]interface{})["foo"], "source schema is not modified") {
+		return
+	}
+
+	t.Run("Literals", func(t *testing.T) {
+		// Only the literals that can not be represented as a float64
+		// are kept
+		src := map[string]interface{}{
+			"minimum":    json.Number("0.1"),
+			"maximum":    json.Number("9007199254740993"),
+			"multipleOf": json.Number("1"),
+		}
+		expected := map[string]interface{}{
+			"minimum":                    float64(0.1),
+			"maximum":                    float64(9007199254740993),
+			"multipleOf":                 float64(1),
+			decimalLiteralKey("maximum"): json.Number("9007199254740993"),
+		}
+		if !assert.Equal(t, expected, NormalizeSchema(src), "normalized schema matches") {
+			return
+		}
+	})
+}
+
+func TestDetectDraft(t *testing.T) {
+	data := map[string]Draft{
+		"http://json-schema.org/draft-04/schema#":       Draft04,
+		"http://json-schema.org/draft-06/schema#":       Draft06,
+		"http://json-schema.org/draft-07/schema":        Draft07,
+		"https://json-schema.org/draft/2019-09/schema":  Draft201909,
+		"https://json-schema.org/draft/2020-12/schema#": Draft202012,
+		"":                                   DraftAuto,
+		"https://example.com/my-meta-schema": DraftAuto,
+	}
+	for uri, expected := range data {
+		if !assert.Equal(t, expected, DetectDraft(uri), "DetectDraft(%q)", uri) {
+			return
+		}
+	}
+}
+
+func TestPercentEncodedReference(t *testing.T) {
+	// "%25" in a reference is the "%" in the name of the definition
+	src := map[string]interface{}{
+		"definitions": map[string]interface{}{
+			"a%b": map[string]interface{}{"type": "string"},
+		},
+		"properties": map[string]interface{}{
+			"x": map[string]interface{}{"$ref": "#/definitions/a%25b"},
+		},
+	}
+
+	check := func(t *testing.T, v *jsval.JSVal) {
+		if !assert.NoError(t, v.Validate(map[string]interface{}{"x": "foo"}), "Validate should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(map[string]interface{}{"x": 1}), "Validate should fail") {
+			return
+		}
+	}
+
+	t.Run("BuildWithCtx", func(t *testing.T) {
+		s := schema.New()
+		if !assert.NoError(t, s.Extract(src), "schema.Extract should succeed") {
+			return
+		}
+		v, err := New().BuildWithCtx(s, src)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+		check(t, v)
+	})
+
+	t.Run("BuildURI", func(t *testing.T) {
+		l := NewMemoryLoader().Set("https://example.com/schema.json", src)
+		v, err := New().SetLoader(l).BuildURI("https://example.com/schema.json#/properties/x")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(1), "Validate should fail") {
+			return
+		}
+
+		v, err = New().SetLoader(l).BuildURI("https://example.com/schema.json#/definitions/a%25b")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.Validate("foo"), "Validate should succeed") {
+			return
+		}
+
+		v, err = New().SetLoader(l).BuildURI("https://example.com/schema.json")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		check(t, v)
+	})
+}
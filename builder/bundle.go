@@ -0,0 +1,641 @@
+package builder
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Bundle loads the document at the URI u using the Loader, and returns
+// a copy of it that is self-contained: the documents that it refers to
+// are embedded in its "definitions" ("$defs" as of draft 2019-09), and
+// the references to them are rewritten to point there. References are
+// resolved the same way as when building validators.
+func (b *Builder) Bundle(u string) (map[string]interface{}, error) {
+	bd, err := b.newBundler(u)
+	if err != nil {
+		return nil, err
+	}
+	return bd.bundle()
+}
+
+// Deref loads the document at the URI u using the Loader, and returns
+// a copy of it with every reference replaced by the schema that it
+// refers to. Recursive references can not be replaced, so they are
+// kept, pointing within the returned document (see Bundle), which
+// holds only the subschemas of other documents that they point to.
+func (b *Builder) Deref(u string) (map[string]interface{}, error) {
+	bd, err := b.newBundler(u)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := bd.bundle()
+	if err != nil {
+		return nil, err
+	}
+	return bd.deref(doc)
+}
+
+// bundler holds the state of Bundle and Deref
+type bundler struct {
+	draft  Draft
+	base   *url.URL // URI of the root document
+	rootID string   // absolute URI of the root document's identifier, if any
+	defs   string   // keyword that documents are embedded under
+	index  *schemaIndex
+	load   func(string) (interface{}, error)
+	root   map[string]interface{}
+	names  map[string]string // URIs of embedded documents to their names
+	taken  map[string]bool
+	queue  []string
+}
+
+func (b *Builder) newBundler(u string) (*bundler, error) {
+	base, err := url.Parse(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid URI %s", u)
+	}
+	base = stripFragment(base)
+
+	load := func(uri string) (interface{}, error) {
+		return b.cache.load(b.loader, uri)
+	}
+	x, err := load(base.String())
+	if err != nil {
+		return nil, err
+	}
+	root, ok := x.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("document %s is not a JSON object", base)
+	}
+
+	draft := b.draft
+	if draft == DraftAuto {
+		s, _ := root["$schema"].(string)
+		if draft = DetectDraft(s); draft == DraftAuto {
+			draft = Draft07
+		}
+	}
+
+	bd := &bundler{
+		draft: draft,
+		base:  base,
+		defs:  "definitions",
+		index: newSchemaIndex(draft),
+		load:  load,
+		root:  root,
+		names: make(map[string]string),
+		taken: make(map[string]bool),
+	}
+	if draft >= Draft201909 {
+		bd.defs = "$defs"
+	}
+	if id, ok := schemaIdentifier(draft, root); ok {
+		if u := resolveScope(base, id); u.IsAbs() {
+			bd.rootID = u.String()
+		}
+	}
+	if defs, ok := root[bd.defs].(map[string]interface{}); ok {
+		for name := range defs {
+			bd.taken[name] = true
+		}
+	}
+	bd.index.addDocument("", root, base)
+	return bd, nil
+}
+
+func (bd *bundler) bundle() (map[string]interface{}, error) {
+	x, err := bd.rewrite(bd.root, location{}, bd.base, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := x.(map[string]interface{})
+
+	// Embedding documents may bring in references to more documents
+	embedded := make(map[string]interface{})
+	for len(bd.queue) > 0 {
+		uri := bd.queue[0]
+		bd.queue = bd.queue[1:]
+
+		doc, err := bd.load(uri)
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		x, err := bd.rewrite(doc, location{doc: uri}, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := x.(map[string]interface{}); ok {
+			delete(m, "$schema")
+		}
+		embedded[bd.names[uri]] = x
+	}
+
+	if len(embedded) > 0 {
+		defs := make(map[string]interface{})
+		if m, ok := out[bd.defs].(map[string]interface{}); ok {
+			for k, v := range m {
+				defs[k] = v
+			}
+		}
+		for k, v := range embedded {
+			defs[k] = v
+		}
+		out[bd.defs] = defs
+	}
+	return out, nil
+}
+
+// rewrite returns a copy of the subschema v at loc, with its references
+// rewritten to point within the bundled document. scope is the URI that
+// references in v are resolved against, and res is the location of the
+// closest subschema that has an identifier, other than the root, if any.
+// Such subschemas are embedded as they are, identifier included.
+func (bd *bundler) rewrite(v interface{}, loc location, scope *url.URL, res *location) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	if id, ok := schemaIdentifier(bd.draft, m); ok {
+		scope = resolveScope(scope, id)
+		if loc != (location{}) {
+			l := loc
+			res = &l
+		}
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		s, err := bd.rewriteRef(ref, loc, scope, res)
+		if err != nil {
+			return nil, err
+		}
+		out["$ref"] = s
+
+		// Prior to draft 2019-09, the siblings of "$ref" are ignored
+		if bd.draft < Draft201909 {
+			return out, nil
+		}
+	}
+
+	err := walkSubschemas(m, loc, func(_ string, child location, v interface{}) (interface{}, error) {
+		return bd.rewrite(v, child, scope, res)
+	}, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (bd *bundler) rewriteRef(ref string, loc location, scope *url.URL, res *location) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid reference %s", ref)
+	}
+	target, err := bd.index.locate(resolveURI(scope, u), bd.load)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve reference %s", ref)
+	}
+
+	if res == nil {
+		// Fragments in the root document mean the same once bundled,
+		// but other references may depend on where the document was
+		if loc.doc == "" && target.doc == "" && strings.HasPrefix(ref, "#") {
+			return ref, nil
+		}
+		return "#" + escapePointer(bd.embed(target)), nil
+	}
+
+	// Within a subschema that has an identifier, references to the
+	// same subschema are resolved the same way once it is embedded.
+	// Others can only point within the root by its identifier
+	if isWithin(target, *res) {
+		return ref, nil
+	}
+	if bd.rootID == "" {
+		return "", errors.Errorf("can not rewrite reference %s in a subschema with an identifier, as the root document has no absolute identifier", ref)
+	}
+	return bd.rootID + "#" + escapePointer(bd.embed(target)), nil
+}
+
+// embed returns the JSON pointer to the subschema at loc in the
+// bundled document, queueing the document it is in to be embedded
+func (bd *bundler) embed(loc location) string {
+	if loc.doc == "" {
+		return loc.ptr
+	}
+
+	name, ok := bd.names[loc.doc]
+	if !ok {
+		name = bd.docName(loc.doc)
+		bd.names[loc.doc] = name
+		bd.taken[name] = true
+		bd.queue = append(bd.queue, loc.doc)
+	}
+	return location{}.child(bd.defs).child(name).ptr + loc.ptr
+}
+
+// docName picks the name that the document at uri is embedded under,
+// after its path relative to the root document (e.g. "types/id.json"
+// becomes "types_id")
+func (bd *bundler) docName(uri string) string {
+	var l []string
+	if u, err := url.Parse(uri); err == nil {
+		rel := relativeURI(bd.base, u)
+		if ru, err := url.Parse(rel); err == nil && ru.IsAbs() {
+			rel = ru.Host + ru.Path
+		}
+		for _, s := range strings.Split(strings.TrimSuffix(rel, path.Ext(rel)), "/") {
+			if s != "" && s != "." && s != ".." {
+				l = append(l, s)
+			}
+		}
+	}
+	name := strings.Join(l, "_")
+	if name == "" {
+		name = "schema"
+	}
+
+	unique := name
+	for i := 2; bd.taken[unique]; i++ {
+		unique = name + "_" + strconv.Itoa(i)
+	}
+	return unique
+}
+
+func (bd *bundler) deref(doc map[string]interface{}) (map[string]interface{}, error) {
+	x := newSchemaIndex(bd.draft)
+	x.addDocument("", doc, bd.base)
+	d := &derefer{bundler: bd, x: x, doc: doc, kept: make(map[string]bool)}
+
+	// Embedded documents are only dereferenced (and kept) if recursive
+	// references point to them
+	root := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		root[k] = v
+	}
+	embedded, _ := doc[bd.defs].(map[string]interface{})
+	if len(bd.names) > 0 {
+		defs := make(map[string]interface{}, len(embedded))
+		for k, v := range embedded {
+			defs[k] = v
+		}
+		for _, name := range bd.names {
+			delete(defs, name)
+		}
+		root[bd.defs] = defs
+		if _, ok := bd.root[bd.defs]; !ok {
+			delete(root, bd.defs)
+		}
+	}
+
+	v, err := d.inline(root, location{}, bd.base, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := v.(map[string]interface{})
+
+	// Only the subschemas that recursive references point to are kept,
+	// along with the objects that lead to them. Inlining them may bring
+	// in recursive references to more subschemas
+	var done []location
+	for progress := true; progress; {
+		progress = false
+		for _, ptr := range d.keptPointers() {
+			loc, ok := d.placement(ptr)
+			if !ok || isWithinAny(loc, done) {
+				continue
+			}
+			done = append(done, loc)
+			progress = true
+
+			tv, err := lookupPointer(doc, loc.ptr)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.inline(tv, loc, x.scope(loc), nil)
+			if err != nil {
+				return nil, err
+			}
+			setPointer(out, loc.ptr, v)
+		}
+	}
+
+	// Everything else in the embedded documents is unreachable now
+	if defs, ok := out[bd.defs].(map[string]interface{}); ok {
+		for _, name := range bd.names {
+			if v, ok := defs[name]; ok {
+				d.prune(v, location{}.child(bd.defs).child(name))
+			}
+		}
+	}
+	return out, nil
+}
+
+// derefer holds the state of Deref, which works on the bundled document
+type derefer struct {
+	*bundler
+	x    *schemaIndex // index of doc
+	doc  map[string]interface{}
+	kept map[string]bool // JSON pointers that recursive references point to
+}
+
+// isKept reports whether a recursive reference points within loc
+func (d *derefer) isKept(loc location) bool {
+	for ptr := range d.kept {
+		if isWithin(location{ptr: ptr}, loc) {
+			return true
+		}
+	}
+	return false
+}
+
+// keptPointers returns the JSON pointers that recursive references
+// point to, sorted so that subschemas come after those containing them
+func (d *derefer) keptPointers() []string {
+	l := make([]string, 0, len(d.kept))
+	for ptr := range d.kept {
+		l = append(l, ptr)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// placement returns the location of the subschema that is copied from
+// an embedded document so that the subschema at ptr is kept, or false
+// if ptr is not in an embedded document. Lists can not be copied in
+// part, so a subschema in a list is copied along with the subschema
+// that holds the list.
+func (d *derefer) placement(ptr string) (location, bool) {
+	toks := pointerTokens(ptr)
+	if len(toks) < 2 || toks[0] != d.defs {
+		return location{}, false
+	}
+	embedded := false
+	for _, name := range d.names {
+		embedded = embedded || name == toks[1]
+	}
+	if !embedded {
+		return location{}, false
+	}
+
+	loc := location{}
+	var v interface{} = d.doc
+	for i, tok := range toks {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		v = m[tok]
+		if _, ok := v.(map[string]interface{}); !ok && i < len(toks)-1 {
+			break
+		}
+		loc = loc.child(tok)
+	}
+	return loc, true
+}
+
+// prune removes the definitions that no recursive reference points
+// within from the subschema v at loc, and the subschemas in it
+func (d *derefer) prune(v interface{}, loc location) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"definitions", "$defs"} {
+		defs, ok := m[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name := range defs {
+			if !d.isKept(loc.child(key).child(name)) {
+				delete(defs, name)
+			}
+		}
+		if len(defs) == 0 {
+			delete(m, key)
+		}
+	}
+	walkSubschemas(m, loc, func(_ string, child location, v interface{}) (interface{}, error) {
+		d.prune(v, child)
+		return v, nil
+	}, nil)
+}
+
+// setPointer stores v at the JSON pointer ptr in doc, creating the
+// objects on the way as needed
+func setPointer(doc map[string]interface{}, ptr string, v interface{}) {
+	toks := pointerTokens(ptr)
+	m := doc
+	for i, tok := range toks {
+		if i == len(toks)-1 {
+			m[tok] = v
+			return
+		}
+		next, ok := m[tok].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[tok] = next
+		}
+		m = next
+	}
+}
+
+// inline returns a copy of the subschema v at loc, with references
+// replaced by the subschemas they refer to. via holds the locations of
+// the references that were followed to get to v
+func (d *derefer) inline(v interface{}, loc location, scope *url.URL, via []location) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	if id, ok := schemaIdentifier(d.draft, m); ok {
+		scope = resolveScope(scope, id)
+	}
+
+	// All references end up pointing within the root document, which
+	// identifiers in subschemas would get in the way of
+	if loc.ptr != "" {
+		for _, key := range []string{"id", "$id", "$anchor", "$schema"} {
+			delete(out, key)
+		}
+	}
+
+	ref, hasRef := m["$ref"].(string)
+	if !hasRef || d.draft >= Draft201909 {
+		err := walkSubschemas(m, loc, func(_ string, child location, v interface{}) (interface{}, error) {
+			return d.inline(v, child, scope, via)
+		}, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !hasRef {
+		return out, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %s", ref)
+	}
+	target, err := d.x.locate(resolveURI(scope, u), func(uri string) (interface{}, error) {
+		return nil, errors.Errorf("document %s is not bundled", uri)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve reference %s", ref)
+	}
+
+	// A reference to a subschema that contains it, or any of the
+	// references that were followed to get here, is recursive
+	recursive := isWithin(loc, target)
+	for _, l := range via {
+		recursive = recursive || isWithin(l, target)
+	}
+	if recursive {
+		d.kept[target.ptr] = true
+		out["$ref"] = "#" + escapePointer(target.ptr)
+		return out, nil
+	}
+
+	tv, err := lookupPointer(d.doc, target.ptr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve reference %s", ref)
+	}
+	inlined, err := d.inline(tv, target, d.x.scope(target), append(via, loc))
+	if err != nil {
+		return nil, err
+	}
+
+	delete(out, "$ref")
+	if d.draft < Draft201909 || len(out) == 0 {
+		return inlined, nil
+	}
+
+	// As of draft 2019-09, the siblings of "$ref" apply as well
+	var allOf []interface{}
+	if l, ok := out["allOf"].([]interface{}); ok {
+		allOf = append(allOf, l...)
+	}
+	out["allOf"] = append(allOf, inlined)
+	return out, nil
+}
+
+// walkSubschemas calls fn for each of the subschemas in m, in a fixed
+// order, with the keyword that each is under, and stores the results
+// in out (if not nil) under the same keys
+func walkSubschemas(m map[string]interface{}, loc location, fn func(string, location, interface{}) (interface{}, error), out map[string]interface{}) error {
+	for _, key := range scopeSubschemaKeys {
+		v, ok := m[key]
+		if !ok {
+			continue
+		}
+		if _, ok := v.([]interface{}); ok {
+			continue
+		}
+		x, err := fn(key, loc.child(key), v)
+		if err != nil {
+			return err
+		}
+		if out != nil {
+			out[key] = x
+		}
+	}
+
+	for _, key := range subschemaListKeys {
+		l, ok := m[key].([]interface{})
+		if !ok {
+			continue
+		}
+		nl := make([]interface{}, len(l))
+		for i, v := range l {
+			x, err := fn(key, loc.child(key).child(strconv.Itoa(i)), v)
+			if err != nil {
+				return err
+			}
+			nl[i] = x
+		}
+		if out != nil {
+			out[key] = nl
+		}
+	}
+
+	for _, key := range subschemaMapKeys {
+		sm, ok := m[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(sm))
+		for name := range sm {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		nm := make(map[string]interface{}, len(sm))
+		for _, name := range names {
+			x, err := fn(key, loc.child(key).child(name), sm[name])
+			if err != nil {
+				return err
+			}
+			nm[name] = x
+		}
+		if out != nil {
+			out[key] = nm
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether loc is the same as, or within, the
+// subschema at parent
+func isWithin(loc, parent location) bool {
+	return loc.doc == parent.doc && (loc.ptr == parent.ptr || strings.HasPrefix(loc.ptr, parent.ptr+"/"))
+}
+
+// isWithinAny reports whether loc is within any of the locations in l
+func isWithinAny(loc location, l []location) bool {
+	for _, parent := range l {
+		if isWithin(loc, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointerTokens returns the unescaped reference tokens of the JSON
+// pointer ptr
+func pointerTokens(ptr string) []string {
+	if ptr == "" {
+		return nil
+	}
+	toks := strings.Split(ptr[1:], "/")
+	for i, tok := range toks {
+		tok = strings.Replace(tok, "~1", "/", -1)
+		toks[i] = strings.Replace(tok, "~0", "~", -1)
+	}
+	return toks
+}
+
+// escapePointer escapes the JSON pointer ptr for use as a URI fragment
+func escapePointer(ptr string) string {
+	if ptr == "" {
+		return ""
+	}
+	return (&url.URL{Fragment: ptr}).String()[1:]
+}
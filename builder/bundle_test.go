@@ -0,0 +1,224 @@
+package builder
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBundleTestBuilder(t *testing.T) *Builder {
+	l := NewMemoryLoader()
+	for name := range loaderTestDocs {
+		l.Set("https://example.com/schemas/"+name, decodeLoaderTestDoc(t, name))
+	}
+	for name, src := range map[string]string{
+		"tree.json": `{
+  "type": "object",
+  "properties": {
+    "id": { "$ref": "types/id.json" },
+    "children": { "type": "array", "items": { "$ref": "#" } }
+  }
+}`,
+		"ids.json": `{
+  "$id": "https://example.com/schemas/ids.json",
+  "properties": { "a": { "$ref": "a.json" } },
+  "definitions": {
+    "a": {
+      "$id": "a.json",
+      "properties": {
+        "id": { "$ref": "types/id.json" },
+        "a": { "$ref": "#" }
+      }
+    }
+  }
+}`,
+		"nodes.json": `{
+  "definitions": {
+    "node": {
+      "type": "object",
+      "properties": {
+        "pos": { "$ref": "#/definitions/pos" },
+        "children": { "type": "array", "items": { "$ref": "#/definitions/node" } }
+      }
+    },
+    "pos": { "type": "integer", "minimum": 0 }
+  }
+}`,
+		"forest.json": `{ "type": "array", "items": { "$ref": "nodes.json#/definitions/node" } }`,
+	} {
+		var doc interface{}
+		if !assert.NoError(t, json.Unmarshal([]byte(src), &doc), "json.Unmarshal should succeed") {
+			t.FailNow()
+		}
+		l.Set("https://example.com/schemas/"+name, doc)
+	}
+	return New().SetLoader(l)
+}
+
+// buildBundled builds a validator from doc, without a loader, so that
+// references outside of it can not be resolved
+func buildBundled(t *testing.T, doc map[string]interface{}) *jsval.JSVal {
+	m := NormalizeSchema(doc)
+	s := schema.New()
+	if !assert.NoError(t, s.Extract(m), "schema.Extract should succeed") {
+		t.FailNow()
+	}
+	v, err := New().BuildWithCtx(s, m)
+	if !assert.NoError(t, err, "BuildWithCtx should succeed") {
+		t.FailNow()
+	}
+	return v
+}
+
+// collectRefs returns the "$ref"s in v
+func collectRefs(v interface{}) []string {
+	var refs []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			if s, ok := x.(string); ok && k == "$ref" {
+				refs = append(refs, s)
+				continue
+			}
+			refs = append(refs, collectRefs(x)...)
+		}
+	case []interface{}:
+		for _, x := range v {
+			refs = append(refs, collectRefs(x)...)
+		}
+	}
+	return refs
+}
+
+func TestBundle(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		b := newBundleTestBuilder(t)
+		doc, err := b.Bundle("https://example.com/schemas/order.json")
+		if !assert.NoError(t, err, "Bundle should succeed") {
+			return
+		}
+
+		defs, ok := doc["definitions"].(map[string]interface{})
+		if !assert.True(t, ok, "definitions exist") {
+			return
+		}
+		for _, name := range []string{"note", "common", "types_id"} {
+			if !assert.Contains(t, defs, name, "definitions contain %s", name) {
+				return
+			}
+		}
+		for _, ref := range collectRefs(doc) {
+			if !assert.Regexp(t, "^#", ref, "references point within the document") {
+				return
+			}
+		}
+		checkLoaderTestValidator(t, buildBundled(t, doc))
+
+		// The output does not depend on the order of maps
+		buf1, _ := json.Marshal(doc)
+		for i := 0; i < 5; i++ {
+			doc, err := newBundleTestBuilder(t).Bundle("https://example.com/schemas/order.json")
+			if !assert.NoError(t, err, "Bundle should succeed") {
+				return
+			}
+			buf2, _ := json.Marshal(doc)
+			if !assert.Equal(t, string(buf1), string(buf2), "Bundle is deterministic") {
+				return
+			}
+		}
+	})
+
+	t.Run("Identifiers", func(t *testing.T) {
+		doc, err := newBundleTestBuilder(t).Bundle("https://example.com/schemas/ids.json")
+		if !assert.NoError(t, err, "Bundle should succeed") {
+			return
+		}
+
+		buf, _ := json.Marshal(doc)
+		expected := `{"$id":"https://example.com/schemas/ids.json","definitions":{"a":{"$id":"a.json","properties":{"a":{"$ref":"#"},"id":{"$ref":"https://example.com/schemas/ids.json#/definitions/types_id"}}},"types_id":{"pattern":"^[0-9]+$","type":"string"}},"properties":{"a":{"$ref":"#/definitions/a"}}}`
+		if !assert.Equal(t, expected, string(buf), "subschemas with identifiers are kept as they are") {
+			return
+		}
+	})
+}
+
+func TestDeref(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		doc, err := newBundleTestBuilder(t).Deref("https://example.com/schemas/order.json")
+		if !assert.NoError(t, err, "Deref should succeed") {
+			return
+		}
+		if !assert.Empty(t, collectRefs(doc), "all references are inlined") {
+			return
+		}
+		defs, _ := doc["definitions"].(map[string]interface{})
+		if !assert.Equal(t, []string{"note"}, keysOf(defs), "embedded documents are dropped") {
+			return
+		}
+		checkLoaderTestValidator(t, buildBundled(t, doc))
+	})
+
+	t.Run("Recursive", func(t *testing.T) {
+		doc, err := newBundleTestBuilder(t).Deref("https://example.com/schemas/tree.json")
+		if !assert.NoError(t, err, "Deref should succeed") {
+			return
+		}
+		if !assert.Equal(t, []string{"#"}, collectRefs(doc), "recursive references are kept") {
+			return
+		}
+
+		v := buildBundled(t, doc)
+		valid := map[string]interface{}{
+			"id":       "1",
+			"children": []interface{}{map[string]interface{}{"id": "2"}},
+		}
+		if !assert.NoError(t, v.Validate(valid), "Validate should succeed") {
+			return
+		}
+		invalid := map[string]interface{}{
+			"id":       "1",
+			"children": []interface{}{map[string]interface{}{"id": "x"}},
+		}
+		if !assert.Error(t, v.Validate(invalid), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		doc, err := newBundleTestBuilder(t).Deref("https://example.com/schemas/forest.json")
+		if !assert.NoError(t, err, "Deref should succeed") {
+			return
+		}
+
+		buf, _ := json.Marshal(doc)
+		expected := `{"definitions":{"nodes":{"definitions":{"node":{"properties":{"children":{"items":{"$ref":"#/definitions/nodes/definitions/node"},"type":"array"},"pos":{"minimum":0,"type":"integer"}},"type":"object"}}}},"items":{"properties":{"children":{"items":{"$ref":"#/definitions/nodes/definitions/node"},"type":"array"},"pos":{"minimum":0,"type":"integer"}},"type":"object"},"type":"array"}`
+		if !assert.Equal(t, expected, string(buf), "only the subschemas that recursive references point to are kept") {
+			return
+		}
+
+		v := buildBundled(t, doc)
+		valid := []interface{}{
+			map[string]interface{}{"pos": 1, "children": []interface{}{map[string]interface{}{"pos": 2}}},
+		}
+		if !assert.NoError(t, v.Validate(valid), "Validate should succeed") {
+			return
+		}
+		invalid := []interface{}{
+			map[string]interface{}{"pos": 1, "children": []interface{}{map[string]interface{}{"pos": -1}}},
+		}
+		if !assert.Error(t, v.Validate(invalid), "Validate should fail") {
+			return
+		}
+	})
+}
+
+func keysOf(m map[string]interface{}) []string {
+	var l []string
+	for k := range m {
+		l = append(l, k)
+	}
+	return l
+}
@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/pdebug"
+)
+
+func buildConditionalConstraint(ctx *buildctx, s *schema.Schema) (c jsval.Constraint, err error) {
+	if pdebug.Enabled {
+		g := pdebug.IPrintf("START buildConditionalConstraint")
+		defer func() {
+			if err == nil {
+				g.IRelease("END buildConditionalConstraint (PASS)")
+			} else {
+				g.IRelease("END buildConditionalConstraint (FAIL): %s", err)
+			}
+		}()
+	}
+
+	cs, _, err := extraSchema(s, "if")
+	if err != nil {
+		return nil, err
+	}
+
+	cond, err := buildFromSchema(ctx, cs)
+	if err != nil {
+		return nil, err
+	}
+	ic := jsval.If(cond)
+
+	if ts, ok, err := extraSchema(s, "then"); err != nil {
+		return nil, err
+	} else if ok {
+		tc, err := buildFromSchema(ctx, ts)
+		if err != nil {
+			return nil, err
+		}
+		ic.Then(tc)
+	}
+
+	if es, ok, err := extraSchema(s, "else"); err != nil {
+		return nil, err
+	} else if ok {
+		ec, err := buildFromSchema(ctx, es)
+		if err != nil {
+			return nil, err
+		}
+		ic.Else(ec)
+	}
+
+	return ic, nil
+}
@@ -0,0 +1,406 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jsschema"
+)
+
+// Draft identifies a version of the JSON Schema specification. It
+// decides how keywords whose meaning changed between versions (such
+// as "items" and "$ref") are interpreted.
+type Draft int
+
+// These are the supported drafts. Keywords introduced in draft 2019-09
+// (such as "dependentRequired" and "unevaluatedProperties") are only
+// recognized when building a 2019-09 or later schema.
+const (
+	// DraftAuto picks the draft from the "$schema" keyword of the
+	// schema being built. If it is missing or unknown, Draft07 is used
+	DraftAuto Draft = iota
+	Draft04
+	Draft06
+	Draft07
+	Draft201909
+	Draft202012
+)
+
+var draftNames = map[Draft]string{
+	DraftAuto:   "auto",
+	Draft04:     "draft-04",
+	Draft06:     "draft-06",
+	Draft07:     "draft-07",
+	Draft201909: "2019-09",
+	Draft202012: "2020-12",
+}
+
+// String returns the name of the draft
+func (d Draft) String() string {
+	if s, ok := draftNames[d]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+var draftURIs = map[string]Draft{
+	"json-schema.org/draft-04/schema":      Draft04,
+	"json-schema.org/draft-06/schema":      Draft06,
+	"json-schema.org/draft-07/schema":      Draft07,
+	"json-schema.org/draft/2019-09/schema": Draft201909,
+	"json-schema.org/draft/2020-12/schema": Draft202012,
+}
+
+// DetectDraft returns the draft identified by the given "$schema"
+// URI. The scheme and the trailing empty fragment are ignored, so
+// both "http://json-schema.org/draft-07/schema#" and
+// "https://json-schema.org/draft-07/schema" yield Draft07. DraftAuto
+// is returned for unknown URIs.
+func DetectDraft(uri string) Draft {
+	uri = strings.TrimSuffix(uri, "#")
+	for _, prefix := range []string{"https://", "http://"} {
+		uri = strings.TrimPrefix(uri, prefix)
+	}
+	if d, ok := draftURIs[uri]; ok {
+		return d
+	}
+	return DraftAuto
+}
+
+// Keywords whose values are a single subschema
+var subschemaKeys = []string{
+	"contains",
+	"else",
+	"if",
+	"items",
+	"not",
+	"propertyNames",
+	"then",
+	"unevaluatedItems",
+	"unevaluatedProperties",
+}
+
+// Keywords whose values are a list of subschemas
+var subschemaListKeys = []string{
+	"allOf",
+	"anyOf",
+	"items",
+	"oneOf",
+	"prefixItems",
+}
+
+// Keywords whose values are a map of subschemas
+var subschemaMapKeys = []string{
+	"$defs",
+	"definitions",
+	"dependencies",
+	"dependentSchemas",
+	"patternProperties",
+	"properties",
+}
+
+// NormalizeSchema takes a JSON schema decoded into a map, and rewrites
+// constructs introduced in draft-06 and later that jsschema (which only
+// understands draft-04) cannot parse. Keywords that jsschema does not
+// know about (such as "prefixItems" or "$defs") are normalized as well,
+// so that they can be extracted from `schema.Schema.Extras` later.
+// Boolean subschemas are converted to their object equivalents (`true`
+// becomes `{}`, and `false` becomes `{"not": {}}`), and numeric
+// `exclusiveMinimum`/`exclusiveMaximum` are converted to
+// `minimum`/`maximum` with a boolean flag.
+//
+// The map may have been decoded with json.Decoder.UseNumber. Numbers
+// are then converted to float64. If the literal of "minimum", "maximum"
+// or "multipleOf" stands for a different number than the float64 (as
+// 9007199254740993 does, but 0.1 does not), it is kept under the key
+// "$jsval:minimum", "$jsval:maximum" or "$jsval:multipleOf", and the
+// builder checks the bound exactly (see NumberConstraint.MaximumDecimal).
+// These keys are not JSON schema keywords: they end up in
+// `schema.Schema.Extras`, and are only meant to be read by the builder.
+//
+// The original map is not modified. Use this before extracting a
+// draft-06/07 schema with `schema.Schema.Extract`, and pass the result
+// to `Builder.BuildWithCtx` as the context.
+func NormalizeSchema(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+
+	for _, key := range subschemaKeys {
+		v, ok := out[key]
+		if !ok {
+			continue
+		}
+		if _, ok := v.([]interface{}); ok { // tuple "items"
+			continue
+		}
+		out[key] = normalizeSubschema(v)
+	}
+
+	for _, key := range subschemaListKeys {
+		l, ok := out[key].([]interface{})
+		if !ok {
+			continue
+		}
+		nl := make([]interface{}, len(l))
+		for i, v := range l {
+			nl[i] = normalizeSubschema(v)
+		}
+		out[key] = nl
+	}
+
+	for _, key := range subschemaMapKeys {
+		sm, ok := out[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		nm := make(map[string]interface{}, len(sm))
+		for name, v := range sm {
+			if _, ok := v.([]interface{}); ok { // property dependencies
+				nm[name] = v
+				continue
+			}
+			nm[name] = normalizeSubschema(v)
+		}
+		out[key] = nm
+	}
+
+	// additionalItems and additionalProperties may also be booleans,
+	// which jsschema understands
+	for _, key := range []string{"additionalItems", "additionalProperties"} {
+		if sm, ok := out[key].(map[string]interface{}); ok {
+			out[key] = NormalizeSchema(sm)
+		}
+	}
+
+	// jsschema only accepts numbers decoded as float64. Numbers decoded
+	// as json.Number (with json.Decoder.UseNumber) are converted, and
+	// the literals of the bounds are kept, so that they can be checked
+	// without losing precision
+	literals := make(map[string]json.Number)
+	for key, v := range out {
+		if containsSubschemas(key) || strings.HasPrefix(key, decimalLiteralPrefix) {
+			continue
+		}
+		if n, ok := v.(json.Number); ok && decimalKeys[key] && !exactFloat(n) {
+			literals[decimalLiteralKey(key)] = n
+		}
+		out[key] = floatNumbers(v)
+	}
+	for key, n := range literals {
+		out[key] = n
+	}
+
+	// As of draft-06, exclusiveMinimum/exclusiveMaximum are numbers.
+	// Fold them into minimum/maximum, keeping whichever is stricter
+	if x, ok := out["exclusiveMinimum"].(float64); ok {
+		if min, ok := out["minimum"].(float64); !ok || x >= min {
+			out["minimum"] = x
+			out["exclusiveMinimum"] = true
+			moveDecimalLiteral(out, "exclusiveMinimum", "minimum")
+		} else {
+			delete(out, "exclusiveMinimum")
+			delete(out, decimalLiteralKey("exclusiveMinimum"))
+		}
+	}
+
+	if x, ok := out["exclusiveMaximum"].(float64); ok {
+		if max, ok := out["maximum"].(float64); !ok || x <= max {
+			out["maximum"] = x
+			out["exclusiveMaximum"] = true
+			moveDecimalLiteral(out, "exclusiveMaximum", "maximum")
+		} else {
+			delete(out, "exclusiveMaximum")
+			delete(out, decimalLiteralKey("exclusiveMaximum"))
+		}
+	}
+
+	return out
+}
+
+func containsSubschemas(key string) bool {
+	switch key {
+	case "additionalItems", "additionalProperties":
+		return true
+	}
+	for _, keys := range [][]string{subschemaKeys, subschemaListKeys, subschemaMapKeys} {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// floatNumbers returns a copy of v, with the json.Number values in it
+// converted to float64. Numbers that do not fit in a float64 are left
+// for `schema.Schema.Extract` to reject
+func floatNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = floatNumbers(e)
+		}
+		return l
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = floatNumbers(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// Keywords whose literals are kept by NormalizeSchema
+var decimalKeys = map[string]bool{
+	"exclusiveMaximum": true,
+	"exclusiveMinimum": true,
+	"maximum":          true,
+	"minimum":          true,
+	"multipleOf":       true,
+}
+
+// exactFloat reports whether the literal n stands for the same number
+// as the float64 that jsschema extracts from it, so that it does not
+// need to be kept. Literals that are not numbers, or do not fit in a
+// float64, are not kept either, as they are rejected anyway
+func exactFloat(n json.Number) bool {
+	f, err := n.Float64()
+	if err != nil {
+		return true
+	}
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return true
+	}
+	fr, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return !ok || r.Cmp(fr) == 0
+}
+
+// decimalLiteralKey returns the key that NormalizeSchema stores the
+// literal of the keyword under. jsschema does not know about it, so
+// it ends up in `schema.Schema.Extras`
+func decimalLiteralKey(name string) string {
+	return decimalLiteralPrefix + name
+}
+
+const decimalLiteralPrefix = "$jsval:"
+
+func moveDecimalLiteral(m map[string]interface{}, from, to string) {
+	if n, ok := m[decimalLiteralKey(from)]; ok {
+		m[decimalLiteralKey(to)] = n
+		delete(m, decimalLiteralKey(from))
+	} else {
+		delete(m, decimalLiteralKey(to))
+	}
+}
+
+// decimalLiteral returns the literal that NormalizeSchema kept for the
+// keyword, if any
+func decimalLiteral(s *schema.Schema, name string) (json.Number, bool) {
+	n, ok := s.Extras[decimalLiteralKey(name)].(json.Number)
+	return n, ok
+}
+
+func normalizeSubschema(v interface{}) interface{} {
+	switch v.(type) {
+	case bool:
+		if v.(bool) {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"not": map[string]interface{}{}}
+	case map[string]interface{}:
+		return NormalizeSchema(v.(map[string]interface{}))
+	default:
+		return v
+	}
+}
+
+// extraSchema extracts the subschema stored under the given keyword
+// in the list of keywords that jsschema does not know about.
+func extraSchema(s *schema.Schema, name string) (*schema.Schema, bool, error) {
+	v, ok := s.Extras[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	m, ok := normalizeSubschema(v).(map[string]interface{})
+	if !ok {
+		return nil, false, errors.New("invalid value for '" + name + "': expected a schema")
+	}
+
+	s1 := schema.New()
+	if err := s1.Extract(m); err != nil {
+		return nil, false, err
+	}
+	return s1, true, nil
+}
+
+// extraSchemaList is like extraSchema, but for keywords whose values
+// are a list of subschemas
+func extraSchemaList(s *schema.Schema, name string) ([]*schema.Schema, bool, error) {
+	v, ok := s.Extras[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, false, errors.New("invalid value for '" + name + "': expected a list of schemas")
+	}
+
+	list := make([]*schema.Schema, len(l))
+	for i, v := range l {
+		m, ok := normalizeSubschema(v).(map[string]interface{})
+		if !ok {
+			return nil, false, errors.New("invalid value for '" + name + "': expected a list of schemas")
+		}
+		s1 := schema.New()
+		if err := s1.Extract(m); err != nil {
+			return nil, false, err
+		}
+		list[i] = s1
+	}
+	return list, true, nil
+}
+
+// extraSchemaMap is like extraSchema, but for keywords whose values
+// are a map of subschemas
+func extraSchemaMap(s *schema.Schema, name string) (map[string]*schema.Schema, error) {
+	v, ok := s.Extras[name]
+	if !ok {
+		return nil, nil
+	}
+
+	sm, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid value for '" + name + "': expected a map of schemas")
+	}
+
+	schemas := make(map[string]*schema.Schema, len(sm))
+	for key, v := range sm {
+		m, ok := normalizeSubschema(v).(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid value for '" + name + "': expected a map of schemas")
+		}
+		s1 := schema.New()
+		if err := s1.Extract(m); err != nil {
+			return nil, err
+		}
+		schemas[key] = s1
+	}
+	return schemas, nil
+}
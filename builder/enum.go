@@ -8,6 +8,6 @@ import (
 func buildEnumConstraint(_ *buildctx, c *jsval.EnumConstraint, s *schema.Schema) error {
 	l := make([]interface{}, len(s.Enum))
 	copy(l, s.Enum)
-	c.Enum(l)
+	c.Enum(l...)
 	return nil
 }
@@ -0,0 +1,231 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lestrrat-go/jsval/internal/fileuri"
+	"github.com/pkg/errors"
+)
+
+// Loader loads the documents that JSON references point to, such as
+// "common.json" in `{"$ref": "common.json#/definitions/address"}`.
+// Load receives the absolute URI of the document, without the fragment,
+// and returns the decoded JSON document.
+type Loader interface {
+	Load(*url.URL) (interface{}, error)
+}
+
+// LoaderFunc adapts an ordinary function to a Loader
+type LoaderFunc func(*url.URL) (interface{}, error)
+
+// Load calls f(u)
+func (f LoaderFunc) Load(u *url.URL) (interface{}, error) {
+	return f(u)
+}
+
+// decodeDocument decodes a loaded document. Numbers are decoded as
+// json.Number, so that the bounds in the schemas are not rounded (see
+// NormalizeSchema)
+func decodeDocument(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "failed to decode JSON")
+	}
+	return doc, nil
+}
+
+type fileLoader struct{}
+
+// NewFileLoader creates a Loader that reads documents from the local
+// filesystem. It accepts "file" URIs, and URIs without a scheme, which
+// are treated as paths.
+func NewFileLoader() Loader {
+	return fileLoader{}
+}
+
+func (fileLoader) Load(u *url.URL) (interface{}, error) {
+	if u.Scheme != "" && u.Scheme != "file" {
+		return nil, errors.Errorf("unsupported scheme for file loader: %s", u)
+	}
+
+	f, err := os.Open(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeDocument(f)
+}
+
+// FileURI returns the "file" URI of the file fn, so that it can be
+// used as the base URI to resolve references against (see
+// `Builder.SetBaseURI`)
+func FileURI(fn string) (string, error) {
+	return fileuri.FromPath(fn)
+}
+
+// MemoryLoader is a Loader that serves documents that were registered
+// in advance. It is mostly useful in tests, and for schemas that are
+// compiled into the program.
+type MemoryLoader struct {
+	lock sync.Mutex
+	docs map[string]interface{}
+}
+
+// NewMemoryLoader creates a new, empty MemoryLoader
+func NewMemoryLoader() *MemoryLoader {
+	return &MemoryLoader{
+		docs: make(map[string]interface{}),
+	}
+}
+
+// Set registers the decoded JSON document doc under the absolute
+// URI uri. Any fragment in uri is ignored
+func (l *MemoryLoader) Set(uri string, doc interface{}) *MemoryLoader {
+	if i := strings.IndexByte(uri, '#'); i > -1 {
+		uri = uri[:i]
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.docs[uri] = doc
+	return l
+}
+
+// Load returns the document registered under u
+func (l *MemoryLoader) Load(u *url.URL) (interface{}, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	doc, ok := l.docs[u.String()]
+	if !ok {
+		return nil, errors.Errorf("document %s not found", u)
+	}
+	return doc, nil
+}
+
+// DefaultMaxDocumentSize is the size, in bytes, of the largest
+// document that an HTTPLoader fetches, unless told otherwise
+const DefaultMaxDocumentSize = 10 << 20
+
+// HTTPLoader is a Loader that fetches documents over HTTP(S)
+type HTTPLoader struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// NewHTTPLoader creates a Loader that fetches documents over HTTP(S)
+// using client. If client is nil, http.DefaultClient is used. It is
+// never used unless specified, as building a schema would otherwise
+// make network requests.
+func NewHTTPLoader(client *http.Client) *HTTPLoader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPLoader{client: client, maxSize: DefaultMaxDocumentSize}
+}
+
+// SetMaxSize specifies the size, in bytes, of the largest document
+// that is fetched. Loading larger documents fails without reading
+// them any further. The default is DefaultMaxDocumentSize.
+func (l *HTTPLoader) SetMaxSize(n int64) *HTTPLoader {
+	l.maxSize = n
+	return l
+}
+
+// Load fetches the document at u
+func (l *HTTPLoader) Load(u *url.URL) (interface{}, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme for HTTP loader: %s", u)
+	}
+
+	res, err := l.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch %s: %s", u, res.Status)
+	}
+
+	// Read one more byte than allowed, to tell documents that are
+	// too large from those that are exactly as large as allowed
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, l.maxSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", u)
+	}
+	if int64(len(buf)) > l.maxSize {
+		return nil, errors.Errorf("failed to fetch %s: document is larger than %d bytes", u, l.maxSize)
+	}
+	return decodeDocument(bytes.NewReader(buf))
+}
+
+// docCache holds the documents that were loaded by a Builder, so that
+// each document is only loaded once
+type docCache struct {
+	lock sync.Mutex
+	docs map[string]interface{}
+}
+
+func (c *docCache) load(l Loader, uri string) (interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if doc, ok := c.docs[uri]; ok {
+		return doc, nil
+	}
+
+	if l == nil {
+		return nil, errors.Errorf("no loader to load %s with (see Builder.SetLoader)", uri)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := l.Load(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", uri)
+	}
+
+	if c.docs == nil {
+		c.docs = make(map[string]interface{})
+	}
+	c.docs[uri] = doc
+	return doc, nil
+}
+
+// relativeURI returns u relative to base, if they share the same
+// scheme and host. Otherwise u is returned as is
+func relativeURI(base, u *url.URL) string {
+	if base == nil || !u.IsAbs() || base.Scheme != u.Scheme || base.Host != u.Host || base.User.String() != u.User.String() || u.RawQuery != "" {
+		return u.String()
+	}
+
+	from := strings.Split(strings.TrimSuffix(path.Dir(base.Path), "/"), "/")
+	to := strings.Split(u.Path, "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+
+	var l []string
+	for j := i; j < len(from); j++ {
+		l = append(l, "..")
+	}
+	l = append(l, to[i:]...)
+	return (&url.URL{Path: strings.Join(l, "/")}).String()
+}
@@ -0,0 +1,40 @@
+//go:build go1.16
+// +build go1.16
+
+package builder
+
+import (
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type fsLoader struct {
+	fsys fs.FS
+}
+
+// NewFSLoader creates a Loader that reads documents from fsys, such
+// as an `embed.FS`. The path of each URI, without the leading slash,
+// is used as the name of the file in fsys. The scheme and the host
+// are ignored, so any base URI (e.g. "file:///schemas/order.json")
+// can be used.
+func NewFSLoader(fsys fs.FS) Loader {
+	return &fsLoader{fsys: fsys}
+}
+
+func (l *fsLoader) Load(u *url.URL) (interface{}, error) {
+	name := path.Clean(strings.TrimPrefix(u.Path, "/"))
+	if !fs.ValidPath(name) {
+		return nil, errors.Errorf("invalid path for fs loader: %s", u)
+	}
+
+	f, err := l.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeDocument(f)
+}
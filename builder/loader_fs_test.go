@@ -0,0 +1,24 @@
+//go:build go1.16
+// +build go1.16
+
+package builder
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSLoader(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for name, src := range loaderTestDocs {
+		fsys["schemas/"+name] = &fstest.MapFile{Data: []byte(src)}
+	}
+
+	v, err := New().SetLoader(NewFSLoader(fsys)).BuildURI("file:///schemas/order.json")
+	if !assert.NoError(t, err, "BuildURI should succeed") {
+		return
+	}
+	checkLoaderTestValidator(t, v)
+}
@@ -0,0 +1,270 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+var loaderTestDocs = map[string]string{
+	"order.json": `{
+  "type": "object",
+  "properties": {
+    "id": { "$ref": "types/id.json" },
+    "shipTo": { "$ref": "common.json#/definitions/address" },
+    "billTo": { "$ref": "common.json#/definitions/address" },
+    "note": { "$ref": "#/definitions/note" }
+  },
+  "required": [ "id", "shipTo" ],
+  "definitions": {
+    "note": { "type": "string", "maxLength": 10 }
+  }
+}`,
+	"common.json": `{
+  "definitions": {
+    "address": {
+      "type": "object",
+      "properties": {
+        "street": { "$ref": "#/definitions/street" },
+        "zip": { "$ref": "types/id.json" }
+      },
+      "required": [ "street" ]
+    },
+    "street": { "type": "string", "minLength": 1 }
+  }
+}`,
+	"types/id.json": `{ "type": "string", "pattern": "^[0-9]+$" }`,
+}
+
+func decodeLoaderTestDoc(t *testing.T, name string) interface{} {
+	var doc interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(loaderTestDocs[name]), &doc), "json.Unmarshal should succeed") {
+		t.FailNow()
+	}
+	return doc
+}
+
+func checkLoaderTestValidator(t *testing.T, v *jsval.JSVal) {
+	valid := map[string]interface{}{
+		"id":     "123",
+		"shipTo": map[string]interface{}{"street": "Main St.", "zip": "12345"},
+	}
+	if !assert.NoError(t, v.Validate(valid), "Validate should succeed") {
+		return
+	}
+
+	for _, invalid := range []map[string]interface{}{
+		{"id": "abc", "shipTo": map[string]interface{}{"street": "Main St."}},
+		{"id": "123", "shipTo": map[string]interface{}{"street": ""}},
+		{"id": "123", "shipTo": map[string]interface{}{"street": "Main St.", "zip": "abc"}},
+		{"id": "123", "shipTo": map[string]interface{}{"street": "Main St."}, "note": "this is too long"},
+	} {
+		if !assert.Error(t, v.Validate(invalid), "Validate should fail for %v", invalid) {
+			return
+		}
+	}
+}
+
+func TestLoader(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		l := NewMemoryLoader()
+		for name := range loaderTestDocs {
+			l.Set("https://example.com/schemas/"+name, decodeLoaderTestDoc(t, name))
+		}
+
+		var loaded []string
+		counter := LoaderFunc(func(u *url.URL) (interface{}, error) {
+			loaded = append(loaded, u.String())
+			return l.Load(u)
+		})
+
+		b := New().SetLoader(counter)
+		v, err := b.BuildURI("https://example.com/schemas/order.json")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		checkLoaderTestValidator(t, v)
+
+		// References are stored relative to the root document
+		for _, ref := range []string{
+			"#/definitions/note",
+			"common.json#/definitions/address",
+			"common.json#/definitions/street",
+			"types/id.json#",
+		} {
+			if _, err := v.GetReference(ref); !assert.NoError(t, err, "reference %s should exist", ref) {
+				return
+			}
+		}
+
+		// Each document is only loaded once, even across builds
+		if _, err := b.BuildURI("https://example.com/schemas/order.json"); !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		if !assert.Len(t, loaded, 3, "each document is loaded once") {
+			return
+		}
+
+		t.Run("Generate", func(t *testing.T) {
+			var buf bytes.Buffer
+			if !assert.NoError(t, jsval.NewGenerator().Process(&buf, v), "Generator.Process should succeed") {
+				return
+			}
+			code := buf.String()
+			if !assert.Equal(t, 1, strings.Count(code, "ConstraintMap{}"), "a single ConstraintMap is generated") {
+				return
+			}
+			for _, s := range []string{
+				`M.SetReference("common.json#/definitions/address", R1)`,
+				`M.SetReference("common.json#/definitions/street", R2)`,
+				`M.SetReference("types/id.json#", R3)`,
+				`jsval.Reference(M).RefersTo("common.json#/definitions/street")`,
+			} {
+				if !assert.Contains(t, code, s, "generated code contains %q", s) {
+					return
+				}
+			}
+
+			buf.Reset()
+			if !assert.NoError(t, jsval.NewGenerator().SetOptions(jsval.GeneratorOptions{Names: jsval.NameByPointer}).Process(&buf, v), "Generator.Process should succeed") {
+				return
+			}
+			if !assert.Contains(t, buf.String(), `M.SetReference("types/id.json#", ID)`, "documents are named after their files") {
+				return
+			}
+		})
+	})
+
+	t.Run("File", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "jsval-loader")
+		if !assert.NoError(t, err, "TempDir should succeed") {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		for name, src := range loaderTestDocs {
+			fn := filepath.Join(dir, filepath.FromSlash(name))
+			if !assert.NoError(t, os.MkdirAll(filepath.Dir(fn), 0755), "MkdirAll should succeed") {
+				return
+			}
+			if !assert.NoError(t, ioutil.WriteFile(fn, []byte(src), 0644), "WriteFile should succeed") {
+				return
+			}
+		}
+
+		uri, err := FileURI(filepath.Join(dir, "order.json"))
+		if !assert.NoError(t, err, "FileURI should succeed") {
+			return
+		}
+
+		v, err := New().SetLoader(NewFileLoader()).BuildURI(uri)
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		checkLoaderTestValidator(t, v)
+
+		// Schemas read elsewhere can be given a base URI
+		s, err := schema.ReadFile(filepath.Join(dir, "order.json"))
+		if !assert.NoError(t, err, "schema.ReadFile should succeed") {
+			return
+		}
+		v, err = New().SetLoader(NewFileLoader()).SetBaseURI(uri).Build(s)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+		checkLoaderTestValidator(t, v)
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			src, ok := loaderTestDocs[strings.TrimPrefix(r.URL.Path, "/schemas/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/schema+json")
+			w.Write([]byte(src))
+		}))
+		defer srv.Close()
+
+		v, err := New().SetLoader(NewHTTPLoader(srv.Client())).BuildURI(srv.URL + "/schemas/order.json")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		checkLoaderTestValidator(t, v)
+
+		if _, err := New().SetLoader(NewHTTPLoader(srv.Client())).BuildURI(srv.URL + "/schemas/missing.json"); !assert.Error(t, err, "BuildURI should fail") {
+			return
+		}
+
+		size := int64(len(loaderTestDocs["order.json"]))
+		if _, err := New().SetLoader(NewHTTPLoader(srv.Client()).SetMaxSize(size)).BuildURI(srv.URL + "/schemas/order.json"); !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		_, err = New().SetLoader(NewHTTPLoader(srv.Client()).SetMaxSize(size - 1)).BuildURI(srv.URL + "/schemas/order.json")
+		if !assert.Error(t, err, "BuildURI should fail") {
+			return
+		}
+		if !assert.Contains(t, err.Error(), "larger than", "error explains that the document is too large") {
+			return
+		}
+	})
+
+	t.Run("Numbers", func(t *testing.T) {
+		// 9007199254740993 is 2^53+1, which rounds to 2^53 as a float64
+		l := LoaderFunc(func(u *url.URL) (interface{}, error) {
+			return decodeDocument(strings.NewReader(`{"type": "integer", "maximum": 9007199254740993}`))
+		})
+		v, err := New().SetLoader(l).BuildURI("https://example.com/schemas/big.json")
+		if !assert.NoError(t, err, "BuildURI should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.ValidateJSON([]byte(`9007199254740993`)), "ValidateJSON should succeed") {
+			return
+		}
+		if !assert.Error(t, v.ValidateJSON([]byte(`9007199254740994`)), "ValidateJSON should fail") {
+			return
+		}
+	})
+
+	t.Run("NoLoader", func(t *testing.T) {
+		s, err := schema.Read(strings.NewReader(loaderTestDocs["order.json"]))
+		if !assert.NoError(t, err, "schema.Read should succeed") {
+			return
+		}
+		_, err = New().Build(s)
+		if !assert.Error(t, err, "Build should fail") {
+			return
+		}
+		if !assert.Contains(t, err.Error(), "SetLoader", "error explains how to load documents") {
+			return
+		}
+	})
+}
+
+func TestRelativeURI(t *testing.T) {
+	base, _ := url.Parse("https://example.com/schemas/v1/order.json")
+	for ref, expected := range map[string]string{
+		"common.json":                      "common.json",
+		"types/id.json":                    "types/id.json",
+		"../shared/address.json":           "../shared/address.json",
+		"/other.json":                      "../../other.json",
+		"https://example.org/schemas.json": "https://example.org/schemas.json",
+	} {
+		u, _ := url.Parse(ref)
+		if !assert.Equal(t, expected, relativeURI(base, base.ResolveReference(u)), "relativeURI(%s)", ref) {
+			return
+		}
+	}
+}
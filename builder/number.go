@@ -15,21 +15,33 @@ func buildIntegerConstraint(ctx *buildctx, nc *jsval.IntegerConstraint, s *schem
 	}
 
 	if s.Minimum.Initialized {
-		nc.Minimum(s.Minimum.Val)
+		if n, ok := decimalLiteral(s, "minimum"); ok {
+			nc.MinimumDecimal(n)
+		} else {
+			nc.Minimum(s.Minimum.Val)
+		}
 		if s.ExclusiveMinimum.Initialized {
 			nc.ExclusiveMinimum(s.ExclusiveMinimum.Val)
 		}
 	}
 
 	if s.Maximum.Initialized {
-		nc.Maximum(s.Maximum.Val)
+		if n, ok := decimalLiteral(s, "maximum"); ok {
+			nc.MaximumDecimal(n)
+		} else {
+			nc.Maximum(s.Maximum.Val)
+		}
 		if s.ExclusiveMaximum.Initialized {
 			nc.ExclusiveMaximum(s.ExclusiveMaximum.Val)
 		}
 	}
 
 	if s.MultipleOf.Initialized {
-		nc.MultipleOf(s.MultipleOf.Val)
+		if n, ok := decimalLiteral(s, "multipleOf"); ok {
+			nc.MultipleOfDecimal(n)
+		} else {
+			nc.MultipleOf(s.MultipleOf.Val)
+		}
 	}
 
 	if lst := s.Enum; len(lst) > 0 {
@@ -51,25 +63,37 @@ func buildNumberConstraint(ctx *buildctx, nc *jsval.NumberConstraint, s *schema.
 	}
 
 	if s.Minimum.Initialized {
-		nc.Minimum(s.Minimum.Val)
+		if n, ok := decimalLiteral(s, "minimum"); ok {
+			nc.MinimumDecimal(n)
+		} else {
+			nc.Minimum(s.Minimum.Val)
+		}
 		if s.ExclusiveMinimum.Initialized {
 			nc.ExclusiveMinimum(s.ExclusiveMinimum.Val)
 		}
 	}
 
 	if s.Maximum.Initialized {
-		nc.Maximum(s.Maximum.Val)
+		if n, ok := decimalLiteral(s, "maximum"); ok {
+			nc.MaximumDecimal(n)
+		} else {
+			nc.Maximum(s.Maximum.Val)
+		}
 		if s.ExclusiveMaximum.Initialized {
 			nc.ExclusiveMaximum(s.ExclusiveMaximum.Val)
 		}
 	}
 
 	if s.MultipleOf.Initialized {
-		nc.MultipleOf(s.MultipleOf.Val)
+		if n, ok := decimalLiteral(s, "multipleOf"); ok {
+			nc.MultipleOfDecimal(n)
+		} else {
+			nc.MultipleOf(s.MultipleOf.Val)
+		}
 	}
 
 	if lst := s.Enum; len(lst) > 0 {
-		nc.Enum(lst)
+		nc.Enum(lst...)
 	}
 
 	if v := s.Default; v != nil {
@@ -1,6 +1,8 @@
 package builder
 
 import (
+	"errors"
+
 	"github.com/lestrrat-go/jsschema"
 	"github.com/lestrrat-go/jsval"
 	"github.com/lestrrat-go/pdebug"
@@ -12,6 +14,10 @@ func buildObjectConstraint(ctx *buildctx, c *jsval.ObjectConstraint, s *schema.S
 		defer g.IRelease("END ObjectConstraint.FromSchema")
 	}
 
+	if ctx.T != nil {
+		c.StructInfo(ctx.T)
+	}
+
 	if l := s.Required; len(l) > 0 {
 		c.Required(l...)
 	}
@@ -53,10 +59,26 @@ func buildObjectConstraint(ctx *buildctx, c *jsval.ObjectConstraint, s *schema.S
 		}
 	}
 
+	if ps, ok, err := extraSchema(s, "propertyNames"); err != nil {
+		return err
+	} else if ok {
+		pc, err := buildFromSchema(ctx, ps)
+		if err != nil {
+			return err
+		}
+		c.PropertyNames(pc)
+	}
+
 	for from, to := range s.Dependencies.Names {
 		c.PropDependency(from, to...)
 	}
 
+	if ctx.D >= Draft201909 {
+		if err := buildDependentConstraints(ctx, c, s); err != nil {
+			return err
+		}
+	}
+
 	if depschemas := s.Dependencies.Schemas; len(depschemas) > 0 {
 		for pname, depschema := range depschemas {
 			depc, err := buildFromSchema(ctx, depschema)
@@ -70,3 +92,52 @@ func buildObjectConstraint(ctx *buildctx, c *jsval.ObjectConstraint, s *schema.S
 
 	return nil
 }
+
+// buildDependentConstraints handles the keywords that were introduced in
+// draft 2019-09: "dependentRequired" and "dependentSchemas" (which
+// replace "dependencies"), and "unevaluatedProperties"
+func buildDependentConstraints(ctx *buildctx, c *jsval.ObjectConstraint, s *schema.Schema) error {
+	if v, ok := s.Extras["dependentRequired"]; ok {
+		deps, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New("invalid value for 'dependentRequired': expected an object")
+		}
+		for from, l := range deps {
+			names, ok := l.([]interface{})
+			if !ok {
+				return errors.New("invalid value for 'dependentRequired': expected a list of property names")
+			}
+			to := make([]string, len(names))
+			for i, name := range names {
+				if to[i], ok = name.(string); !ok {
+					return errors.New("invalid value for 'dependentRequired': expected a list of property names")
+				}
+			}
+			c.PropDependency(from, to...)
+		}
+	}
+
+	depschemas, err := extraSchemaMap(s, "dependentSchemas")
+	if err != nil {
+		return err
+	}
+	for pname, depschema := range depschemas {
+		depc, err := buildFromSchema(ctx, depschema)
+		if err != nil {
+			return err
+		}
+		c.SchemaDependency(pname, depc)
+	}
+
+	if us, ok, err := extraSchema(s, "unevaluatedProperties"); err != nil {
+		return err
+	} else if ok {
+		uc, err := buildFromSchema(ctx, us)
+		if err != nil {
+			return err
+		}
+		c.UnevaluatedProperties(uc)
+	}
+
+	return nil
+}
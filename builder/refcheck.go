@@ -0,0 +1,192 @@
+package builder
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Keywords whose subschemas apply to the same value as the schema that
+// they are in. A chain of references through these alone never gets
+// any closer to the end of the value being validated.
+var inPlaceKeys = map[string]bool{
+	"allOf":            true,
+	"anyOf":            true,
+	"oneOf":            true,
+	"not":              true,
+	"if":               true,
+	"then":             true,
+	"else":             true,
+	"dependencies":     true,
+	"dependentSchemas": true,
+}
+
+// refChecker walks the subschemas that a schema consists of, following
+// references into other subschemas and documents, and keeps track of
+// which subschemas apply to the same value as which others
+type refChecker struct {
+	ctx   *buildctx
+	x     *schemaIndex
+	seen  map[location]bool
+	queue []location
+	edges map[location][]location
+}
+
+// checkReferences makes sure that every reference in the subschema at
+// root, and in the subschemas that those refer to, can be resolved,
+// and that no reference leads back to the subschema it is in without
+// descending into the value (as in `{"$ref": "#"}` at the root).
+// Either would otherwise only surface while validating, if at all.
+func checkReferences(ctx *buildctx, root location) error {
+	x, err := ctx.index()
+	if err != nil {
+		return err
+	}
+
+	rc := &refChecker{
+		ctx:   ctx,
+		x:     x,
+		seen:  make(map[location]bool),
+		queue: []location{root},
+		edges: make(map[location][]location),
+	}
+	for len(rc.queue) > 0 {
+		loc := rc.queue[0]
+		rc.queue = rc.queue[1:]
+		if rc.seen[loc] {
+			continue
+		}
+
+		v, err := x.get(loc)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve %s", loc.key(ctx.Base))
+		}
+		if err := rc.walk(loc, v, x.scope(loc)); err != nil {
+			return err
+		}
+	}
+	return rc.checkCycles()
+}
+
+func (rc *refChecker) walk(loc location, v interface{}, scope *url.URL) error {
+	if rc.seen[loc] {
+		return nil
+	}
+	rc.seen[loc] = true
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if id, ok := schemaIdentifier(rc.x.draft, m); ok {
+		scope = resolveScope(scope, id)
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		target, err := rc.resolve(scope, ref)
+		if err != nil {
+			return errors.Wrapf(err, "reference %s at %s can not be resolved", ref, loc.key(rc.ctx.Base))
+		}
+		rc.edges[loc] = append(rc.edges[loc], target)
+		rc.queue = append(rc.queue, target)
+
+		// $ref used to hide all of its siblings
+		if rc.x.draft < Draft201909 {
+			return nil
+		}
+	}
+
+	return walkSubschemas(m, loc, func(key string, child location, v interface{}) (interface{}, error) {
+		if inPlaceKeys[key] {
+			rc.edges[loc] = append(rc.edges[loc], child)
+		}
+		return v, rc.walk(child, v, scope)
+	}, nil)
+}
+
+// resolve returns the location of the subschema that ref, in the scope
+// scope, refers to, making sure that there is one
+func (rc *refChecker) resolve(scope *url.URL, ref string) (location, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return location{}, err
+	}
+	target, err := rc.x.locate(resolveURI(scope, u), rc.ctx.L)
+	if err != nil {
+		return location{}, err
+	}
+	v, err := rc.x.get(target)
+	if err != nil {
+		return location{}, err
+	}
+	switch v.(type) {
+	case map[string]interface{}, bool:
+		return target, nil
+	default:
+		return location{}, errors.Errorf("%s is not a schema", target.key(rc.ctx.Base))
+	}
+}
+
+// checkCycles looks for a chain of subschemas that applies to the same
+// value, and leads back to where it started
+func (rc *refChecker) checkCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[location]int)
+	var path []location
+	var visit func(location) error
+	visit = func(loc location) error {
+		switch state[loc] {
+		case visiting:
+			var l []string
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == loc {
+					for _, p := range path[i:] {
+						l = append(l, p.key(rc.ctx.Base))
+					}
+					break
+				}
+			}
+			l = append(l, loc.key(rc.ctx.Base))
+			return errors.Errorf("%s refers back to itself without descending into the value", strings.Join(l, " -> "))
+		case done:
+			return nil
+		}
+
+		state[loc] = visiting
+		path = append(path, loc)
+		for _, next := range rc.edges[loc] {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[loc] = done
+		return nil
+	}
+
+	// Start from the subschemas in a fixed order, so that the same
+	// cycle is reported every time
+	locs := make([]location, 0, len(rc.edges))
+	for loc := range rc.edges {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].doc != locs[j].doc {
+			return locs[i].doc < locs[j].doc
+		}
+		return locs[i].ptr < locs[j].ptr
+	})
+	for _, loc := range locs {
+		if err := visit(loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
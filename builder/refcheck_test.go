@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReferences(t *testing.T) {
+	t.Run("Unresolvable", func(t *testing.T) {
+		for _, src := range []string{
+			`{ "properties": { "a": { "$ref": "#/definitions/missing" } } }`,
+			`{ "definitions": { "b": {} }, "properties": { "a": { "$ref": "#/definitions/b/required" } } }`,
+		} {
+			_, err := buildScopeTestSchema(t, src)
+			if !assert.Error(t, err, "Build should fail for %s", src) {
+				return
+			}
+			if !assert.Contains(t, err.Error(), "at #/properties/a", "errors point to the reference") {
+				return
+			}
+		}
+	})
+
+	t.Run("Unused", func(t *testing.T) {
+		// References are checked even if they are only reachable through
+		// subschemas that the builder does not need to look at yet
+		_, err := buildScopeTestSchema(t, `{
+  "properties": { "a": { "$ref": "#/definitions/a" } },
+  "definitions": {
+    "a": { "items": { "$ref": "#/definitions/b" } },
+    "b": { "properties": { "c": { "$ref": "#/definitions/c" } } }
+  }
+}`)
+		if !assert.Error(t, err, "Build should fail") {
+			return
+		}
+		if !assert.Contains(t, err.Error(), "reference #/definitions/c at #/definitions/b/properties/c", "errors point to the reference") {
+			return
+		}
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		for _, src := range []string{
+			`{ "properties": { "a": { "$ref": "#/definitions/x" } }, "definitions": { "x": { "$ref": "#/definitions/x" } } }`,
+			`{ "definitions": { "x": { "$ref": "#/definitions/y" }, "y": { "$ref": "#/definitions/x" } } }`,
+			`{ "type": "object", "anyOf": [ { "type": "null" }, { "$ref": "#" } ] }`,
+			`{ "definitions": { "x": { "not": { "allOf": [ { "$ref": "#/definitions/x" } ] } } } }`,
+		} {
+			_, err := buildScopeTestSchema(t, src)
+			if !assert.Error(t, err, "Build should fail for %s", src) {
+				return
+			}
+			if !assert.Contains(t, err.Error(), "refers back to itself", "cycles are reported") {
+				return
+			}
+		}
+	})
+
+	t.Run("Recursive", func(t *testing.T) {
+		// References back to a schema that apply to a part of the
+		// value are fine
+		for _, src := range []string{
+			`{ "type": "object", "properties": { "next": { "$ref": "#" } } }`,
+			`{ "$ref": "#/definitions/node", "definitions": { "node": { "items": { "anyOf": [ { "type": "string" }, { "$ref": "#/definitions/node" } ] } } } }`,
+		} {
+			if _, err := buildScopeTestSchema(t, src); !assert.NoError(t, err, "Build should succeed for %s", src) {
+				return
+			}
+		}
+	})
+}
@@ -8,12 +8,19 @@ import (
 	"github.com/lestrrat-go/pdebug"
 )
 
-func buildReferenceConstraint(_ *buildctx, r *jsval.ReferenceConstraint, s *schema.Schema) error {
+func buildReferenceConstraint(ctx *buildctx, r *jsval.ReferenceConstraint, s *schema.Schema) error {
 	pdebug.Printf("ReferenceConstraint.buildFromSchema '%s'", s.Reference)
 	if s.Reference == "" {
 		return errors.New("schema does not contain a reference")
 	}
-	r.RefersTo(s.Reference)
+
+	key, doc, err := refTarget(ctx, s.Reference)
+	if err != nil {
+		return err
+	}
+	r.RefersTo(key)
+	ctx.R[key] = struct{}{}
+	ctx.U[key] = doc
 
 	return nil
 }
@@ -0,0 +1,359 @@
+package builder
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/pkg/errors"
+)
+
+// location identifies a subschema by the absolute URI of the document
+// it is in ("" for the root document), and the JSON pointer to it
+type location struct {
+	doc string
+	ptr string
+}
+
+// key returns the key that the constraint built from the subschema at
+// loc is stored under in the ConstraintMap. Subschemas in the root
+// document are keyed by their JSON pointers (e.g. "#/definitions/foo"),
+// and subschemas in other documents by the URI of the document relative
+// to the root document (e.g. "common.json#/definitions/address"), so
+// that the keys do not depend on where the schemas are.
+func (loc location) key(base *url.URL) string {
+	frag := "#" + strings.Replace(loc.ptr, "%", "%25", -1)
+	if loc.doc == "" {
+		return frag
+	}
+
+	u, err := url.Parse(loc.doc)
+	if err != nil {
+		return loc.doc + frag
+	}
+	return relativeURI(base, u) + frag
+}
+
+// Keywords, in addition to those that are normalized, whose values are
+// subschemas that may be the target of references
+var scopeSubschemaKeys = append([]string{"additionalItems", "additionalProperties"}, subschemaKeys...)
+
+// schemaIndex keeps track of the resolution scopes in the documents
+// that a schema consists of. Each subschema that has an identifier
+// ("id" before draft-06, "$id" and "$anchor" afterwards) is registered
+// under its canonical URI, so that references such as
+// "http://example.com/node" or "#address" can be resolved to it
+// wherever it is.
+type schemaIndex struct {
+	draft  Draft
+	docs   map[string]interface{}
+	ids    map[string]location
+	scopes map[location]*url.URL
+}
+
+func newSchemaIndex(d Draft) *schemaIndex {
+	return &schemaIndex{
+		draft:  d,
+		docs:   make(map[string]interface{}),
+		ids:    make(map[string]location),
+		scopes: make(map[location]*url.URL),
+	}
+}
+
+// addDocument registers the subschemas in the document doc, which is
+// identified by the absolute URI uri ("" for the root document), and
+// whose retrieval URI is base
+func (x *schemaIndex) addDocument(uri string, doc interface{}, base *url.URL) {
+	x.docs[uri] = doc
+	if base == nil {
+		base = &url.URL{}
+	}
+	x.ids[resourceURI(base)] = location{doc: uri}
+	x.add(location{doc: uri}, doc, base)
+}
+
+func (x *schemaIndex) add(loc location, v interface{}, base *url.URL) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	// The scope that the subschema is in, before its own identifier is
+	// applied, is what it is built with
+	x.scopes[loc] = base
+
+	scope := base
+	if id, ok := schemaIdentifier(x.draft, m); ok {
+		if u, err := url.Parse(id); err == nil {
+			scope = resolveURI(base, u)
+			if u.Path != "" || u.Host != "" || u.Scheme != "" {
+				x.ids[resourceURI(scope)] = loc
+			}
+			if scope.Fragment != "" && !strings.HasPrefix(scope.Fragment, "/") {
+				// Plain name fragments, such as "#foo", were used as
+				// anchors before draft 2019-09
+				x.ids[scope.String()] = loc
+			}
+			scope = stripFragment(scope)
+		}
+	}
+	if anchor, ok := m["$anchor"].(string); ok && x.draft >= Draft201909 {
+		x.ids[resourceURI(scope)+"#"+anchor] = loc
+	}
+
+	// $ref used to hide all of its siblings, so there are no
+	// subschemas to look for
+	if _, ok := m["$ref"]; ok && x.draft < Draft201909 {
+		return
+	}
+
+	for _, key := range scopeSubschemaKeys {
+		x.add(loc.child(key), m[key], scope)
+	}
+	for _, key := range subschemaListKeys {
+		l, _ := m[key].([]interface{})
+		for i, v := range l {
+			x.add(loc.child(key).child(strconv.Itoa(i)), v, scope)
+		}
+	}
+	for _, key := range subschemaMapKeys {
+		sm, _ := m[key].(map[string]interface{})
+		for name, v := range sm {
+			x.add(loc.child(key).child(name), v, scope)
+		}
+	}
+}
+
+// get returns the subschema at loc, in one of the documents that were
+// indexed
+func (x *schemaIndex) get(loc location) (interface{}, error) {
+	doc, ok := x.docs[loc.doc]
+	if !ok {
+		return nil, errors.Errorf("document %s is not loaded", loc.doc)
+	}
+	return lookupPointer(doc, loc.ptr)
+}
+
+// lookupPointer returns the value at the JSON pointer ptr in the
+// decoded JSON document doc. Unlike jsref, it does not follow the
+// references that it comes across on the way.
+func lookupPointer(doc interface{}, ptr string) (interface{}, error) {
+	if ptr == "" {
+		return doc, nil
+	}
+	if ptr[0] != '/' {
+		return nil, errors.Errorf("invalid JSON pointer %s", ptr)
+	}
+
+	v := doc
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.Replace(tok, "~1", "/", -1)
+		tok = strings.Replace(tok, "~0", "~", -1)
+
+		var ok bool
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v, ok = x[tok]
+		case []interface{}:
+			var i int
+			if i, ok = arrayIndex(tok, len(x)); ok {
+				v = x[i]
+			}
+		}
+		if !ok {
+			return nil, errors.Errorf("JSON pointer %s not found", ptr)
+		}
+	}
+	return v, nil
+}
+
+// arrayIndex parses the JSON pointer token tok as an index into an
+// array of n elements
+func arrayIndex(tok string, n int) (int, bool) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, false
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// scope returns the resolution scope that the subschema at loc is in.
+// If loc does not point to a known subschema, the scope of the closest
+// subschema that contains it is used
+func (x *schemaIndex) scope(loc location) *url.URL {
+	for {
+		if u, ok := x.scopes[loc]; ok {
+			return u
+		}
+		i := strings.LastIndexByte(loc.ptr, '/')
+		if i < 0 {
+			return &url.URL{}
+		}
+		loc.ptr = loc.ptr[:i]
+	}
+}
+
+// locate returns the location of the subschema that the absolute (or,
+// if the root document has no known URI, relative) URI u refers to.
+// Documents that have not been seen yet are loaded with load.
+func (x *schemaIndex) locate(u *url.URL, load func(string) (interface{}, error)) (location, error) {
+	res := resourceURI(u)
+	loc, ok := x.ids[res]
+	if !ok {
+		if _, ok := x.docs[res]; !ok {
+			doc, err := load(res)
+			if err != nil {
+				return location{}, err
+			}
+			x.addDocument(res, doc, stripFragment(u))
+		}
+		loc = x.ids[res]
+	}
+
+	switch frag := u.Fragment; {
+	case frag == "":
+		return loc, nil
+	case strings.HasPrefix(frag, "/"):
+		loc.ptr += frag
+		return loc, nil
+	default:
+		loc, ok := x.ids[res+"#"+frag]
+		if !ok {
+			return location{}, errors.Errorf("no schema with the identifier %s", u)
+		}
+		return loc, nil
+	}
+}
+
+func (loc location) child(name string) location {
+	name = strings.Replace(name, "~", "~0", -1)
+	name = strings.Replace(name, "/", "~1", -1)
+	return location{doc: loc.doc, ptr: loc.ptr + "/" + name}
+}
+
+// schemaIdentifier returns the identifier of the schema m, which
+// is "id" before draft-06, and "$id" afterwards
+func schemaIdentifier(d Draft, m map[string]interface{}) (string, bool) {
+	key := "$id"
+	if d == Draft04 {
+		key = "id"
+	}
+	id, ok := m[key].(string)
+	if !ok || id == "" {
+		return "", false
+	}
+	// Prior to draft 2019-09, "$ref" hides its siblings, including
+	// the identifier
+	if _, ok := m["$ref"]; ok && d < Draft201909 {
+		return "", false
+	}
+	return id, true
+}
+
+// schemaID returns the identifier of s, like schemaIdentifier
+func schemaID(d Draft, s *schema.Schema) string {
+	if s.Reference != "" && d < Draft201909 {
+		return ""
+	}
+	if d == Draft04 {
+		return s.ID
+	}
+	id, _ := s.Extras["$id"].(string)
+	return id
+}
+
+// refTarget resolves the reference ref against the current resolution
+// scope, and returns the key that the referenced constraint is stored
+// under, along with the absolute URI of the document that it is in
+// ("" for the root document)
+func refTarget(ctx *buildctx, ref string) (string, string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid reference %s", ref)
+	}
+
+	x, err := ctx.index()
+	if err != nil {
+		return "", "", err
+	}
+
+	loc, err := x.locate(resolveURI(ctx.Scope, u), ctx.L)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to resolve reference %s", ref)
+	}
+	return loc.key(ctx.Base), loc.doc, nil
+}
+
+// index returns the index of the documents that the schema being built
+// consists of, indexing the root document if it has not been yet
+func (ctx *buildctx) index() (*schemaIndex, error) {
+	if ctx.X != nil {
+		return ctx.X, nil
+	}
+
+	doc, err := rawDocument(ctx.J)
+	if err != nil {
+		return nil, err
+	}
+	base := ctx.Base
+	if ctx.Doc != "" {
+		if base, err = url.Parse(ctx.Doc); err != nil {
+			return nil, err
+		}
+	}
+	ctx.X = newSchemaIndex(ctx.D)
+	ctx.X.addDocument(ctx.Doc, doc, base)
+	return ctx.X, nil
+}
+
+// resolveScope returns the resolution scope within a schema whose
+// identifier is id, and which is in the scope base
+func resolveScope(base *url.URL, id string) *url.URL {
+	u, err := url.Parse(id)
+	if err != nil {
+		return base
+	}
+	return stripFragment(resolveURI(base, u))
+}
+
+// resolveURI resolves u against base. If base is empty, because the
+// root document has no known URI, u is left relative
+func resolveURI(base, u *url.URL) *url.URL {
+	if base.String() == "" {
+		return u
+	}
+	return base.ResolveReference(u)
+}
+
+func resourceURI(u *url.URL) string {
+	return stripFragment(u).String()
+}
+
+func stripFragment(u *url.URL) *url.URL {
+	v := *u
+	v.Fragment = ""
+	return &v
+}
+
+// rawDocument returns v as decoded JSON, so that it can be indexed
+func rawDocument(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return v, nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode schema")
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to decode schema")
+	}
+	return doc, nil
+}
@@ -0,0 +1,141 @@
+package builder
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildScopeTestSchema(t *testing.T, src string) (*jsval.JSVal, error) {
+	var m map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(src), &m), "json.Unmarshal should succeed") {
+		t.FailNow()
+	}
+	m = NormalizeSchema(m)
+
+	s := schema.New()
+	if !assert.NoError(t, s.Extract(m), "schema.Extract should succeed") {
+		t.FailNow()
+	}
+	return New().BuildWithCtx(s, m)
+}
+
+func TestScope(t *testing.T) {
+	t.Run("BundledResource", func(t *testing.T) {
+		// "#/definitions/street" in the address schema refers to its
+		// own definitions, not to those of the root document
+		v, err := buildScopeTestSchema(t, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://example.com/order.json",
+  "properties": {
+    "shipTo": { "$ref": "https://other.example.com/address.json" }
+  },
+  "definitions": {
+    "street": { "type": "integer" },
+    "address": {
+      "$id": "https://other.example.com/address.json",
+      "properties": {
+        "street": { "$ref": "#/definitions/street" }
+      },
+      "definitions": {
+        "street": { "type": "string" }
+      }
+    }
+  }
+}`)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+
+		for _, ref := range []string{"#/definitions/address", "#/definitions/address/definitions/street"} {
+			if _, err := v.GetReference(ref); !assert.NoError(t, err, "reference %s should exist", ref) {
+				return
+			}
+		}
+
+		if !assert.NoError(t, v.Validate(map[string]interface{}{"shipTo": map[string]interface{}{"street": "Main St."}}), "Validate should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(map[string]interface{}{"shipTo": map[string]interface{}{"street": 1}}), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("RelativeID", func(t *testing.T) {
+		v, err := buildScopeTestSchema(t, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://example.com/schemas/root.json",
+  "items": { "$ref": "types/positive.json" },
+  "definitions": {
+    "positive": {
+      "$id": "types/positive.json",
+      "type": "integer",
+      "minimum": 1
+    }
+  }
+}`)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+
+		if !assert.NoError(t, v.Validate([]interface{}{1, 2}), "Validate should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate([]interface{}{1, 0}), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("Anchor", func(t *testing.T) {
+		v, err := buildScopeTestSchema(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "properties": {
+    "name": { "$ref": "#name" }
+  },
+  "$defs": {
+    "name": { "$anchor": "name", "type": "string", "minLength": 1 }
+  }
+}`)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+
+		if _, err := v.GetReference("#/$defs/name"); !assert.NoError(t, err, "anchors are stored under their locations") {
+			return
+		}
+		if !assert.Error(t, v.Validate(map[string]interface{}{"name": ""}), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("RefHidesID", func(t *testing.T) {
+		// Before draft 2019-09, "$id" next to "$ref" is ignored, so
+		// "#/definitions/b" is resolved in the root document
+		v, err := buildScopeTestSchema(t, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://example.com/root.json",
+  "properties": {
+    "a": { "$id": "https://example.com/other.json", "$ref": "#/definitions/b" }
+  },
+  "definitions": {
+    "b": { "type": "string" }
+  }
+}`)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(map[string]interface{}{"a": 1}), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("UnknownAnchor", func(t *testing.T) {
+		_, err := buildScopeTestSchema(t, `{ "properties": { "a": { "$ref": "#missing" } } }`)
+		if !assert.Error(t, err, "Build should fail") {
+			return
+		}
+	})
+}
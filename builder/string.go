@@ -27,9 +27,20 @@ func buildStringConstraint(ctx *buildctx, c *jsval.StringConstraint, s *schema.S
 	}
 
 	if f := s.Format; f != "" {
+		r := ctx.F
+		if r == nil {
+			r = jsval.DefaultFormatRegistry
+		}
+		if _, ok := r.Lookup(string(f)); !ok && r.IsStrict() {
+			return errors.New("unknown format '" + string(f) + "'")
+		}
 		c.Format(string(f))
 	}
 
+	if ctx.F != nil {
+		c.Formats(ctx.F)
+	}
+
 	if lst := s.Enum; len(lst) > 0 {
 		c.Enum(lst...)
 	}
@@ -1,18 +1,28 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/lestrrat-go/jspointer"
 	"github.com/lestrrat-go/jsschema"
 	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/accessors"
 	"github.com/lestrrat-go/jsval/builder"
+	"github.com/lestrrat-go/jsval/internal/diff"
 	"github.com/lestrrat-go/jsval/server"
+	"github.com/pkg/errors"
 )
 
 func main() {
@@ -22,13 +32,35 @@ func main() {
 // jsval schema.json [ref]
 // jsval hyper-schema.json -ptr /path/to/schema1 -ptr /path/to/schema2 -ptr /path/to/schema3
 // jsval server -listen :8080
+// jsval accessors -o accessors_gen.go -t Type1 -t Type2 types.go
+// jsval generate --check -s schema.json -o validator_gen.go
+// jsval generate --check --manifest jsval.json
+// jsval validate -s schema.json data.json 'more/*.json' < stdin.ndjson
+// jsval bundle -s schema.json -o bundled.json
+// jsval deref -s schema.json -o dereferenced.json
 
 func _main() int {
 	if len(os.Args) > 1 && os.Args[1] == "server" {
 		return _server()
 	}
 
-	return _cli()
+	if len(os.Args) > 1 && os.Args[1] == "accessors" {
+		return _accessors()
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		return _validate(os.Args[2:])
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "bundle" || os.Args[1] == "deref") {
+		return _bundle(os.Args[1], os.Args[2:])
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		return _cli(os.Args[2:])
+	}
+
+	return _cli(os.Args[1:])
 }
 
 type serverOptions struct {
@@ -50,107 +82,566 @@ func _server() int {
 	return 0
 }
 
-type cliOptions struct {
-	Schema  string   `short:"s" long:"schema" description:"the source JSON schema file"`
+type accessorsOptions struct {
 	OutFile string   `short:"o" long:"outfile" description:"output file to generate"`
-	Pointer []string `short:"p" long:"ptr" description:"JSON pointer(s) within the document to create validators with"`
-	Prefix  string   `short:"P" long:"prefix" description:"prefix for validator name(s)"`
+	Type    []string `short:"t" long:"type" description:"struct type(s) to generate accessors for (default: all)"`
 }
 
-func _cli() int {
-	var opts cliOptions
-	if _, err := flags.Parse(&opts); err != nil {
+func _accessors() int {
+	var opts accessorsOptions
+	files, err := flags.ParseArgs(&opts, os.Args[2:])
+	if err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	var buf bytes.Buffer
+	g := accessors.NewGenerator().Types(opts.Type...)
+	if err := g.Process(&buf, files...); err != nil {
 		log.Printf("%s", err)
 		return 1
 	}
 
-	f, err := os.Open(opts.Schema)
+	var out io.Writer = os.Stdout
+	if fn := opts.OutFile; fn != "" {
+		f, err := os.Create(fn)
+		if err != nil {
+			log.Printf("%s", err)
+			return 1
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	if _, err := buf.WriteTo(out); err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+	return 0
+}
+
+// Exit codes of `jsval validate`
+const (
+	exitValid       = 0
+	exitInvalid     = 1 // some of the documents are invalid
+	exitSchemaError = 2 // the schema could not be built, or the options are wrong
+	exitInputError  = 3 // some of the input could not be read
+)
+
+type validateOptions struct {
+	Schema  string   `short:"s" long:"schema" description:"the JSON schema file to validate against" required:"true"`
+	Pointer []string `short:"p" long:"ptr" description:"JSON pointer(s) within the schema file to the schema(s) to validate against"`
+	NDJSON  bool     `long:"ndjson" description:"read one JSON document per line (always the case for *.ndjson and *.jsonl files)"`
+	Format  string   `short:"f" long:"format" description:"output format" choice:"text" choice:"json" default:"text"`
+}
+
+// validateResult is the outcome of validating a single document. In
+// JSON output, one result is printed per line
+type validateResult struct {
+	File   string          `json:"file"`
+	Line   int             `json:"line,omitempty"`
+	Valid  bool            `json:"valid"`
+	Errors []validateError `json:"errors,omitempty"`
+	// malformed is true if the document is not well-formed JSON
+	malformed bool
+}
+
+type validateError struct {
+	InstancePath string      `json:"instancePath"`
+	Keyword      string      `json:"keyword,omitempty"`
+	Limit        interface{} `json:"limit,omitempty"`
+	Message      string      `json:"message"`
+	Schema       string      `json:"schema,omitempty"`
+}
+
+func _validate(args []string) int {
+	var opts validateOptions
+	files, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		log.Printf("%s", err)
+		return exitSchemaError
+	}
+
+	validators, err := buildValidators(opts.Schema, opts.Pointer)
+	if err != nil {
+		log.Printf("%s: %s", opts.Schema, err)
+		return exitSchemaError
+	}
+
+	inputs, err := expandInputs(files)
 	if err != nil {
+		log.Printf("%s", err)
+		return exitInputError
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	return validateInputs(out, opts, validators, inputs)
+}
+
+// validateInputs validates the files in inputs, writes the results to
+// out, and returns the exit status
+func validateInputs(out *bufio.Writer, opts validateOptions, validators []*jsval.JSVal, inputs []string) int {
+	status := exitValid
+	report := func(r *validateResult) {
+		switch {
+		case r.malformed:
+			status = exitInputError
+		case !r.Valid && status < exitInvalid:
+			status = exitInvalid
+		}
+		if opts.Format == "json" {
+			buf, _ := json.Marshal(r)
+			out.Write(buf)
+			out.WriteByte('\n')
+			return
+		}
+
+		loc := r.File
+		if r.Line > 0 {
+			loc += ":" + strconv.Itoa(r.Line)
+		}
+		for _, e := range r.Errors {
+			switch {
+			case e.InstancePath != "":
+				fmt.Fprintf(out, "%s: %s: %s", loc, e.InstancePath, e.Message)
+			case e.Keyword != "":
+				fmt.Fprintf(out, "%s: (root): %s", loc, e.Message)
+			default:
+				fmt.Fprintf(out, "%s: %s", loc, e.Message)
+			}
+			if e.Schema != "" {
+				fmt.Fprintf(out, " (schema %s)", e.Schema)
+			}
+			out.WriteByte('\n')
+		}
+	}
+
+	for _, fn := range inputs {
+		ndjson := opts.NDJSON
+		switch filepath.Ext(fn) {
+		case ".ndjson", ".jsonl":
+			ndjson = true
+		}
+		if err := validateInput(fn, ndjson, validators, opts.Pointer, report); err != nil {
+			log.Printf("%s", err)
+			status = exitInputError
+		}
+	}
+	return status
+}
+
+// expandInputs expands the glob patterns in args. If there are no
+// arguments, the standard input ("-") is read
+func expandInputs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{"-"}, nil
+	}
+
+	var inputs []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			inputs = append(inputs, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %s", arg)
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf("no files match %s", arg)
+		}
+		inputs = append(inputs, matches...)
+	}
+	return inputs, nil
+}
+
+// validateInput validates the document(s) in the file fn, and passes the
+// results to report. If fn is "-", the standard input is read
+func validateInput(fn string, ndjson bool, validators []*jsval.JSVal, ptrs []string, report func(*validateResult)) error {
+	var r io.Reader = os.Stdin
+	name := "<stdin>"
+	if fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		name = fn
+	}
+
+	if !ndjson {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", name)
+		}
+		report(validateDocument(name, 0, buf, validators, ptrs))
+		return nil
+	}
+
+	// Lines are read one by one, so that streams of any size can be
+	// validated
+	br := bufio.NewReader(r)
+	for lineno := 1; ; lineno++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			report(validateDocument(name, lineno, line, validators, ptrs))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", name)
+		}
+	}
+}
+
+func validateDocument(name string, lineno int, buf []byte, validators []*jsval.JSVal, ptrs []string) *validateResult {
+	result := validateResult{File: name, Line: lineno, Valid: true}
+
+	// Numbers are decoded as json.Number, the same way ValidateJSON does
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	var x interface{}
+	err := dec.Decode(&x)
+	if err == nil {
+		if _, err2 := dec.Token(); err2 != io.EOF {
+			err = errors.New("extra data after the top-level value")
+		}
+	}
+	if err != nil {
+		result.Valid = false
+		result.malformed = true
+		result.Errors = append(result.Errors, validateError{Message: "invalid JSON: " + err.Error()})
+		return &result
+	}
+
+	for i, v := range validators {
+		errs := v.ValidateAll(x)
+		l := make([]validateError, 0, len(errs))
+		for _, err := range errs {
+			e := validateError{Message: err.Error()}
+			if verr, ok := err.(*jsval.ValidationError); ok {
+				e.InstancePath = verr.Pointer
+				e.Keyword = verr.Keyword
+				e.Limit = jsonLimit(verr.Limit)
+				e.Message = verr.Message
+			}
+			if len(ptrs) > 0 {
+				e.Schema = ptrs[i]
+			}
+			l = append(l, e)
+		}
+
+		// Properties are not checked in any particular order, so sort
+		// the errors to produce the same output every time
+		sort.SliceStable(l, func(i, j int) bool {
+			if l[i].InstancePath != l[j].InstancePath {
+				return l[i].InstancePath < l[j].InstancePath
+			}
+			return l[i].Message < l[j].Message
+		})
+		if len(l) > 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, l...)
+		}
+	}
+	return &result
+}
+
+// jsonLimit converts the limit of a validation error to a value
+// that can be encoded as JSON
+func jsonLimit(v interface{}) interface{} {
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+type bundleOptions struct {
+	Schema  string `short:"s" long:"schema" description:"the source JSON schema file" required:"true"`
+	OutFile string `short:"o" long:"outfile" description:"output file to write (default: standard output)"`
+}
+
+// _bundle writes the schema file with the references to other files
+// pulled into it ("bundle"), or with all non-recursive references
+// inlined ("deref")
+func _bundle(cmd string, args []string) int {
+	var opts bundleOptions
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
 		log.Printf("%s", err)
 		return 1
 	}
-	defer f.Close()
 
-	var m map[string]interface{}
-	if err := json.NewDecoder(f).Decode(&m); err != nil {
+	uri, err := builder.FileURI(opts.Schema)
+	if err != nil {
 		log.Printf("%s", err)
 		return 1
 	}
 
-	// Extract possibly multiple schemas out of the main JSON document.
-	var schemas []*schema.Schema
-	ptrs := opts.Pointer
-	if len(ptrs) == 0 {
-		s, err := schema.ReadFile(opts.Schema)
+	b := builder.New().SetLoader(builder.NewFileLoader())
+	fn := b.Bundle
+	if cmd == "deref" {
+		fn = b.Deref
+	}
+	doc, err := fn(uri)
+	if err != nil {
+		log.Printf("%s: %s", opts.Schema, err)
+		return 1
+	}
+
+	// Object keys are sorted, so the same schemas always give the
+	// same output
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	if err := writeOutput(opts.OutFile, buf.Bytes()); err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+	return 0
+}
+
+type cliOptions struct {
+	Schema   string   `short:"s" long:"schema" description:"the source JSON schema file" json:"schema"`
+	OutFile  string   `short:"o" long:"outfile" description:"output file to generate" json:"outfile"`
+	Pointer  []string `short:"p" long:"ptr" description:"JSON pointer(s) within the document to create validators with" json:"ptr"`
+	Prefix   string   `short:"P" long:"prefix" description:"prefix for the names of the generated variables" json:"prefix"`
+	Types    bool     `short:"t" long:"types" description:"generate Go types for the schema(s) as well" json:"types"`
+	Compile  bool     `short:"c" long:"compile" description:"compile the validators to Go code that checks values directly" json:"compile"`
+	Package  string   `long:"package" description:"package name of the generated file (compiled validators default to main)" json:"package"`
+	Alias    string   `long:"import-alias" description:"name used to refer to the jsval package in the generated code" json:"import-alias"`
+	Names    string   `long:"names" description:"how to name validators and references" choice:"index" choice:"title" choice:"pointer" default:"index" json:"names"`
+	Comment  bool     `long:"comments" description:"generate doc comments from schema descriptions" json:"comments"`
+	Check    bool     `long:"check" description:"do not write the output file(s), but report the differences from the generated code" json:"-"`
+	Manifest string   `short:"m" long:"manifest" description:"JSON file listing the schemas and output files to generate, with the same options as above" json:"-"`
+}
+
+func _cli(args []string) int {
+	var opts cliOptions
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		log.Printf("%s", err)
+		return 1
+	}
+
+	jobs := []cliOptions{opts}
+	if fn := opts.Manifest; fn != "" {
+		var err error
+		jobs, err = readManifest(fn)
 		if err != nil {
 			log.Printf("%s", err)
 			return 1
 		}
+	}
+
+	status := 0
+	for _, job := range jobs {
+		code, err := generate(job)
+		if err != nil {
+			log.Printf("%s: %s", job.Schema, err)
+			return 1
+		}
+
+		if opts.Check {
+			uptodate, err := check(job.OutFile, code)
+			if err != nil {
+				log.Printf("%s", err)
+				return 1
+			}
+			if !uptodate {
+				log.Printf("%s is out of date (generated from %s)", job.OutFile, job.Schema)
+				status = 1
+			}
+			continue
+		}
+
+		if err := writeOutput(job.OutFile, code); err != nil {
+			log.Printf("%s", err)
+			return 1
+		}
+	}
+
+	return status
+}
+
+// readManifest reads the list of schemas and output files to generate
+// from fn. The file contains a JSON array of objects, whose keys are
+// the long names of the command line options. Relative paths are
+// relative to the directory of the manifest.
+func readManifest(fn string) ([]cliOptions, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open manifest")
+	}
+	defer f.Close()
+
+	var jobs []cliOptions
+	if err := json.NewDecoder(f).Decode(&jobs); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode manifest %s", fn)
+	}
+
+	dir := filepath.Dir(fn)
+	for i := range jobs {
+		if jobs[i].Schema == "" {
+			return nil, errors.Errorf("manifest %s: entry %d has no schema", fn, i)
+		}
+		if jobs[i].OutFile == "" {
+			return nil, errors.Errorf("manifest %s: entry %d has no outfile", fn, i)
+		}
+		for _, p := range []*string{&jobs[i].Schema, &jobs[i].OutFile} {
+			if !filepath.IsAbs(*p) {
+				*p = filepath.Join(dir, *p)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// check compares the generated code with the contents of fn. If they
+// differ, a unified diff is printed out to stdout
+func check(fn string, code []byte) (bool, error) {
+	if fn == "" {
+		return false, errors.New("an output file must be specified to check against")
+	}
+
+	old, err := ioutil.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrap(err, "failed to read output file")
+	}
+
+	d := diff.Unified("a/"+filepath.ToSlash(fn), "b/"+filepath.ToSlash(fn), old, code)
+	if d == "" {
+		return true, nil
+	}
+	os.Stdout.WriteString(d)
+	return false, nil
+}
+
+func writeOutput(fn string, code []byte) error {
+	if fn == "" {
+		_, err := os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(fn, code, 0644)
+}
+
+// generate builds the validators as specified by opts, and returns
+// the generated code
+func generate(opts cliOptions) ([]byte, error) {
+	validators, err := buildValidators(opts.Schema, opts.Pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if opts.Compile {
+		c := jsval.NewCompiler().ImportAlias(opts.Alias).Prefix(opts.Prefix).EmitTypes(opts.Types)
+		if opts.Package != "" {
+			c.Package(opts.Package)
+		}
+		if err := c.Process(&buf, validators...); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	gopts := jsval.GeneratorOptions{
+		ImportAlias: opts.Alias,
+		Package:     opts.Package,
+		Comments:    opts.Comment,
+		Prefix:      opts.Prefix,
+	}
+	switch opts.Names {
+	case "title":
+		gopts.Names = jsval.NameByTitle
+	case "pointer":
+		gopts.Names = jsval.NameByPointer
+	}
+	g := jsval.NewGenerator().SetOptions(gopts).EmitTypes(opts.Types)
+	if err := g.Process(&buf, validators...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildValidators reads the JSON schema file fn, and builds a validator
+// for each of the schemas at the JSON pointers ptrs. If there are no
+// pointers, the entire document is the schema.
+func buildValidators(fn string, ptrs []string) ([]*jsval.JSVal, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Keep the numbers as written, so that the generated code has the
+	// exact bounds
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	m = builder.NormalizeSchema(m)
+
+	// Extract possibly multiple schemas out of the main JSON document.
+	var schemas []*schema.Schema
+	if len(ptrs) == 0 {
+		s := schema.New()
+		if err := s.Extract(m); err != nil {
+			return nil, err
+		}
 		schemas = []*schema.Schema{s}
 	} else {
 		for _, ptr := range ptrs {
 			log.Printf("Resolving pointer '%s'", ptr)
 			resolver, err := jspointer.New(ptr)
 			if err != nil {
-				log.Printf("%s", err)
-				return 1
+				return nil, err
 			}
 
 			resolved, err := resolver.Get(m)
 			if err != nil {
-				log.Printf("%s", err)
-				return 1
+				return nil, err
 			}
 
 			m2, ok := resolved.(map[string]interface{})
 			if !ok {
-				log.Printf("Expected map")
-				return 1
+				return nil, errors.New("expected map")
 			}
 
 			s := schema.New()
 			if err := s.Extract(m2); err != nil {
-				log.Printf("%s", err)
-				return 1
+				return nil, err
 			}
 			schemas = append(schemas, s)
 		}
 	}
 
-	b := builder.New()
+	// References to other files are resolved relative to fn
+	uri, err := builder.FileURI(fn)
+	if err != nil {
+		return nil, err
+	}
+	b := builder.New().SetLoader(builder.NewFileLoader()).SetBaseURI(uri)
 
 	validators := make([]*jsval.JSVal, len(schemas))
 	for i, s := range schemas {
 		v, err := b.BuildWithCtx(s, m)
 		if err != nil {
-			log.Printf("%s", err)
-			return 1
-		}
-		if p := opts.Prefix; p != "" {
-			v.Name = fmt.Sprintf("%s%d", p, i)
+			return nil, err
 		}
 		validators[i] = v
 	}
-
-	var out io.Writer
-
-	out = os.Stdout
-	if fn := opts.OutFile; fn != "" {
-		f, err := os.Create(fn)
-		if err != nil {
-			log.Printf("%s", err)
-			return 1
-		}
-		defer f.Close()
-
-		out = f
-	}
-
-	g := jsval.NewGenerator()
-	if err := g.Process(out, validators...); err != nil {
-		log.Printf("%s", err)
-		return 1
-	}
-
-	return 0
-}
\ No newline at end of file
+	return validators, nil
+}
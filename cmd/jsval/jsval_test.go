@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsval-validate")
+	if !assert.NoError(t, err, "TempDir should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	schemafn := filepath.Join(dir, "schema.json")
+	const schemaSrc = `{"type": "object", "properties": {"n": {"type": "integer", "maximum": 10}}}`
+	if !assert.NoError(t, ioutil.WriteFile(schemafn, []byte(schemaSrc), 0644), "WriteFile should succeed") {
+		return
+	}
+	validators, err := buildValidators(schemafn, nil)
+	if !assert.NoError(t, err, "buildValidators should succeed") {
+		return
+	}
+
+	type result struct {
+		Line  int
+		Valid bool
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		File     string
+		Input    string
+		Status   int
+		Expected []result
+	}{
+		{
+			Name:     "Valid",
+			File:     "valid.ndjson",
+			Input:    "{\"n\": 1}\n{\"n\": 2}\n",
+			Status:   exitValid,
+			Expected: []result{{1, true}, {2, true}},
+		},
+		{
+			Name:     "Invalid",
+			File:     "invalid.ndjson",
+			Input:    "{\"n\": 1}\n{\"n\": 11}\n{\"n\": 3}",
+			Status:   exitInvalid,
+			Expected: []result{{1, true}, {2, false}, {3, true}},
+		},
+		{
+			Name:     "EmptyLines",
+			File:     "empty.jsonl",
+			Input:    "\n{\"n\": 1}\n  \n{\"n\": 2}\n",
+			Status:   exitValid,
+			Expected: []result{{2, true}, {4, true}},
+		},
+		{
+			Name:     "MalformedLine",
+			File:     "malformed.ndjson",
+			Input:    "{\"n\": 1}\n{\"n\": \n{\"n\": 11}\n",
+			Status:   exitInputError,
+			Expected: []result{{1, true}, {2, false}, {3, false}},
+		},
+		{
+			Name:     "TrailingData",
+			File:     "trailing.ndjson",
+			Input:    "{\"n\": 1} {\"n\": 2}\n{\"n\": 3}\n",
+			Status:   exitInputError,
+			Expected: []result{{1, false}, {2, true}},
+		},
+		{
+			Name:     "MalformedDocument",
+			File:     "malformed.json",
+			Input:    `{"n": 1}}`,
+			Status:   exitInputError,
+			Expected: []result{{0, false}},
+		},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			fn := filepath.Join(dir, tc.File)
+			if !assert.NoError(t, ioutil.WriteFile(fn, []byte(tc.Input), 0644), "WriteFile should succeed") {
+				return
+			}
+
+			var buf bytes.Buffer
+			out := bufio.NewWriter(&buf)
+			status := validateInputs(out, validateOptions{Format: "json"}, validators, []string{fn})
+			if !assert.NoError(t, out.Flush(), "Flush should succeed") {
+				return
+			}
+			if !assert.Equal(t, tc.Status, status, "exit status should match") {
+				return
+			}
+
+			var results []result
+			dec := json.NewDecoder(&buf)
+			for dec.More() {
+				var r validateResult
+				if !assert.NoError(t, dec.Decode(&r), "Decode should succeed") {
+					return
+				}
+				if !assert.Equal(t, fn, r.File, "file name should match") {
+					return
+				}
+				results = append(results, result{r.Line, r.Valid})
+			}
+			if !assert.Equal(t, tc.Expected, results, "results should match") {
+				return
+			}
+		})
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsval-generate")
+	if !assert.NoError(t, err, "TempDir should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	schemafn := filepath.Join(dir, "schema.json")
+	const schemaSrc = `{"title": "order", "type": "object", "properties": {"n": {"type": "integer"}}}`
+	if !assert.NoError(t, ioutil.WriteFile(schemafn, []byte(schemaSrc), 0644), "WriteFile should succeed") {
+		return
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		Names    string
+		Expected string
+	}{
+		{Name: "Index", Names: "index", Expected: "var My0 *jsval.JSVal"},
+		{Name: "Title", Names: "title", Expected: "var MyOrder *jsval.JSVal"},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			code, err := generate(cliOptions{Schema: schemafn, Prefix: "My", Names: tc.Names})
+			if !assert.NoError(t, err, "generate should succeed") {
+				return
+			}
+			if !assert.Contains(t, string(code), tc.Expected, "validator is named after the prefix and the strategy") {
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package jsval
+
+// multiValidator is implemented by constraints that are able to keep
+// validating after they find the first violation.
+type multiValidator interface {
+	validate(interface{}, *errorList)
+}
+
+// errorList accumulates the errors found during validation. Unless
+// collect is true, validation stops after the first error is found.
+// depth is the number of references that are being followed, which
+// is passed on to the lists of the constraints that are validated
+// with l, so that runaway recursion can be stopped once it reaches
+// maxDepth (DefaultMaxReferenceDepth if zero).
+type errorList struct {
+	collect  bool
+	depth    int
+	maxDepth int
+	errors   []error
+}
+
+// add records err, and reports whether the validation should stop.
+func (l *errorList) add(err error) bool {
+	if err == nil {
+		return false
+	}
+	l.errors = append(l.errors, err)
+	return !l.collect
+}
+
+// err returns the first error found, if any
+func (l *errorList) err() error {
+	if len(l.errors) == 0 {
+		return nil
+	}
+	return l.errors[0]
+}
+
+// check validates v against c, and records the errors as is. It
+// reports whether the validation should stop.
+func (l *errorList) check(c Constraint, v interface{}) bool {
+	for _, err := range l.run(c, v) {
+		if l.add(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAt is the same as check, except the pointers of the recorded
+// errors are prefixed with token.
+func (l *errorList) checkAt(c Constraint, v interface{}, token string) bool {
+	for _, err := range l.run(c, v) {
+		if l.add(withPointer(err, token)) {
+			return true
+		}
+	}
+	return false
+}
+
+// try returns the first error found validating v against c, without
+// recording it. It is used by constraints that only need to know
+// whether a value passes another constraint, such as "anyOf".
+func (l *errorList) try(c Constraint, v interface{}) error {
+	l1 := errorList{depth: l.depth, maxDepth: l.maxDepth}
+	l1.check(c, v)
+	return l1.err()
+}
+
+// run validates v against c, and returns the errors found
+func (l *errorList) run(c Constraint, v interface{}) []error {
+	if mv, ok := c.(multiValidator); ok {
+		l1 := errorList{collect: l.collect, depth: l.depth, maxDepth: l.maxDepth}
+		mv.validate(v, &l1)
+		return l1.errors
+	}
+
+	if err := c.Validate(v); err != nil {
+		return []error{err}
+	}
+	return nil
+}
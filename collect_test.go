@@ -0,0 +1,71 @@
+package jsval_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAll(t *testing.T) {
+	v := jsval.New().SetRoot(
+		jsval.Object().
+			AddProp(`name`, jsval.String().MinLength(3)).
+			AddProp(`zip`, jsval.All().
+				Add(jsval.String().MaxLength(5)).
+				Add(jsval.String().RegexpString(`^\d+$`)),
+			).
+			AddProp(`address`, jsval.Object().
+				AddProp(`city`, jsval.String()).
+				AddProp(`country`, jsval.String()).
+				Required(`city`, `country`),
+			).
+			AddProp(`tags`, jsval.Array().Items(jsval.String())).
+			Required(`name`),
+	)
+
+	input := map[string]interface{}{
+		"name":    "x",
+		"zip":     "abcdef",
+		"address": map[string]interface{}{},
+		"tags":    []interface{}{"foo", 1, false},
+	}
+
+	errs := v.ValidateAll(input)
+	type result struct {
+		Pointer string
+		Keyword string
+	}
+	var results []result
+	for _, err := range errs {
+		verr, ok := err.(*jsval.ValidationError)
+		if !assert.True(t, ok, "error should be a *jsval.ValidationError (got %T)", err) {
+			return
+		}
+		results = append(results, result{Pointer: verr.Pointer, Keyword: verr.Keyword})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Pointer == results[j].Pointer {
+			return results[i].Keyword < results[j].Keyword
+		}
+		return results[i].Pointer < results[j].Pointer
+	})
+
+	expected := []result{
+		{Pointer: "/address", Keyword: "required"},
+		{Pointer: "/address", Keyword: "required"},
+		{Pointer: "/name", Keyword: "minLength"},
+		{Pointer: "/tags/1", Keyword: "type"},
+		{Pointer: "/tags/2", Keyword: "type"},
+		{Pointer: "/zip", Keyword: "maxLength"},
+		{Pointer: "/zip", Keyword: "pattern"},
+	}
+	if !assert.Equal(t, expected, results, "all errors should be reported") {
+		return
+	}
+
+	if !assert.Len(t, v.ValidateAll(map[string]interface{}{"name": "John"}), 0, "valid input should not report errors") {
+		return
+	}
+}
@@ -1,10 +1,6 @@
 package jsval
 
-import (
-	"errors"
-
-	"github.com/lestrrat-go/pdebug"
-)
+import "github.com/lestrrat-go/pdebug"
 
 func (c *comboconstraint) Add(v Constraint) {
 	c.constraints = append(c.constraints, v)
@@ -51,12 +47,19 @@ func (c *AnyConstraint) Validate(v interface{}) (err error) {
 		g := pdebug.Marker("AnyConstraint.Validate").BindError(&err)
 		defer g.End()
 	}
+
+	l := errorList{}
+	c.validate(v, &l)
+	return l.err()
+}
+
+func (c *AnyConstraint) validate(v interface{}, l *errorList) {
 	for _, celem := range c.constraints {
-		if err := celem.Validate(v); err == nil {
-			return nil
+		if l.try(celem, v) == nil {
+			return
 		}
 	}
-	return errors.New("could not validate against any of the constraints")
+	l.add(newValidationError("anyOf", nil, v, "could not validate against any of the constraints"))
 }
 
 // All creates a new AllConstraint
@@ -85,12 +88,17 @@ func (c *AllConstraint) Validate(v interface{}) (err error) {
 		defer g.End()
 	}
 
+	l := errorList{}
+	c.validate(v, &l)
+	return l.err()
+}
+
+func (c *AllConstraint) validate(v interface{}, l *errorList) {
 	for _, celem := range c.constraints {
-		if err := celem.Validate(v); err != nil {
-			return err
+		if l.check(celem, v) {
+			return
 		}
 	}
-	return nil
 }
 
 // OneOf creates a new OneOfConstraint
@@ -119,17 +127,71 @@ func (c *OneOfConstraint) Validate(v interface{}) (err error) {
 		defer g.End()
 	}
 
+	l := errorList{}
+	c.validate(v, &l)
+	return l.err()
+}
+
+func (c *OneOfConstraint) validate(v interface{}, l *errorList) {
 	count := 0
 	for _, celem := range c.constraints {
-		if err := celem.Validate(v); err == nil {
+		if l.try(celem, v) == nil {
 			count++
 		}
 	}
 
 	if count == 0 {
-		return errors.New("none of the constraints passed")
+		l.add(newValidationError("oneOf", nil, v, "none of the constraints passed"))
 	} else if count > 1 {
-		return errors.New("more than 1 of the constraints passed")
+		l.add(newValidationError("oneOf", nil, v, "more than 1 of the constraints passed"))
+	}
+}
+
+// If creates a new IfThenElseConstraint, using c as the condition
+func If(c Constraint) *IfThenElseConstraint {
+	return &IfThenElseConstraint{cond: c}
+}
+
+// Then specifies the constraint that the value must pass when
+// it passes the condition
+func (c *IfThenElseConstraint) Then(c2 Constraint) *IfThenElseConstraint {
+	c.then = c2
+	return c
+}
+
+// Else specifies the constraint that the value must pass when
+// it fails the condition
+func (c *IfThenElseConstraint) Else(c2 Constraint) *IfThenElseConstraint {
+	c.els = c2
+	return c
+}
+
+// Validate validates the value against the "then" constraint if
+// the value passes the condition, or against the "else" constraint
+// if it does not.
+func (c *IfThenElseConstraint) Validate(v interface{}) (err error) {
+	if pdebug.Enabled {
+		g := pdebug.Marker("IfThenElseConstraint.Validate").BindError(&err)
+		defer g.End()
+	}
+
+	l := errorList{}
+	c.validate(v, &l)
+	return l.err()
+}
+
+func (c *IfThenElseConstraint) validate(v interface{}, l *errorList) {
+	if c.cond == nil {
+		return
+	}
+
+	next := c.els
+	if l.try(c.cond, v) == nil {
+		next = c.then
+	}
+
+	if next == nil {
+		return
 	}
-	return nil // Yes!
+	l.check(next, v)
 }
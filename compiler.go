@@ -0,0 +1,810 @@
+package jsval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compiler is responsible for generating Go code that validates
+// values against a validator. Unlike the code generated by Generator,
+// which sets up the same constraints that the validator is made of,
+// the code generated by Compiler checks the values directly: objects
+// decoded into `map[string]interface{}` (and the types generated with
+// `EmitTypes`) are checked property by property, regular expressions
+// are compiled once, and numbers and strings are checked without
+// reflection.
+//
+// Constraints that are not compiled (e.g. `oneOf`, `format`, or
+// `patternProperties`) are set up and called in the same way as the
+// code generated by Generator. Values that are not decoded from JSON
+// (e.g. arbitrary structs) are converted using `JSONValue`. Unlike
+// validators built at runtime, compiled validators stop at the first
+// error, even if called through `ValidateAll`, and default values are
+// only set in maps and in the generated types.
+type Compiler struct {
+	alias   string
+	pkgname string
+	prefix  string
+	types   bool
+}
+
+// NewCompiler creates a new Compiler
+func NewCompiler() *Compiler {
+	return &Compiler{
+		pkgname: "main",
+	}
+}
+
+// Package sets the name of the package for the generated code.
+// The default is "main"
+func (c *Compiler) Package(s string) *Compiler {
+	c.pkgname = s
+	return c
+}
+
+// ImportAlias sets the name used to refer to the jsval package from
+// the generated code. The default is "jsval"
+func (c *Compiler) ImportAlias(s string) *Compiler {
+	c.alias = s
+	return c
+}
+
+// Prefix specifies a prefix for the names of the functions and the
+// variables that the generated code declares (e.g. "Order" turns
+// `jsvalCheck0` into `jsvalOrderCheck0`), so that the code compiled
+// from several schemas can live in the same package. Validators that
+// have no name are named `<prefix>0`, `<prefix>1`, ...
+func (c *Compiler) Prefix(s string) *Compiler {
+	c.prefix = s
+	return c
+}
+
+// EmitTypes specifies if Go types should be generated along with
+// the validators (see `Generator.EmitTypes`). Values of these types
+// are validated without reflection.
+func (c *Compiler) EmitTypes(b bool) *Compiler {
+	c.types = b
+	return c
+}
+
+const jsvalImportPath = "github.com/lestrrat-go/jsval"
+
+// compctx holds the state while a set of validators is compiled
+type compctx struct {
+	gen       genctx
+	prefix    string // prefix of the names of the helpers
+	tc        *typectx
+	typenames map[string]*gentype
+	structs   map[Constraint][]*gentype
+	slices    map[Constraint][]string
+	funcs     map[Constraint]string
+	queue     []Constraint
+	reffuncs  map[string]string // functions that follow references
+	fallbacks map[Constraint]string
+	fblist    []Constraint
+	regexps   map[string]string
+	rxlist    []string
+	imports   map[string]struct{}
+}
+
+// Process takes validators and prints out the Go code that validates
+// values against them to out. The output is a complete Go source file.
+func (c *Compiler) Process(out io.Writer, validators ...*JSVal) error {
+	ctx := compctx{
+		gen: genctx{
+			hoisted:  make(map[Constraint]string),
+			pkgname:  "jsval",
+			refnames: make(map[string]string),
+			vname:    "V",
+		},
+		prefix:    "jsval" + c.prefix,
+		typenames: make(map[string]*gentype),
+		structs:   make(map[Constraint][]*gentype),
+		slices:    make(map[Constraint][]string),
+		funcs:     make(map[Constraint]string),
+		reffuncs:  make(map[string]string),
+		fallbacks: make(map[Constraint]string),
+		regexps:   make(map[string]string),
+		imports:   make(map[string]struct{}),
+	}
+	if c.alias != "" {
+		ctx.gen.pkgname = c.alias
+	}
+
+	refs := map[string]Constraint{}
+	refnames := []string{}
+	for i, v := range validators {
+		for rname, rc := range v.refs {
+			if _, ok := refs[rname]; ok {
+				continue
+			}
+			refs[rname] = rc
+			refnames = append(refnames, rname)
+		}
+
+		if v.Name == "" {
+			v.Name = validatorName(c.prefix, i)
+		}
+	}
+	sort.Strings(refnames)
+	sort.Sort(JSValSlice(validators))
+
+	ctx.gen.refs = refs
+	ctx.gen.refnamelist = refnames
+	if len(refs) > 0 {
+		ctx.gen.cmname = ctx.prefix + "M"
+	}
+
+	if c.types {
+		ctx.declareTypes(validators, refnames)
+	}
+
+	rootfns := make([]string, len(validators))
+	for i, v := range validators {
+		rootfns[i] = ctx.compile(v.root)
+	}
+	reffns := make([]string, len(refnames))
+	for i, rname := range refnames {
+		reffns[i] = ctx.compile(refs[rname])
+	}
+
+	var fbuf bytes.Buffer
+	for len(ctx.queue) > 0 {
+		cc := ctx.queue[0]
+		ctx.queue = ctx.queue[1:]
+		if err := ctx.compileFunc(&fbuf, ctx.funcs[cc], cc); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsval. DO NOT EDIT.")
+	fmt.Fprintf(&buf, "\n\npackage %s", c.pkgname)
+	imports := make([]string, 0, len(ctx.imports))
+	for path := range ctx.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	fmt.Fprintf(&buf, "\n\nimport (")
+	for _, path := range imports {
+		if path == jsvalImportPath {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n%s", strconv.Quote(path))
+	}
+	fmt.Fprintf(&buf, "\n\n")
+	if ctx.gen.pkgname != "jsval" {
+		fmt.Fprintf(&buf, "%s ", ctx.gen.pkgname)
+	}
+	fmt.Fprintf(&buf, "%s", strconv.Quote(jsvalImportPath))
+	fmt.Fprintf(&buf, "\n)")
+
+	if ctx.tc != nil {
+		ctx.tc.generate(&buf)
+	}
+
+	fmt.Fprintf(&buf, "\n")
+	for _, v := range validators {
+		fmt.Fprintf(&buf, "\nvar %s *%s.JSVal", v.Name, ctx.gen.pkgname)
+	}
+	if cmname := ctx.gen.cmname; cmname != "" {
+		fmt.Fprintf(&buf, "\nvar %s *%s.ConstraintMap", cmname, ctx.gen.pkgname)
+	}
+	for _, fc := range ctx.fblist {
+		fmt.Fprintf(&buf, "\nvar %s %s.Constraint", ctx.fallbacks[fc], ctx.gen.pkgname)
+	}
+	for _, rx := range ctx.rxlist {
+		fmt.Fprintf(&buf, "\nvar %s = regexp.MustCompile(%s)", ctx.regexps[rx], strconv.Quote(rx))
+	}
+
+	fmt.Fprintf(&buf, "\n\nfunc init() {")
+	if cmname := ctx.gen.cmname; cmname != "" {
+		fmt.Fprintf(&buf, "\n%s = &%s.ConstraintMap{}", cmname, ctx.gen.pkgname)
+		for i, rname := range refnames {
+			fmt.Fprintf(&buf, "\n%s.SetReference(%s, %s.ConstraintFunc(%s))", cmname, strconv.Quote(rname), ctx.gen.pkgname, reffns[i])
+		}
+	}
+	for _, fc := range ctx.fblist {
+		fmt.Fprintf(&buf, "\n%s = ", ctx.fallbacks[fc])
+		if err := generateCode(&ctx.gen, &buf, fc); err != nil {
+			return err
+		}
+	}
+	for i, v := range validators {
+		fmt.Fprintf(&buf, "\n%s = %s.New().", v.Name, ctx.gen.pkgname)
+		fmt.Fprintf(&buf, "\nSetName(%s).", strconv.Quote(v.Name))
+		if cmname := ctx.gen.cmname; cmname != "" {
+			fmt.Fprintf(&buf, "\nSetConstraintMap(%s).", cmname)
+		}
+		fmt.Fprintf(&buf, "\nSetRoot(%s.ConstraintFunc(%s))", ctx.gen.pkgname, rootfns[i])
+	}
+	fmt.Fprintf(&buf, "\n}")
+	fbuf.WriteTo(&buf)
+
+	fsrc, err := format.Source(buf.Bytes())
+	if err != nil {
+		os.Stderr.Write(buf.Bytes())
+		return err
+	}
+	out.Write(fsrc)
+	return nil
+}
+
+// declareTypes generates the Go types for the validators, and
+// records which of the compiled functions should handle them
+func (ctx *compctx) declareTypes(validators []*JSVal, refnames []string) {
+	tc := newTypectx(&ctx.gen)
+	ctx.tc = tc
+
+	roots := map[string]struct{}{}
+	for _, v := range validators {
+		tc.reserve(v.Name)
+		if name := tc.declare(v.root, v.Name+"Type", v.Name); name != "" {
+			roots[name] = struct{}{}
+		}
+	}
+	defs := map[string]Constraint{}
+	for _, rname := range refnames {
+		rc := ctx.gen.refs[rname]
+		if name := tc.declare(rc, refTypeName(rname), ""); name != "" {
+			tc.refnames[rname] = name
+			if _, ok := defs[name]; !ok {
+				defs[name] = rc
+			}
+		}
+	}
+	tc.build()
+
+	for _, t := range tc.types {
+		ctx.typenames[t.name] = t
+	}
+
+	for _, t := range tc.types {
+		if t.base == "" {
+			ctx.structs[t.c] = append(ctx.structs[t.c], t)
+			for _, f := range t.fields {
+				if strings.HasPrefix(f.typ, "[]") {
+					ctx.addSlice(f.c, f.typ)
+				}
+			}
+		} else if strings.HasPrefix(t.base, "[]") {
+			ctx.addSlice(t.c, t.base)
+		}
+
+		// Validate() of the types for the validators go through
+		// the validator, so that the errors are reported the same way
+		if _, ok := roots[t.name]; ok {
+			continue
+		}
+		if rc, ok := defs[t.name]; ok {
+			t.call = ctx.compile(rc)
+		} else {
+			t.call = ctx.compile(t.c)
+		}
+		t.arg = "v"
+		if t.base != "" {
+			t.arg = t.base + "(*v)"
+		}
+		t.arg += ", " + ctx.gen.pkgname + ".ReferenceDepth{}"
+	}
+}
+
+// addSlice records that the array constraint c may be given Go
+// slices of type typ
+func (ctx *compctx) addSlice(c Constraint, typ string) {
+	ac, ok := ctx.resolve(c).(*ArrayConstraint)
+	if !ok || typ == "[]interface{}" {
+		return
+	}
+	for _, s := range ctx.slices[ac] {
+		if s == typ {
+			return
+		}
+	}
+	ctx.slices[ac] = append(ctx.slices[ac], typ)
+}
+
+// resolve returns the constraint that determines the Go type of
+// the values matching c, following references
+func (ctx *compctx) resolve(c Constraint) Constraint {
+	c = typedConstraint(c)
+	for i := 0; i < len(ctx.gen.refs); i++ {
+		rc, ok := c.(*ReferenceConstraint)
+		if !ok {
+			break
+		}
+		c = typedConstraint(ctx.gen.refs[rc.reference])
+	}
+	return c
+}
+
+// compile returns the name of the function that validates values
+// against c. The function is generated later. If c can not be
+// compiled, the function calls the Validate method of the constraint
+// itself. References are followed by functions of their own, which
+// keep track of the depth (see ReferenceDepth), so that data that
+// refers to itself can not exhaust the stack
+func (ctx *compctx) compile(c Constraint) string {
+	if rc, ok := c.(*ReferenceConstraint); ok {
+		if name, ok := ctx.reffuncs[rc.reference]; ok {
+			return name
+		}
+		name := ctx.queueFunc(c)
+		ctx.reffuncs[rc.reference] = name
+		return name
+	}
+
+	if name, ok := ctx.funcs[c]; ok {
+		return name
+	}
+	return ctx.queueFunc(c)
+}
+
+func (ctx *compctx) queueFunc(c Constraint) string {
+	name := fmt.Sprintf("%sCheck%d", ctx.prefix, len(ctx.funcs))
+	ctx.funcs[c] = name
+	ctx.queue = append(ctx.queue, c)
+	return name
+}
+
+// compilable reports whether validating values against c can be
+// done by the compiled code
+func compilable(c Constraint) bool {
+	switch c := c.(type) {
+	case emptyConstraint, nullConstraint, *AllConstraint, *BooleanConstraint:
+		return true
+	case *StringConstraint:
+		return c.format == ""
+	case *NumberConstraint:
+		return compilableNumber(c)
+	case *IntegerConstraint:
+		return compilableNumber(&c.NumberConstraint)
+	case *EnumConstraint:
+		return len(stringEnums(c)) > 0
+	case *ArrayConstraint:
+		return len(c.positionalItems) == 0 && c.contains == nil && !c.uniqueItems &&
+			c.unevaluatedItems == nil && len(c.siblings) == 0
+	case *ObjectConstraint:
+		return len(c.patternProperties) == 0 && c.propertyNames == nil &&
+			len(c.propdeps) == 0 && len(c.schemadeps) == 0 &&
+			c.unevaluatedProperties == nil && len(c.siblings) == 0 &&
+			c.structInfo == nil && c.FieldNameFromName == nil && c.FieldNamesFromStruct == nil
+	}
+	return false
+}
+
+// compilableNumber reports whether the bounds of c can be checked by
+// comparing float64 values. Numbers given as decimal literals, and
+// multipleOf are checked using exact arithmetic instead.
+func compilableNumber(c *NumberConstraint) bool {
+	if c.enums != nil || c.applyMultipleOf {
+		return false
+	}
+	for _, b := range []struct {
+		apply bool
+		d     decimal
+	}{{c.applyMinimum, c.minimum}, {c.applyMaximum, c.maximum}} {
+		if b.apply && (b.d.exact || b.d.rat == nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// fallback returns the name of the variable that holds a copy of c
+func (ctx *compctx) fallback(c Constraint) string {
+	if name, ok := ctx.fallbacks[c]; ok {
+		return name
+	}
+	name := fmt.Sprintf("%sC%d", ctx.prefix, len(ctx.fblist))
+	ctx.fallbacks[c] = name
+	ctx.fblist = append(ctx.fblist, c)
+	return name
+}
+
+func (ctx *compctx) regexp(rx string) string {
+	if name, ok := ctx.regexps[rx]; ok {
+		return name
+	}
+	ctx.imports["regexp"] = struct{}{}
+	name := fmt.Sprintf("%sRx%d", ctx.prefix, len(ctx.rxlist))
+	ctx.regexps[rx] = name
+	ctx.rxlist = append(ctx.rxlist, rx)
+	return name
+}
+
+func (ctx *compctx) compileFunc(out io.Writer, name string, c Constraint) error {
+	fmt.Fprintf(out, "\n\nfunc %s(v interface{}, depth %s.ReferenceDepth) error {", name, ctx.gen.pkgname)
+
+	var err error
+	switch c := c.(type) {
+	case *ReferenceConstraint:
+		target, ok := ctx.gen.refs[c.reference]
+		if !ok {
+			fmt.Fprintf(out, "\nreturn depth.Validate(%s, v)", ctx.fallback(c))
+			break
+		}
+		fmt.Fprintf(out, "\ndepth, err := depth.Follow(%s)", strconv.Quote(c.reference))
+		fmt.Fprintf(out, "\nif err != nil {\nreturn err\n}")
+		fmt.Fprintf(out, "\nreturn %s(v, depth)", ctx.compile(target))
+	case Constraint:
+		if !compilable(c) {
+			fmt.Fprintf(out, "\nreturn depth.Validate(%s, v)", ctx.fallback(c))
+			break
+		}
+		err = ctx.compileBody(out, name, c)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\n}")
+	return nil
+}
+
+// compileBody generates the body of the function name, which checks
+// values against c
+func (ctx *compctx) compileBody(out io.Writer, name string, c Constraint) error {
+	var err error
+	switch c := c.(type) {
+	case emptyConstraint:
+		fmt.Fprintf(out, "\nreturn nil")
+	case nullConstraint:
+		fmt.Fprintf(out, "\nif v == nil {\nreturn nil\n}")
+		fmt.Fprintf(out, "\nreturn %s.Validate(v)", ctx.fallback(c))
+	case *BooleanConstraint:
+		fmt.Fprintf(out, "\nif _, ok := v.(bool); ok {\nreturn nil\n}")
+		fmt.Fprintf(out, "\nreturn %s.Validate(v)", ctx.fallback(c))
+	case *StringConstraint:
+		ctx.compileString(out, c)
+	case *NumberConstraint:
+		ctx.compileNumber(out, c, c, false)
+	case *IntegerConstraint:
+		ctx.compileNumber(out, c, &c.NumberConstraint, true)
+	case *EnumConstraint:
+		fmt.Fprintf(out, "\nif s, ok := v.(string); ok {")
+		compileStringEnum(out, stringEnums(c), "return nil")
+		fmt.Fprintf(out, "\n}")
+		fmt.Fprintf(out, "\nreturn %s.Validate(v)", ctx.fallback(c))
+	case *AllConstraint:
+		for _, c1 := range c.constraints {
+			fmt.Fprintf(out, "\nif err := %s(v, depth); err != nil {\nreturn err\n}", ctx.compile(c1))
+		}
+		fmt.Fprintf(out, "\nreturn nil")
+	case *ArrayConstraint:
+		ctx.compileArray(out, name, c)
+	case *ObjectConstraint:
+		err = ctx.compileObject(out, name, c)
+	default:
+		return errors.New("failed to compile constraint of type " + fmt.Sprintf("%T", c))
+	}
+	return err
+}
+
+// compileStringEnum generates a switch statement that runs stmt if
+// the string s is one of l
+func compileStringEnum(out io.Writer, l []string, stmt string) {
+	fmt.Fprintf(out, "\nswitch s {\ncase ")
+	for i, e := range l {
+		if i > 0 {
+			fmt.Fprintf(out, ", ")
+		}
+		fmt.Fprint(out, strconv.Quote(e))
+	}
+	fmt.Fprintf(out, ":\n%s\n}", stmt)
+}
+
+// The compiled checks for strings and numbers only decide whether the
+// value is valid. If it is not, the constraint itself is called, so
+// that the errors are the same as the ones reported by the constraint
+func (ctx *compctx) compileString(out io.Writer, c *StringConstraint) {
+	fb := ctx.fallback(c)
+	fail := "return " + fb + ".Validate(v)"
+
+	if c.minLength <= 0 && c.maxLength < 0 && c.regexp == nil && c.enums == nil {
+		fmt.Fprintf(out, "\nif _, ok := v.(string); ok {\nreturn nil\n}")
+		fmt.Fprintf(out, "\n%s", fail)
+		return
+	}
+
+	fmt.Fprintf(out, "\ns, ok := v.(string)")
+	fmt.Fprintf(out, "\nif !ok {\n%s\n}", fail)
+	if c.minLength > 0 || c.maxLength > -1 {
+		ctx.imports["unicode/utf8"] = struct{}{}
+		var conds []string
+		if c.minLength > 0 {
+			conds = append(conds, fmt.Sprintf("n < %d", c.minLength))
+		}
+		if c.maxLength > -1 {
+			conds = append(conds, fmt.Sprintf("n > %d", c.maxLength))
+		}
+		fmt.Fprintf(out, "\nif n := utf8.RuneCountInString(s); %s {\n%s\n}", strings.Join(conds, " || "), fail)
+	}
+	if rx := c.regexp; rx != nil {
+		fmt.Fprintf(out, "\nif !%s.MatchString(s) {\n%s\n}", ctx.regexp(rx.String()), fail)
+	}
+	if enum := c.enums; enum != nil {
+		compileStringEnum(out, stringEnums(enum), "return nil")
+		fmt.Fprintf(out, "\n%s", fail)
+		return
+	}
+	fmt.Fprintf(out, "\nreturn nil")
+}
+
+func (ctx *compctx) compileNumber(out io.Writer, self Constraint, c *NumberConstraint, integer bool) {
+	fail := "return " + ctx.fallback(self) + ".Validate(v)"
+
+	if !integer && !c.applyMinimum && !c.applyMaximum {
+		ctx.imports["math"] = struct{}{}
+		fmt.Fprintf(out, "\nswitch n := v.(type) {\ncase int64:\nreturn nil")
+		fmt.Fprintf(out, "\ncase float64:\nif !math.IsNaN(n) && !math.IsInf(n, 0) {\nreturn nil\n}\n}")
+		fmt.Fprintf(out, "\n%s", fail)
+		return
+	}
+
+	// Integers are compared as float64 values, as long as they
+	// can be converted without losing precision
+	fmt.Fprintf(out, "\nvar f float64")
+	fmt.Fprintf(out, "\nswitch n := v.(type) {")
+	fmt.Fprintf(out, "\ncase float64:\nf = n")
+	fmt.Fprintf(out, "\ncase int64:")
+	fmt.Fprintf(out, "\nif n > 1<<53 || n < -(1<<53) {\n%s\n}", fail)
+	fmt.Fprintf(out, "\nf = float64(n)")
+	fmt.Fprintf(out, "\ndefault:\n%s\n}", fail)
+	ctx.imports["math"] = struct{}{}
+	if integer {
+		fmt.Fprintf(out, "\nif f != math.Trunc(f) || math.IsInf(f, 0) {\n%s\n}", fail)
+	} else {
+		fmt.Fprintf(out, "\nif math.IsNaN(f) || math.IsInf(f, 0) {\n%s\n}", fail)
+	}
+	if c.applyMinimum {
+		op := "<"
+		if c.exclusiveMinimum {
+			op = "<="
+		}
+		fmt.Fprintf(out, "\nif f %s %s {\n%s\n}", op, c.minimum.literal, fail)
+	}
+	if c.applyMaximum {
+		op := ">"
+		if c.exclusiveMaximum {
+			op = ">="
+		}
+		fmt.Fprintf(out, "\nif f %s %s {\n%s\n}", op, c.maximum.literal, fail)
+	}
+	fmt.Fprintf(out, "\nreturn nil")
+}
+
+func (ctx *compctx) compileArray(out io.Writer, name string, c *ArrayConstraint) {
+	var items string
+	if c.items != nil && c.items != Constraint(EmptyConstraint) {
+		items = ctx.compile(c.items)
+	}
+
+	fmt.Fprintf(out, "\nswitch x := v.(type) {")
+	for _, typ := range append([]string{"[]interface{}"}, ctx.slices[c]...) {
+		fmt.Fprintf(out, "\ncase %s:", typ)
+		fmt.Fprintf(out, "\nif x == nil {\nbreak\n}")
+		if mi := c.minItems; mi > -1 {
+			fmt.Fprintf(out, "\nif len(x) < %d {", mi)
+			fmt.Fprintf(out, "\nreturn %s.NewValidationError(\"minItems\", %d, len(x), \"fewer items than minItems\")\n}", ctx.gen.pkgname, mi)
+		}
+		if mi := c.maxItems; mi > -1 {
+			fmt.Fprintf(out, "\nif len(x) > %d {", mi)
+			fmt.Fprintf(out, "\nreturn %s.NewValidationError(\"maxItems\", %d, len(x), \"more items than maxItems\")\n}", ctx.gen.pkgname, mi)
+		}
+		if items != "" {
+			ctx.imports["strconv"] = struct{}{}
+			fmt.Fprintf(out, "\nfor i := range x {")
+			fmt.Fprintf(out, "\n%s\n}", ctx.checkCode(items, typ[2:], "x[i]", "strconv.Itoa(i)"))
+		}
+		fmt.Fprintf(out, "\nreturn nil")
+	}
+	fmt.Fprintf(out, "\n}")
+	fmt.Fprintf(out, "\nif l, ok := %s.JSONValue(v).([]interface{}); ok {", ctx.gen.pkgname)
+	fmt.Fprintf(out, "\nreturn %s(l, depth)\n}", name)
+	fmt.Fprintf(out, "\nreturn %s.Array().Validate(v)", ctx.gen.pkgname)
+}
+
+func (ctx *compctx) compileObject(out io.Writer, name string, c *ObjectConstraint) error {
+	pnames := make([]string, 0, len(c.properties))
+	for pname := range c.properties {
+		pnames = append(pnames, pname)
+	}
+	sort.Strings(pnames)
+
+	// Required properties that do not have a constraint of their own
+	var reqnames []string
+	for pname := range c.required {
+		if _, ok := c.properties[pname]; !ok {
+			reqnames = append(reqnames, pname)
+		}
+	}
+	sort.Strings(reqnames)
+
+	pkg := ctx.gen.pkgname
+
+	fmt.Fprintf(out, "\nswitch x := v.(type) {")
+	fmt.Fprintf(out, "\ncase map[string]interface{}:")
+	fmt.Fprintf(out, "\nif x == nil {\nbreak\n}")
+	if n := c.minProperties; n > -1 {
+		fmt.Fprintf(out, "\nif len(x) < %d {", n)
+		fmt.Fprintf(out, "\nreturn %s.NewValidationError(\"minProperties\", %d, len(x), \"fewer properties than minProperties\")\n}", pkg, n)
+	}
+	if n := c.maxProperties; n > -1 {
+		fmt.Fprintf(out, "\nif len(x) > %d {", n)
+		fmt.Fprintf(out, "\nreturn %s.NewValidationError(\"maxProperties\", %d, len(x), \"more properties than maxProperties\")\n}", pkg, n)
+	}
+	for _, pname := range pnames {
+		pc := c.properties[pname]
+		qname := strconv.Quote(pname)
+		fmt.Fprintf(out, "\nif pv, ok := x[%s]; ok {", qname)
+		fmt.Fprintf(out, "\nif err := %s(pv, depth); err != nil {", ctx.compile(pc))
+		fmt.Fprintf(out, "\nreturn %s.ErrorAt(err, %s)\n}", pkg, qname)
+		switch {
+		case c.IsPropRequired(pname):
+			fmt.Fprintf(out, "\n} else {\n%s", ctx.requiredError(pname))
+		case pc.HasDefault():
+			fmt.Fprintf(out, "\n} else {\nx[%s] = ", qname)
+			if err := generateValueCode(out, pc.DefaultValue()); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "\n}")
+	}
+	for _, pname := range reqnames {
+		fmt.Fprintf(out, "\nif _, ok := x[%s]; !ok {\n%s\n}", strconv.Quote(pname), ctx.requiredError(pname))
+	}
+	if ap := c.additionalProperties; ap != Constraint(EmptyConstraint) {
+		ctx.imports["sort"] = struct{}{}
+		fmt.Fprintf(out, "\nvar extra []string")
+		fmt.Fprintf(out, "\nfor pname := range x {")
+		if len(pnames) > 0 {
+			fmt.Fprintf(out, "\nswitch pname {\ncase ")
+			for i, pname := range pnames {
+				if i > 0 {
+					fmt.Fprintf(out, ", ")
+				}
+				fmt.Fprint(out, strconv.Quote(pname))
+			}
+			fmt.Fprintf(out, ":\ncontinue\n}")
+		}
+		fmt.Fprintf(out, "\nextra = append(extra, pname)\n}")
+		fmt.Fprintf(out, "\nsort.Strings(extra)")
+		if ap == nil {
+			fmt.Fprintf(out, "\nif len(extra) > 0 {")
+			fmt.Fprintf(out, "\nreturn %s.ErrorAt(%s.NewValidationError(\"additionalProperties\", false, x[extra[0]], \"additional properties are not allowed\"), extra[0])\n}", pkg, pkg)
+		} else {
+			fmt.Fprintf(out, "\nfor _, pname := range extra {")
+			fmt.Fprintf(out, "\nif err := %s(x[pname], depth); err != nil {", ctx.compile(ap))
+			fmt.Fprintf(out, "\nreturn %s.ErrorAt(err, pname)\n}\n}", pkg)
+		}
+	}
+	fmt.Fprintf(out, "\nreturn nil")
+
+	// Structs have the same properties no matter what, so the number
+	// of properties can't be checked without looking at each field
+	if c.minProperties < 0 && c.maxProperties < 0 {
+		for _, t := range ctx.structs[c] {
+			if err := ctx.compileStruct(out, t, c, reqnames); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(out, "\n}")
+
+	fmt.Fprintf(out, "\nif m, ok := %s.JSONValue(v).(map[string]interface{}); ok {", pkg)
+	fmt.Fprintf(out, "\nreturn %s(m, depth)\n}", name)
+	fmt.Fprintf(out, "\nreturn %s.Object().Validate(v)", pkg)
+	return nil
+}
+
+func (ctx *compctx) compileStruct(out io.Writer, t *gentype, c *ObjectConstraint, reqnames []string) error {
+	pkg := ctx.gen.pkgname
+
+	fmt.Fprintf(out, "\ncase *%s:", t.name)
+	fmt.Fprintf(out, "\nif x == nil {\nbreak\n}")
+	for _, f := range t.fields {
+		fexpr := "x." + f.name
+		qname := strconv.Quote(f.pname)
+
+		var present string
+		typ, vexpr := f.typ, fexpr
+		switch {
+		case strings.HasPrefix(f.typ, pkg+".Maybe"):
+			present = fexpr + ".Valid()"
+			typ, vexpr = "", fexpr+".Value()"
+		case strings.HasPrefix(f.typ, "*"):
+			present = fexpr + " != nil"
+		case f.omitempty && f.typ == "interface{}":
+			present = fexpr + " != nil"
+		case f.omitempty:
+			present = "len(" + fexpr + ") != 0"
+		}
+
+		check := ctx.checkCode(ctx.compile(f.c), typ, vexpr, qname)
+		if present == "" {
+			fmt.Fprintf(out, "\n%s", check)
+			continue
+		}
+
+		fmt.Fprintf(out, "\nif %s {\n%s", present, check)
+		switch {
+		case c.IsPropRequired(f.pname):
+			fmt.Fprintf(out, "\n} else {\n%s", ctx.requiredError(f.pname))
+		case f.c.HasDefault():
+			ctx.imports["errors"] = struct{}{}
+			fmt.Fprintf(out, "\n} else if err := %s.AssignValue(&%s, ", pkg, fexpr)
+			if err := generateValueCode(out, f.c.DefaultValue()); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "); err != nil {")
+			fmt.Fprintf(out, "\nreturn errors.New(%s + err.Error())", strconv.Quote("failed to set default value for property '"+f.pname+"': "))
+		}
+		fmt.Fprintf(out, "\n}")
+	}
+
+	// The generated types have no fields for these, so they are
+	// always missing. Only the first one is reported
+	if len(reqnames) > 0 {
+		fmt.Fprintf(out, "\n%s", ctx.requiredError(reqnames[0]))
+		return nil
+	}
+	fmt.Fprintf(out, "\nreturn nil")
+	return nil
+}
+
+// requiredError returns the statement that reports that the
+// property pname is missing
+func (ctx *compctx) requiredError(pname string) string {
+	return fmt.Sprintf("return %s.NewValidationError(\"required\", %s, v, %s)", ctx.gen.pkgname, strconv.Quote(pname), strconv.Quote("object property '"+pname+"' is required"))
+}
+
+// valueExpr returns the expression that passes expr, a value of the
+// Go type typ, to a compiled function. Structs are passed by pointer,
+// so that default values can be set, and named types are converted
+// to their underlying types so that they are checked without
+// reflection.
+func (ctx *compctx) valueExpr(typ, expr string) string {
+	ptr := strings.HasPrefix(typ, "*")
+	t, ok := ctx.typenames[strings.TrimPrefix(typ, "*")]
+	switch {
+	case !ok:
+		if ptr {
+			return "*" + expr
+		}
+		return expr
+	case t.base == "":
+		if ptr {
+			return expr
+		}
+		return "&" + expr
+	case ptr:
+		return t.base + "(*" + expr + ")"
+	}
+	return t.base + "(" + expr + ")"
+}
+
+// checkCode returns the code that checks expr, a value of the Go type
+// typ, using the compiled function fn. Errors are reported at token.
+// Values of named types are checked again as they are when the check
+// fails, so that the error holds the same value as it would at runtime
+func (ctx *compctx) checkCode(fn, typ, expr, token string) string {
+	pkg := ctx.gen.pkgname
+	vexpr := ctx.valueExpr(typ, expr)
+	errexpr := "err"
+	if t, ok := ctx.typenames[strings.TrimPrefix(typ, "*")]; ok && t.base != "" {
+		orig := expr
+		if strings.HasPrefix(typ, "*") {
+			orig = "*" + expr
+		}
+		errexpr = fn + "(" + orig + ", depth)"
+	}
+	return fmt.Sprintf("if err := %s(%s, depth); err != nil {\nreturn %s.ErrorAt(%s, %s)\n}", fn, vexpr, pkg, errexpr, token)
+}
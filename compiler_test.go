@@ -0,0 +1,325 @@
+package jsval_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// compileTestInputs returns functions that create fresh inputs, as
+// validating may set default values. Invalid inputs specify priority,
+// because the builder checks properties in no particular order, and
+// may or may not set its default before the error is found
+func compileTestInputs() map[string]func() interface{} {
+	return map[string]func() interface{}{
+		"Valid": func() interface{} {
+			return map[string]interface{}{
+				"id":       "order-1",
+				"status":   "pending",
+				"customer": map[string]interface{}{"name": "John", "email": "john@example.com"},
+				"items": []interface{}{
+					map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)},
+					map[string]interface{}{"sku": "XYZ-1234", "quantity": float64(1), "note": "gift wrap"},
+				},
+				"tags":     []interface{}{"a", "b"},
+				"gift":     true,
+				"weight":   2.5,
+				"discount": float64(-1),
+			}
+		},
+		"MissingID": func() interface{} {
+			return map[string]interface{}{
+				"status":   "pending",
+				"priority": float64(2),
+				"items":    []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+			}
+		},
+		"BadStatus": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "lost",
+				"items":  []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+			}
+		},
+		"BadSKU": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "shipped",
+				"items": []interface{}{
+					map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)},
+					map[string]interface{}{"sku": "abc", "quantity": float64(2)},
+				},
+			}
+		},
+		"BadQuantity": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "shipped",
+				"items":  []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": 1.5}},
+			}
+		},
+		"ExtraProperty": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "shipped",
+				"items":  []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2), "price": float64(1)}},
+			}
+		},
+		"NoItems": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "shipped",
+				"items":  []interface{}{},
+			}
+		},
+		"TooManyTags": func() interface{} {
+			return map[string]interface{}{
+				"id":     "order-1",
+				"status": "shipped",
+				"items":  []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+				"tags":   []interface{}{"a", "b", "c", "d", "e", "f"},
+			}
+		},
+		"BadEmail": func() interface{} {
+			return map[string]interface{}{
+				"id":       "order-1",
+				"status":   "shipped",
+				"customer": map[string]interface{}{"name": "John", "email": "john"},
+				"items":    []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+			}
+		},
+		"InfiniteWeight": func() interface{} {
+			return map[string]interface{}{
+				"id":       "order-1",
+				"status":   "shipped",
+				"items":    []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+				"priority": float64(2),
+				"weight":   math.Inf(-1),
+			}
+		},
+		"InfiniteDiscount": func() interface{} {
+			return map[string]interface{}{
+				"id":       "order-1",
+				"status":   "shipped",
+				"items":    []interface{}{map[string]interface{}{"sku": "ABC-0001", "quantity": float64(2)}},
+				"priority": float64(2),
+				"discount": math.Inf(1),
+			}
+		},
+		"NotAnObject": func() interface{} {
+			return []interface{}{"order-1"}
+		},
+		"Struct": func() interface{} {
+			return &Compiled0Type{
+				ID:     "order-1",
+				Status: Compiled0TypeStatusShipped,
+				Items:  []LineItem{{Sku: "ABC-0001", Quantity: 2}},
+				Tags:   []string{"a"},
+			}
+		},
+		"StructBadQuantity": func() interface{} {
+			return &Compiled0Type{
+				ID:     "order-1",
+				Status: Compiled0TypeStatusShipped,
+				Items:  []LineItem{{Sku: "ABC-0001", Quantity: 2}, {Sku: "ABC-0002", Quantity: 101}},
+			}
+		},
+		"StructEmptyID": func() interface{} {
+			return &Compiled0Type{
+				Status:   Compiled0TypeStatusPending,
+				Items:    []LineItem{{Sku: "ABC-0001", Quantity: 2}},
+				Priority: jsval.MaybeInt{ValidFlag: true, Int: 2},
+			}
+		},
+	}
+}
+
+func buildCompileTestValidator() (*jsval.JSVal, error) {
+	s, err := schema.ReadFile("testdata/compile-schema.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read schema")
+	}
+	v, err := builder.New().Build(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build validator")
+	}
+	return v.SetName("Compiled0"), nil
+}
+
+func TestCompiledValidator(t *testing.T) {
+	v, err := buildCompileTestValidator()
+	if !assert.NoError(t, err, "building validator should succeed") {
+		return
+	}
+
+	for name, input := range compileTestInputs() {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			expected := v.Validate(input())
+			err := Compiled0.Validate(input())
+			if expected == nil {
+				if !assert.NoError(t, err, "compiled validator should succeed") {
+					return
+				}
+				return
+			}
+
+			if !assert.Error(t, err, "compiled validator should fail") {
+				return
+			}
+			if !assert.Equal(t, expected.Error(), err.Error(), "error messages should match") {
+				return
+			}
+			if !assert.Equal(t, errors.Cause(expected), errors.Cause(err), "validation errors should match") {
+				return
+			}
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		m := compileTestInputs()["Valid"]().(map[string]interface{})
+		if !assert.NoError(t, Compiled0.Validate(m), "compiled validator should succeed") {
+			return
+		}
+		if !assert.Equal(t, float64(1), m["priority"], "default value is set") {
+			return
+		}
+
+		o := compileTestInputs()["Struct"]().(*Compiled0Type)
+		if !assert.NoError(t, o.Validate(), "Validate should succeed") {
+			return
+		}
+		if !assert.Equal(t, int64(1), o.Priority.Value(), "default value is set") {
+			return
+		}
+	})
+
+	t.Run("NaN", func(t *testing.T) {
+		// NaN is not equal to itself, so only the messages are compared
+		for _, name := range []string{"weight", "discount"} {
+			m := compileTestInputs()["Valid"]().(map[string]interface{})
+			m[name] = math.NaN()
+			expected := v.Validate(m)
+			if !assert.Error(t, expected, "validator should fail") {
+				return
+			}
+			err := Compiled0.Validate(m)
+			if !assert.Error(t, err, "compiled validator should fail") {
+				return
+			}
+			if !assert.Equal(t, expected.Error(), err.Error(), "error messages should match") {
+				return
+			}
+		}
+	})
+
+	t.Run("Process", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, jsval.NewCompiler().Package("foo").Process(&buf, v), "Compiler.Process should succeed") {
+			return
+		}
+		code := buf.String()
+		for _, s := range []string{
+			"// Code generated by jsval. DO NOT EDIT.",
+			"package foo\n",
+			"var jsvalRx0 = regexp.MustCompile(\"^[A-Z]{3}-[0-9]{4}$\")",
+			"func jsvalCheck0(v interface{}, depth jsval.ReferenceDepth) error {",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, jsval.NewCompiler().Package("foo").Prefix("Order").Process(&buf, v), "Compiler.Process should succeed") {
+			return
+		}
+		code := buf.String()
+		for _, s := range []string{
+			"var jsvalOrderRx0 = regexp.MustCompile(",
+			"var jsvalOrderM *jsval.ConstraintMap",
+			"var jsvalOrderC0 jsval.Constraint",
+			"func jsvalOrderCheck0(v interface{}, depth jsval.ReferenceDepth) error {",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+		if !assert.NotContains(t, code, "jsvalCheck", "helpers are prefixed") {
+			return
+		}
+	})
+}
+
+func TestCompiledReferenceDepth(t *testing.T) {
+	t.Run("Cycle", func(t *testing.T) {
+		m := map[string]interface{}{"name": "loop"}
+		m["next"] = m
+		if !assert.Error(t, Recursive0.Validate(m), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		v := jsval.New().
+			SetConstraintMap(Recursive0.ConstraintMap).
+			SetRoot(Recursive0.Root()).
+			SetMaxReferenceDepth(2)
+
+		m := map[string]interface{}{
+			"next": map[string]interface{}{
+				"next": map[string]interface{}{
+					"next": map[string]interface{}{},
+				},
+			},
+		}
+		if !assert.Error(t, v.Validate(m), "Validate should fail") {
+			return
+		}
+		if !assert.NoError(t, v.Validate(m["next"]), "Validate should succeed") {
+			return
+		}
+		if !assert.NoError(t, Recursive0.Validate(m), "Validate should succeed with the default limit") {
+			return
+		}
+	})
+}
+
+func BenchmarkCompiledValidator(b *testing.B) {
+	v, err := buildCompileTestValidator()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	inputs := compileTestInputs()
+	m := inputs["Valid"]()
+	o := inputs["Struct"]()
+	for _, bc := range []struct {
+		name  string
+		v     *jsval.JSVal
+		input interface{}
+	}{
+		{"Builder/Map", v, m},
+		{"Compiled/Map", Compiled0, m},
+		{"Builder/Struct", v, o},
+		{"Compiled/Struct", Compiled0, o},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bc.v.Validate(bc.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
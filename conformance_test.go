@@ -0,0 +1,251 @@
+package jsval_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+var conformanceReport = flag.String("conformance-report", "", "file to write the JSON-Schema-Test-Suite conformance report to")
+
+const (
+	suiteDir          = "testdata/JSON-Schema-Test-Suite/tests"
+	suiteRemotesDir   = "testdata/JSON-Schema-Test-Suite/remotes"
+	suiteRemotesURI   = "http://localhost:1234/"
+	knownFailuresFile = "testdata/conformance-known-failures.txt"
+)
+
+// suiteLoader loads the documents that the cases refer to: the files
+// under remotes, which the suite expects to be served from
+// http://localhost:1234/, and the draft-04 meta-schema.
+var suiteLoader = builder.LoaderFunc(func(u *url.URL) (interface{}, error) {
+	var fn string
+	switch s := u.String(); {
+	case strings.HasPrefix(s, suiteRemotesURI):
+		fn = filepath.Join(suiteRemotesDir, filepath.FromSlash(strings.TrimPrefix(s, suiteRemotesURI)))
+	case s == "http://json-schema.org/draft-04/schema":
+		fn = "schema.json"
+	default:
+		return nil, fmt.Errorf("%s is not part of the suite", s)
+	}
+
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+})
+
+var suiteDrafts = []struct {
+	Dir   string
+	Draft builder.Draft
+}{
+	{Dir: "draft4", Draft: builder.Draft04},
+	{Dir: "draft4/optional", Draft: builder.Draft04},
+}
+
+type suiteGroup struct {
+	Description string      `json:"description"`
+	Schema      interface{} `json:"schema"`
+	Tests       []suiteCase `json:"tests"`
+}
+
+type suiteCase struct {
+	Description string      `json:"description"`
+	Data        interface{} `json:"data"`
+	Valid       bool        `json:"valid"`
+}
+
+// suiteResult holds the number of cases that passed for a keyword
+type suiteResult struct {
+	Passed int
+	Total  int
+}
+
+// loadKnownFailures reads the IDs of the cases that are known to fail.
+// Each line holds one ID in the form "draft/keyword/group/case".
+// Empty lines and lines starting with "#" are ignored.
+func loadKnownFailures(t *testing.T) map[string]bool {
+	f, err := os.Open(knownFailuresFile)
+	if !assert.NoError(t, err, "opening known failures should succeed") {
+		return nil
+	}
+	defer f.Close()
+
+	known := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		known[line] = true
+	}
+	if !assert.NoError(t, scanner.Err(), "reading known failures should succeed") {
+		return nil
+	}
+	return known
+}
+
+func buildSuiteSchema(draft builder.Draft, src interface{}) (v *jsval.JSVal, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	var m map[string]interface{}
+	switch src.(type) {
+	case bool:
+		if src.(bool) {
+			m = map[string]interface{}{}
+		} else {
+			m = map[string]interface{}{"not": map[string]interface{}{}}
+		}
+	case map[string]interface{}:
+		m = builder.NormalizeSchema(src.(map[string]interface{}))
+	default:
+		return nil, fmt.Errorf("invalid schema: %v", src)
+	}
+
+	s := schema.New()
+	if err := s.Extract(m); err != nil {
+		return nil, err
+	}
+	return builder.New().SetDraft(draft).SetLoader(suiteLoader).BuildWithCtx(s, m)
+}
+
+func validateSuiteCase(v *jsval.JSVal, data interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return v.Validate(data)
+}
+
+// TestJSONSchemaTestSuite runs the cases from the JSON-Schema-Test-Suite
+// (https://github.com/json-schema-org/JSON-Schema-Test-Suite). Cases that
+// are listed in testdata/conformance-known-failures.txt are expected to
+// fail; any other failure, as well as a known failure that starts passing,
+// fails the test. Run with -conformance-report=FILE to get the number of
+// passing cases for each keyword.
+func TestJSONSchemaTestSuite(t *testing.T) {
+	known := loadKnownFailures(t)
+	if known == nil {
+		return
+	}
+
+	results := make(map[string]*suiteResult)
+	seen := make(map[string]bool)
+	for _, d := range suiteDrafts {
+		files, err := filepath.Glob(filepath.Join(suiteDir, d.Dir, "*.json"))
+		if !assert.NoError(t, err, "filepath.Glob should succeed") {
+			return
+		}
+
+		for _, file := range files {
+			keyword := d.Dir + "/" + strings.TrimSuffix(filepath.Base(file), ".json")
+			buf, err := ioutil.ReadFile(file)
+			if !assert.NoError(t, err, "reading %s should succeed", file) {
+				return
+			}
+
+			var groups []suiteGroup
+			if !assert.NoError(t, json.Unmarshal(buf, &groups), "decoding %s should succeed", file) {
+				return
+			}
+
+			result := &suiteResult{}
+			results[keyword] = result
+			for _, g := range groups {
+				v, builderr := buildSuiteSchema(d.Draft, g.Schema)
+				for _, c := range g.Tests {
+					id := keyword + "/" + g.Description + "/" + c.Description
+					seen[id] = true
+					result.Total++
+
+					var failure string
+					if builderr != nil {
+						failure = "failed to build schema: " + builderr.Error()
+					} else {
+						err := validateSuiteCase(v, c.Data)
+						switch {
+						case c.Valid && err != nil:
+							failure = "expected to be valid, got: " + err.Error()
+						case !c.Valid && err == nil:
+							failure = "expected to be invalid"
+						}
+					}
+
+					if failure == "" {
+						result.Passed++
+						if known[id] {
+							t.Errorf("%s: passes now, remove it from %s", id, knownFailuresFile)
+						}
+						continue
+					}
+
+					if !known[id] {
+						t.Errorf("%s: %s", id, failure)
+					}
+				}
+			}
+		}
+	}
+
+	for id := range known {
+		if !seen[id] {
+			t.Errorf("%s: no such case, remove it from %s", id, knownFailuresFile)
+		}
+	}
+
+	report := conformanceReportString(results)
+	if fn := *conformanceReport; fn != "" {
+		if !assert.NoError(t, ioutil.WriteFile(fn, []byte(report), 0644), "writing the report should succeed") {
+			return
+		}
+	}
+	t.Logf("JSON-Schema-Test-Suite conformance:\n%s", report)
+}
+
+func conformanceReportString(results map[string]*suiteResult) string {
+	keywords := make([]string, 0, len(results))
+	for keyword := range results {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	var buf bytes.Buffer
+	var passed, total int
+	for _, keyword := range keywords {
+		r := results[keyword]
+		status := "PASS"
+		if r.Passed < r.Total {
+			status = "PARTIAL"
+		}
+		fmt.Fprintf(&buf, "%-32s %4d/%-4d %s\n", keyword, r.Passed, r.Total, status)
+		passed += r.Passed
+		total += r.Total
+	}
+	fmt.Fprintf(&buf, "%-32s %4d/%-4d\n", "total", passed, total)
+	return buf.String()
+}
@@ -0,0 +1,26 @@
+package jsval
+
+import "github.com/lestrrat-go/pdebug"
+
+// Const creates a new ConstConstraint
+func Const(v interface{}) *ConstConstraint {
+	return &ConstConstraint{value: v}
+}
+
+// Value returns the value that the incoming value must be equal to
+func (c *ConstConstraint) Value() interface{} {
+	return c.value
+}
+
+// Validate validates the value against the constant value
+func (c *ConstConstraint) Validate(v interface{}) (err error) {
+	if pdebug.Enabled {
+		g := pdebug.Marker("ConstConstraint.Validate").BindError(&err)
+		defer g.End()
+	}
+
+	if !equalJSON(c.value, v) {
+		return newValidationError("const", c.value, v, "value does not match the constant value")
+	}
+	return nil
+}
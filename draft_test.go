@@ -0,0 +1,174 @@
+package jsval_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildFromJSON(t *testing.T, src string) (*jsval.JSVal, bool) {
+	var m map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(src), &m), "json.Unmarshal should succeed") {
+		return nil, false
+	}
+	m = builder.NormalizeSchema(m)
+
+	s := schema.New()
+	if !assert.NoError(t, s.Extract(m), "schema.Extract should succeed") {
+		return nil, false
+	}
+
+	v, err := builder.New().BuildWithCtx(s, m)
+	if !assert.NoError(t, err, "Builder.BuildWithCtx should succeed") {
+		return nil, false
+	}
+	return v, true
+}
+
+func TestDraft07(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/draft07-schema.json")
+	if !assert.NoError(t, err, "ioutil.ReadFile should succeed") {
+		return
+	}
+
+	v, ok := buildFromJSON(t, string(src))
+	if !ok {
+		return
+	}
+	if !checkGenerated(t, v.SetName("Draft07V0"), "Draft07V", "generated_draft07_test.go") {
+		return
+	}
+
+	data := []interface{}{
+		map[string]interface{}{"kind": "square"},
+		map[string]interface{}{"kind": "circle"},
+		map[string]interface{}{"kind": "circle", "radius": float64(0)},
+		map[string]interface{}{"kind": "circle", "radius": float64(100)},
+		map[string]interface{}{"tags": []interface{}{"foo", "bar"}},
+		map[string]interface{}{"tags": []interface{}{"shape"}, "meta": map[string]interface{}{"Foo": 1}},
+		map[string]interface{}{"tags": []interface{}{"shape"}, "forbidden": 1},
+	}
+	for _, input := range data {
+		t.Logf("Testing %#v (should FAIL)", input)
+		for _, v := range []*jsval.JSVal{v, Draft07V0} {
+			if !assert.Error(t, v.Validate(input), "validation fails") {
+				return
+			}
+		}
+	}
+
+	data = []interface{}{
+		map[string]interface{}{"kind": "circle", "radius": 0.5},
+		map[string]interface{}{"tags": []interface{}{"foo", "shape"}},
+		map[string]interface{}{"tags": []interface{}{"shape"}, "meta": map[string]interface{}{"foo": 1}},
+	}
+	for _, input := range data {
+		t.Logf("Testing %#v (should PASS)", input)
+		for _, v := range []*jsval.JSVal{v, Draft07V0} {
+			if !assert.NoError(t, v.Validate(input), "validation passes") {
+				return
+			}
+		}
+	}
+}
+
+func TestDraft202012(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/draft202012-schema.json")
+	if !assert.NoError(t, err, "ioutil.ReadFile should succeed") {
+		return
+	}
+
+	v, ok := buildFromJSON(t, string(src))
+	if !ok {
+		return
+	}
+	if !checkGenerated(t, v.SetName("Draft202012V0"), "Draft202012V", "generated_draft202012_test.go") {
+		return
+	}
+
+	data := []interface{}{
+		map[string]interface{}{"point": []interface{}{1.0, 2.0, 3.0}},
+		map[string]interface{}{"point": []interface{}{1.0, "y"}},
+		map[string]interface{}{"labels": []interface{}{"", "main"}},
+		map[string]interface{}{"labels": []interface{}{"x", "main", "other"}},
+		map[string]interface{}{"owner": ""},
+		map[string]interface{}{"owner": "long"},
+		map[string]interface{}{"card": "1234"},
+		map[string]interface{}{"card": "1234", "billing": 1.0},
+		map[string]interface{}{"unknown": true},
+	}
+	for _, input := range data {
+		t.Logf("Testing %#v (should FAIL)", input)
+		for _, v := range []*jsval.JSVal{v, Draft202012V0} {
+			if !assert.Error(t, v.Validate(input), "validation fails") {
+				return
+			}
+		}
+	}
+
+	data = []interface{}{
+		map[string]interface{}{"point": []interface{}{1.0, 2.0}},
+		map[string]interface{}{"labels": []interface{}{"x", "main", "main"}},
+		map[string]interface{}{"owner": "bob", "extra": true},
+		map[string]interface{}{"card": "1234", "billing": "home"},
+	}
+	for _, input := range data {
+		t.Logf("Testing %#v (should PASS)", input)
+		for _, v := range []*jsval.JSVal{v, Draft202012V0} {
+			if !assert.NoError(t, v.Validate(input), "validation passes") {
+				return
+			}
+		}
+	}
+}
+
+func TestDraftSelection(t *testing.T) {
+	// Prior to 2019-09, keywords next to "$ref" are ignored, and
+	// "unevaluatedProperties" does not exist
+	const src = `{
+  "definitions": { "any": {} },
+  "properties": {
+    "name": { "$ref": "#/definitions/any", "type": "string" }
+  },
+  "unevaluatedProperties": false
+}`
+
+	var m map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(src), &m), "json.Unmarshal should succeed") {
+		return
+	}
+	m = builder.NormalizeSchema(m)
+	s := schema.New()
+	if !assert.NoError(t, s.Extract(m), "schema.Extract should succeed") {
+		return
+	}
+
+	input := map[string]interface{}{"name": 1.0, "other": true}
+
+	v, err := builder.New().BuildWithCtx(s, m)
+	if !assert.NoError(t, err, "Builder.BuildWithCtx should succeed") {
+		return
+	}
+	if !assert.NoError(t, v.Validate(input), "draft-07 validation passes") {
+		return
+	}
+
+	v, err = builder.New().SetDraft(builder.Draft201909).BuildWithCtx(s, m)
+	if !assert.NoError(t, err, "Builder.BuildWithCtx should succeed") {
+		return
+	}
+	if !assert.Error(t, v.Validate(map[string]interface{}{"name": 1.0}), "2019-09 validation fails on $ref siblings") {
+		return
+	}
+	if !assert.Error(t, v.Validate(map[string]interface{}{"name": "x", "other": true}), "2019-09 validation fails on unevaluated properties") {
+		return
+	}
+	if !assert.NoError(t, v.Validate(map[string]interface{}{"name": "x"}), "2019-09 validation passes") {
+		return
+	}
+}
@@ -1,10 +1,6 @@
 package jsval
 
-import (
-	"errors"
-
-	"github.com/lestrrat-go/pdebug"
-)
+import "github.com/lestrrat-go/pdebug"
 
 // Enum creates a new EnumConstraint
 func Enum(v ...interface{}) *EnumConstraint {
@@ -24,9 +20,9 @@ func (c *EnumConstraint) Validate(v interface{}) (err error) {
 		defer g.End()
 	}
 	for _, e := range c.enums {
-		if e == v {
+		if equalJSON(e, v) {
 			return nil
 		}
 	}
-	return errors.New("value is not in enumeration")
+	return newValidationError("enum", c.enums, v, "value is not in enumeration")
 }
@@ -0,0 +1,94 @@
+package jsval_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnum(t *testing.T) {
+	c := jsval.Enum(
+		float64(6),
+		"foo",
+		[]interface{}{},
+		true,
+		map[string]interface{}{"foo": float64(12), "bar": []interface{}{"baz"}},
+		nil,
+	)
+
+	valid := []interface{}{
+		6,
+		uint16(6),
+		6.0,
+		json.Number("6.0"),
+		"foo",
+		[]interface{}{},
+		[]string{},
+		true,
+		map[string]interface{}{"bar": []interface{}{"baz"}, "foo": 12},
+		map[string]interface{}{"bar": []string{"baz"}, "foo": json.Number("12")},
+		nil,
+	}
+	for _, v := range valid {
+		if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+			return
+		}
+	}
+
+	invalid := []interface{}{
+		6.5,
+		"6",
+		"bar",
+		[]interface{}{nil},
+		false,
+		map[string]interface{}{"foo": 12},
+		map[string]interface{}{"foo": 12, "bar": []interface{}{"baz"}, "baz": 1},
+		map[string]interface{}{"foo": "12", "bar": []interface{}{"baz"}},
+	}
+	for _, v := range invalid {
+		if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+			return
+		}
+	}
+}
+
+func TestConst(t *testing.T) {
+	c := jsval.Const(map[string]interface{}{"a": []interface{}{float64(1), "b"}})
+	if !assert.NoError(t, c.Validate(map[string]interface{}{"a": []interface{}{1, "b"}}), "numbers are compared by value") {
+		return
+	}
+	if !assert.Error(t, c.Validate(map[string]interface{}{"a": []interface{}{"1", "b"}}), "strings are not numbers") {
+		return
+	}
+}
+
+func TestEnumFromSchema(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/enum-schema.json")
+	if !assert.NoError(t, err, "ioutil.ReadFile should succeed") {
+		return
+	}
+
+	v, ok := buildFromJSON(t, string(src))
+	if !ok {
+		return
+	}
+	if !checkGenerated(t, v.SetName("EnumV0"), "EnumV", "generated_enum_test.go") {
+		return
+	}
+
+	for _, v := range []*jsval.JSVal{v, EnumV0} {
+		for _, input := range []interface{}{float64(6), 6, json.Number("6.0"), "foo", []interface{}{}, []string{}, map[string]interface{}{"foo": float64(12)}, nil} {
+			if !assert.NoError(t, v.Validate(input), "%#v should be valid", input) {
+				return
+			}
+		}
+		for _, input := range []interface{}{float64(7), "6", "bar", []interface{}{float64(6)}, map[string]interface{}{"foo": false}, map[string]interface{}{}} {
+			if !assert.Error(t, v.Validate(input), "%#v should be invalid", input) {
+				return
+			}
+		}
+	}
+}
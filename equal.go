@@ -0,0 +1,155 @@
+package jsval
+
+import (
+	"hash"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// numberRat converts a numeric value to a big.Rat. Floats are
+// converted to the closest decimal number, so that 0.1 and
+// json.Number("0.1") are equal. It returns nil for NaN and infinity.
+func numberRat(rv reflect.Value) *big.Rat {
+	switch rv.Type() {
+	case jsonNumberType:
+		r, ok := new(big.Rat).SetString(rv.String())
+		if !ok {
+			return nil
+		}
+		return r
+	case bigIntType:
+		return new(big.Rat).SetInt(rv.Interface().(*big.Int))
+	case bigFloatType:
+		f := rv.Interface().(*big.Float)
+		if f.IsInf() {
+			return nil
+		}
+		r, _ := f.Rat(nil)
+		return r
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		bits := 64
+		if rv.Kind() == reflect.Float32 {
+			bits = 32
+		}
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, bits))
+		return r
+	}
+	return nil
+}
+
+// equalJSON reports whether a and b are equal according to the JSON
+// data model: numbers are compared by their values regardless of their
+// Go types (1 == 1.0), objects are compared without regard to the
+// order of their keys, and arrays are compared element by element.
+// Values that can't be represented in JSON are compared using
+// reflect.DeepEqual.
+func equalJSON(a, b interface{}) bool {
+	return equalJSONValue(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equalJSONValue(a, b reflect.Value) bool {
+	a, ka := jsonValueOf(a)
+	b, kb := jsonValueOf(b)
+	if ka != kb {
+		return false
+	}
+
+	switch ka {
+	case jsonNull:
+		return true
+	case jsonBoolean:
+		return a.Bool() == b.Bool()
+	case jsonNumber:
+		ra, rb := numberRat(a), numberRat(b)
+		if ra == nil || rb == nil {
+			// NaN and infinity can't be represented in JSON
+			return false
+		}
+		return ra.Cmp(rb) == 0
+	case jsonString:
+		return a.String() == b.String()
+	case jsonArray:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalJSONValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case jsonObject:
+		ka, erra := propNames(a)
+		kb, errb := propNames(b)
+		if erra != nil || errb != nil || len(ka) != len(kb) {
+			return false
+		}
+		for _, k := range ka {
+			bv := propValue(b, k)
+			if !bv.IsValid() || !equalJSONValue(propValue(a, k), bv) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// hashJSON computes a hash of v that is consistent with equalJSON:
+// values that are equal according to equalJSON have the same hash.
+func hashJSON(v interface{}) uint64 {
+	h := fnv.New64a()
+	hashJSONValue(h, reflect.ValueOf(v))
+	return h.Sum64()
+}
+
+func hashJSONValue(h hash.Hash64, rv reflect.Value) {
+	rv, kind := jsonValueOf(rv)
+	h.Write([]byte{byte(kind)})
+
+	switch kind {
+	case jsonBoolean:
+		if rv.Bool() {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	case jsonNumber:
+		if r := numberRat(rv); r != nil {
+			h.Write([]byte(r.RatString()))
+		}
+	case jsonString:
+		h.Write([]byte(rv.String()))
+	case jsonArray:
+		for i := 0; i < rv.Len(); i++ {
+			hashJSONValue(h, rv.Index(i))
+		}
+	case jsonObject:
+		keys, _ := propNames(rv)
+		sort.Strings(keys)
+		for _, k := range keys {
+			h.Write([]byte(k))
+			h.Write([]byte{0})
+			hashJSONValue(h, propValue(rv, k))
+		}
+	}
+}
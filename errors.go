@@ -0,0 +1,107 @@
+package jsval
+
+import (
+	"strings"
+)
+
+// ValidationError is the error returned by constraints when the value
+// being validated does not satisfy them. It carries enough information
+// to build machine readable error reports without having to parse the
+// error message.
+type ValidationError struct {
+	// Pointer is the JSON Pointer (RFC 6901) to the offending value,
+	// relative to the value that was passed to Validate. The root
+	// value is represented by the empty string.
+	Pointer string
+
+	// Keyword is the name of the JSON Schema keyword that failed,
+	// such as "maxLength", "required", or "pattern".
+	Keyword string
+
+	// Limit is the value that was specified for the keyword in the
+	// constraint (e.g. 5 for "maxLength": 5). For "required" and
+	// "dependencies" this is the name of the missing property.
+	Limit interface{}
+
+	// Value is the value that failed validation.
+	Value interface{}
+
+	// Message is a human readable description of the failure.
+	Message string
+
+	// Offset is the byte offset of the offending value in the raw
+	// JSON input. It is only available when the input was validated
+	// via ValidateJSON or ValidateReader, and is -1 otherwise.
+	Offset int
+}
+
+// Error returns the human readable message, prefixed with the
+// JSON Pointer of the failing value if it is not the root value.
+func (e *ValidationError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return "'" + e.Pointer + "': " + e.Message
+}
+
+// NewValidationError creates a new ValidationError for the root value.
+// It is used by the code generated by the Compiler, and by custom
+// constraints that want to report errors the same way as the built-in
+// ones.
+func NewValidationError(keyword string, limit, value interface{}, msg string) *ValidationError {
+	return newValidationError(keyword, limit, value, msg)
+}
+
+func newValidationError(keyword string, limit, value interface{}, msg string) *ValidationError {
+	return &ValidationError{
+		Keyword: keyword,
+		Limit:   limit,
+		Value:   value,
+		Message: msg,
+		Offset:  -1,
+	}
+}
+
+// escapePointerToken escapes a single reference token so that it
+// can be used as part of a JSON Pointer
+func escapePointerToken(s string) string {
+	if !strings.ContainsAny(s, "~/") {
+		return s
+	}
+	s = strings.Replace(s, "~", "~0", -1)
+	return strings.Replace(s, "/", "~1", -1)
+}
+
+// ErrorAt returns err, with the JSON Pointer reference token
+// prepended to the pointer of the failing value. Use it to report
+// the errors for the value of property (or array index) token from
+// a custom constraint.
+func ErrorAt(err error, token string) error {
+	return withPointer(err, token)
+}
+
+// withPointer prepends the given reference token to the JSON Pointer
+// of the error. Errors that are not *ValidationError are converted
+// to one so that callers always receive a consistent type.
+func withPointer(err error, token string) error {
+	return withPointerPrefix(err, "/"+escapePointerToken(token))
+}
+
+// withPointerPrefix is the same as withPointer, except that it
+// prepends an already escaped JSON Pointer.
+func withPointerPrefix(err error, ptr string) error {
+	if err == nil {
+		return nil
+	}
+
+	ve, ok := err.(*ValidationError)
+	if !ok {
+		ve = &ValidationError{Message: err.Error(), Offset: -1}
+	} else {
+		// Do not modify the original error, as it may be shared
+		cp := *ve
+		ve = &cp
+	}
+	ve.Pointer = ptr + ve.Pointer
+	return ve
+}
@@ -0,0 +1,83 @@
+package jsval_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationError(t *testing.T) {
+	v := jsval.New().SetRoot(
+		jsval.Object().
+			AddProp(`zip`, jsval.String().MaxLength(5)).
+			AddProp(`tags`, jsval.Array().Items(jsval.String().RegexpString(`^[a-z]+$`))).
+			AddProp(`a/b`, jsval.Integer().Minimum(10)).
+			AddProp(`name`, jsval.String()).
+			Required(`name`),
+	)
+
+	data := []struct {
+		Input   interface{}
+		Pointer string
+		Keyword string
+		Limit   interface{}
+		Value   interface{}
+	}{
+		{
+			Input:   map[string]interface{}{"name": "x", "zip": "123456"},
+			Pointer: "/zip",
+			Keyword: "maxLength",
+			Limit:   5,
+			Value:   "123456",
+		},
+		{
+			Input:   map[string]interface{}{"name": "x", "tags": []interface{}{"foo", "BAR"}},
+			Pointer: "/tags/1",
+			Keyword: "pattern",
+			Limit:   `^[a-z]+$`,
+			Value:   "BAR",
+		},
+		{
+			Input:   map[string]interface{}{"name": "x", "a/b": 1},
+			Pointer: "/a~1b",
+			Keyword: "minimum",
+			Limit:   float64(10),
+			Value:   1,
+		},
+		{
+			Input:   map[string]interface{}{},
+			Pointer: "",
+			Keyword: "required",
+			Limit:   "name",
+		},
+	}
+
+	for _, d := range data {
+		err := v.Validate(d.Input)
+		if !assert.Error(t, err, "validation should fail for %#v", d.Input) {
+			return
+		}
+
+		verr, ok := errors.Cause(err).(*jsval.ValidationError)
+		if !assert.True(t, ok, "cause should be a *jsval.ValidationError (got %T)", errors.Cause(err)) {
+			return
+		}
+
+		if !assert.Equal(t, d.Pointer, verr.Pointer, "pointer matches") {
+			return
+		}
+		if !assert.Equal(t, d.Keyword, verr.Keyword, "keyword matches") {
+			return
+		}
+		if !assert.Equal(t, d.Limit, verr.Limit, "limit matches") {
+			return
+		}
+		if d.Value != nil {
+			if !assert.Equal(t, d.Value, verr.Value, "value matches") {
+				return
+			}
+		}
+	}
+}
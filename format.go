@@ -0,0 +1,128 @@
+package jsval
+
+import (
+	"sync"
+)
+
+// FormatFunc checks that the given string conforms to a format.
+// It should return a non-nil error describing the problem if it
+// does not.
+type FormatFunc func(string) error
+
+// FormatRegistry holds the named formats that are used to validate
+// the `format` of a string. By default, formats that are not registered
+// are not checked at all. In strict mode, unknown formats are reported
+// as validation errors instead.
+type FormatRegistry struct {
+	lock    sync.RWMutex
+	formats map[string]FormatFunc
+	strict  bool
+}
+
+// DefaultFormatRegistry is the registry used by StringConstraint
+// objects that were not given their own registry via `Formats`.
+// It comes with the formats defined by the JSON Schema specification
+// registered.
+var DefaultFormatRegistry = NewFormatRegistry().
+	Register("date", isDate).
+	Register("date-time", isDateTime).
+	Register("datetime", isDateTime). // for backwards compatibility
+	Register("duration", isDuration).
+	Register("email", isEmail).
+	Register("hostname", isHostname).
+	Register("idn-email", isIDNEmail).
+	Register("idn-hostname", isIDNHostname).
+	Register("ipv4", isIPv4).
+	Register("ipv6", isIPv6).
+	Register("iri", isIRI).
+	Register("iri-reference", isIRIReference).
+	Register("json-pointer", isJSONPointer).
+	Register("regex", isRegex).
+	Register("relative-json-pointer", isRelativeJSONPointer).
+	Register("time", isTime).
+	Register("uri", isURI).
+	Register("uri-reference", isURIReference).
+	Register("uri-template", isURITemplate).
+	Register("uuid", isUUID)
+
+// RegisterFormat registers a format in the DefaultFormatRegistry,
+// replacing any format previously registered under the same name.
+func RegisterFormat(name string, fn FormatFunc) {
+	DefaultFormatRegistry.Register(name, fn)
+}
+
+// NewFormatRegistry creates a new, empty FormatRegistry. To start from
+// the built-in formats, use `DefaultFormatRegistry.Clone()` instead.
+func NewFormatRegistry() *FormatRegistry {
+	return &FormatRegistry{
+		formats: make(map[string]FormatFunc),
+	}
+}
+
+// Register registers a format under the given name, replacing any
+// format previously registered under the same name.
+func (r *FormatRegistry) Register(name string, fn FormatFunc) *FormatRegistry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.formats[name] = fn
+	return r
+}
+
+// Lookup returns the format registered under the given name
+func (r *FormatRegistry) Lookup(name string) (FormatFunc, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	fn, ok := r.formats[name]
+	return fn, ok
+}
+
+// SetStrict specifies if formats that are not registered should be
+// rejected. If unspecified, unknown formats are silently accepted.
+func (r *FormatRegistry) SetStrict(b bool) *FormatRegistry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.strict = b
+	return r
+}
+
+// IsStrict returns true if unknown formats are rejected
+func (r *FormatRegistry) IsStrict() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.strict
+}
+
+// Clone creates a new FormatRegistry with the same formats and
+// strictness. Use this to add formats for a particular validator
+// without affecting the others.
+func (r *FormatRegistry) Clone() *FormatRegistry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	r2 := NewFormatRegistry()
+	for name, fn := range r.formats {
+		r2.formats[name] = fn
+	}
+	r2.strict = r.strict
+	return r2
+}
+
+// check validates str against the named format
+func (r *FormatRegistry) check(name, str string) error {
+	fn, ok := r.Lookup(name)
+	if !ok {
+		if r.IsStrict() {
+			return newValidationError("format", name, str, "unknown format '"+name+"'")
+		}
+		return nil
+	}
+
+	if err := fn(str); err != nil {
+		return newValidationError("format", name, str, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package jsval_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFormatConformance checks the formats registered in the
+// DefaultFormatRegistry against examples taken from their RFCs and
+// the JSON Schema Test Suite
+func TestFormatConformance(t *testing.T) {
+	data := []struct {
+		Format  string
+		Valid   []string
+		Invalid []string
+	}{
+		{
+			Format: "date-time",
+			Valid: []string{
+				"1963-06-19T08:30:06.283185Z",
+				"1963-06-19T08:30:06Z",
+				"1937-01-01T12:00:27.87+00:20",
+				"1990-12-31T15:59:50.123-08:00",
+				"1998-12-31T23:59:60Z",
+				"1998-12-31T15:59:60.123-08:00",
+				"1963-06-19t08:30:06.283185z",
+			},
+			Invalid: []string{
+				"1998-12-31T22:59:60Z",
+				"1990-02-31T15:59:59.123-08:00",
+				"1990-12-31T15:59:59-24:00",
+				"1963-06-19T08:30:06.28123+01:00Z",
+				"06/19/1963 08:30:06 PST",
+				"2013-350T01:01:01",
+				"1963-06-19 08:30:06Z",
+				"1963-06-19T08:30:06",
+			},
+		},
+		{
+			Format:  "datetime",
+			Valid:   []string{"1963-06-19T08:30:06Z"},
+			Invalid: []string{"1963-06-19"},
+		},
+		{
+			Format:  "date",
+			Valid:   []string{"1963-06-19", "2020-01-31", "2020-02-29", "2000-02-29"},
+			Invalid: []string{"2020-01-32", "2021-02-29", "1900-02-29", "2020-13-01", "06/19/1963", "1998-1-20", "2013-350"},
+		},
+		{
+			Format: "time",
+			Valid:  []string{"08:30:06Z", "08:30:06.283185Z", "23:59:60Z", "15:59:60-08:00", "08:30:06+00:20", "08:30:06z"},
+			Invalid: []string{
+				"22:59:60Z",
+				"08:30:06",
+				"24:00:00Z",
+				"00:60:00Z",
+				"08:30:06+24:00",
+				"08:30:06 PST",
+				"8:3:6Z",
+			},
+		},
+		{
+			Format:  "duration",
+			Valid:   []string{"P4DT12H30M5S", "P1Y", "PT1M", "P2W"},
+			Invalid: []string{"P", "PT", "P1D2H", "4DT12H30M5S", "P1W1D"},
+		},
+		{
+			Format:  "email",
+			Valid:   []string{"joe.bloggs@example.com", "te~st@example.com", `"joe bloggs"@example.com`, "joe.bloggs@[127.0.0.1]", "joe.bloggs@[IPv6:::1]"},
+			Invalid: []string{"2962", ".test@example.com", "test.@example.com", "te..st@example.com", "joe.bloggs@[127.0.0.300]", "Joe <joe@example.com>", "실례@실례.테스트"},
+		},
+		{
+			Format:  "idn-email",
+			Valid:   []string{"실례@실례.테스트", "joe.bloggs@example.com"},
+			Invalid: []string{"2962", "실례@@실례.테스트"},
+		},
+		{
+			Format: "hostname",
+			Valid:  []string{"www.example.com", "xn--4gbwdl.xn--wgbh1c", "hostname", "1host", "example.com."},
+			Invalid: []string{
+				"-a-host-name-that-starts-with--",
+				"not_a_valid_host_name",
+				"a-vvvvvvvvvvvvvvvveeeeeeeeeeeeeeeerrrrrrrrrrrrrrrryyyyyyyyyyyyyyyy-long-host-name-component",
+				"host..name",
+				"",
+				"실례.테스트",
+			},
+		},
+		{
+			Format:  "idn-hostname",
+			Valid:   []string{"실례.테스트", "www.example.com", "ελληνικά.gr"},
+			Invalid: []string{"〮실례.테스트", "-실례", "실례 테스트", ""},
+		},
+		{
+			Format:  "ipv4",
+			Valid:   []string{"192.168.0.1", "0.0.0.0", "255.255.255.255"},
+			Invalid: []string{"127.0.0.0.1", "256.256.256.256", "127.0", "0x7f000001", "087.10.0.1", "1.2.3.4\n", "১২৭.০.০.১"},
+		},
+		{
+			Format:  "ipv6",
+			Valid:   []string{"::1", "fe80::1", "::abef", "1:d6::42", "::ffff:192.168.0.1", "2001:db8:85a3::8a2e:370:7334"},
+			Invalid: []string{"12345::", "::laptop", ":2:3:4:5:6:7:8", "1:2:3:4:5:6:7:8:9", "fe80::1%eth0", "::ffff:192.168.0.256", "127.0.0.1", " ::1"},
+		},
+		{
+			Format:  "uri",
+			Valid:   []string{"http://foo.bar/?baz=qux#quux", "http://foo.com/blah_(wikipedia)_blah#cite-1", "ftp://ftp.is.co.za/rfc/rfc1808.txt", "mailto:John.Doe@example.com", "urn:oasis:names:specification:docbook:dtd:xml:4.1.2", "http://[2001:db8::7]/c=GB?objectClass?one", "tel:+1-816-555-1212"},
+			Invalid: []string{"//foo.bar/?baz=qux#quux", "/abc", "\\\\WINDOWS\\fileshare", "abc", "http:// shouldfail.com", ":// should fail", "http://example.com/%zz", "http://ƒøø.ßår/"},
+		},
+		{
+			Format:  "uri-reference",
+			Valid:   []string{"http://foo.bar/?baz=qux#quux", "//foo.bar/?baz=qux#quux", "/abc", "abc", "#fragment", "", "../up"},
+			Invalid: []string{"\\\\WINDOWS\\fileshare", "#frag\\ment", "http://example.com/%zz", "a b"},
+		},
+		{
+			Format:  "iri",
+			Valid:   []string{"http://ƒøø.ßår/?∂éœ=πîx#πîüx", "urn:isbn:0451450523", "http://[2001:db8::7]/"},
+			Invalid: []string{"/abc", "http://[::1", "http://exa mple.com/"},
+		},
+		{
+			Format:  "iri-reference",
+			Valid:   []string{"http://ƒøø.ßår/?∂éœ=πîx#πîüx", "//ƒøø.ßår/?∂éœ=πîx#πîüx", "#ƒrägmênt"},
+			Invalid: []string{"\\\\WINDOWS\\filëßåré", "#ƒräg\\mênt"},
+		},
+		{
+			Format:  "uri-template",
+			Valid:   []string{"http://example.com/dictionary/{term:1}/{term}", "dictionary/{term:1}/{term}", "{/path*}{?x,y}", "{+var}", "no-expressions"},
+			Invalid: []string{"http://example.com/dictionary/{term:1}/{term", "{}", "{term:0}", "{term:10000}", "{te rm}", "{a..b}", "}"},
+		},
+		{
+			Format:  "uuid",
+			Valid:   []string{"2eb8aa08-aa98-11ea-b4aa-73b441d16380", "2EB8AA08-AA98-11EA-B4AA-73B441D16380"},
+			Invalid: []string{"2eb8aa08aa9811eab4aa73b441d16380", "2eb8aa08-aa98-11ea-b4aa-73b441d1638g"},
+		},
+		{
+			Format:  "json-pointer",
+			Valid:   []string{"", "/", "/foo/0", "/a~1b", "/m~0n"},
+			Invalid: []string{"foo", "/foo~", "/foo~2"},
+		},
+		{
+			Format:  "relative-json-pointer",
+			Valid:   []string{"0", "1/0", "2#", "0/foo/bar"},
+			Invalid: []string{"", "/foo", "01", "-1/foo", "1foo"},
+		},
+		{
+			Format:  "regex",
+			Valid:   []string{`^[a-z]+$`, `\d{3}`},
+			Invalid: []string{`^[a-z+$`, `(foo`},
+		},
+	}
+
+	for _, d := range data {
+		c := jsval.String().Format(d.Format)
+		for _, s := range d.Valid {
+			if !assert.NoError(t, c.Validate(s), "%s: %q should be valid", d.Format, s) {
+				return
+			}
+		}
+		for _, s := range d.Invalid {
+			if !assert.Error(t, c.Validate(s), "%s: %q should be invalid", d.Format, s) {
+				return
+			}
+		}
+	}
+}
+
+func TestFormatRegistry(t *testing.T) {
+	skuRx := regexp.MustCompile(`^[A-Z]{3}-\d{4}$`)
+	isSKU := func(s string) error {
+		if !skuRx.MatchString(s) {
+			return errors.New("invalid SKU")
+		}
+		return nil
+	}
+
+	t.Run("Per constraint", func(t *testing.T) {
+		r := jsval.DefaultFormatRegistry.Clone().Register("sku", isSKU)
+		c := jsval.String().Format("sku").Formats(r)
+		if !assert.NoError(t, c.Validate("ABC-1234"), "validation should succeed") {
+			return
+		}
+		if !assert.Error(t, c.Validate("abc-1234"), "validation should fail") {
+			return
+		}
+
+		// The default registry does not know about "sku"
+		if _, ok := jsval.DefaultFormatRegistry.Lookup("sku"); !assert.False(t, ok, "sku should not be in the default registry") {
+			return
+		}
+		if !assert.NoError(t, jsval.String().Format("sku").Validate("abc-1234"), "unknown formats are accepted") {
+			return
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		r := jsval.NewFormatRegistry().SetStrict(true)
+		err := jsval.String().Format("sku").Formats(r).Validate("ABC-1234")
+		if !assert.Error(t, err, "unknown formats are rejected") {
+			return
+		}
+		verr, ok := err.(*jsval.ValidationError)
+		if !assert.True(t, ok, "error is a ValidationError") {
+			return
+		}
+		if !assert.Equal(t, "format", verr.Keyword, "keyword is format") {
+			return
+		}
+	})
+
+	t.Run("Builder", func(t *testing.T) {
+		const src = `{ "type": "string", "format": "sku" }`
+		s, err := schema.Read(strings.NewReader(src))
+		if !assert.NoError(t, err, "schema.Read should succeed") {
+			return
+		}
+
+		r := jsval.NewFormatRegistry().SetStrict(true)
+		if _, err := builder.New().SetFormatRegistry(r).Build(s); !assert.Error(t, err, "Build should fail for unknown formats in strict mode") {
+			return
+		}
+
+		r.Register("sku", isSKU)
+		v, err := builder.New().SetFormatRegistry(r).Build(s)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.Validate("ABC-1234"), "validation should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate("ABC-123"), "validation should fail") {
+			return
+		}
+	})
+
+	t.Run("RegisterFormat", func(t *testing.T) {
+		jsval.RegisterFormat("x-test-sku", isSKU)
+		c := jsval.String().Format("x-test-sku")
+		if !assert.Error(t, c.Validate("abc"), "validation should fail") {
+			return
+		}
+	})
+}
@@ -0,0 +1,452 @@
+package jsval
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file contains the formats that are registered in
+// DefaultFormatRegistry. Each of them follows the RFC referred to
+// by the JSON Schema specification.
+
+var (
+	dateRx         = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	timeRx         = regexp.MustCompile(`^(\d{2}):(\d{2}):(\d{2})(?:\.\d+)?(?:([Zz])|([+-])(\d{2}):(\d{2}))$`)
+	uuidRx         = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	uriSchemeRx    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:`)
+	durationRx     = regexp.MustCompile(`^P(?:\d+W|(?:\d+Y(?:\d+M(?:\d+D)?)?|\d+M(?:\d+D)?|\d+D)(?:T(?:\d+H(?:\d+M(?:\d+S)?)?|\d+M(?:\d+S)?|\d+S))?|T(?:\d+H(?:\d+M(?:\d+S)?)?|\d+M(?:\d+S)?|\d+S))$`)
+	daysInMonth    = [...]int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	errInvalidDate = errors.New("invalid date")
+	errInvalidTime = errors.New("invalid time")
+)
+
+// isDate checks for a RFC 3339 full-date
+func isDate(s string) error {
+	m := dateRx.FindStringSubmatch(s)
+	if m == nil {
+		return errInvalidDate
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	if month < 1 || month > 12 || day < 1 || day > daysInMonth[month-1] {
+		return errInvalidDate
+	}
+	if month == 2 && day == 29 && !isLeapYear(year) {
+		return errInvalidDate
+	}
+	return nil
+}
+
+func isLeapYear(y int) bool {
+	return y%4 == 0 && (y%100 != 0 || y%400 == 0)
+}
+
+// isTime checks for a RFC 3339 full-time, which requires a time offset.
+// Leap seconds are only allowed at 23:59:60 UTC.
+func isTime(s string) error {
+	m := timeRx.FindStringSubmatch(s)
+	if m == nil {
+		return errInvalidTime
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	var offh, offm int
+	if m[4] == "" {
+		offh, _ = strconv.Atoi(m[6])
+		offm, _ = strconv.Atoi(m[7])
+		if offh > 23 || offm > 59 {
+			return errInvalidTime
+		}
+		if m[5] == "-" {
+			offh, offm = -offh, -offm
+		}
+	}
+
+	if hour > 23 || min > 59 || sec > 60 {
+		return errInvalidTime
+	}
+
+	if sec == 60 {
+		// Convert to UTC, and check that this is the last minute of the day
+		utc := ((hour-offh)*60 + (min - offm) + 24*60) % (24 * 60)
+		if utc != 23*60+59 {
+			return errInvalidTime
+		}
+	}
+	return nil
+}
+
+// isDateTime checks for a RFC 3339 date-time
+func isDateTime(s string) error {
+	i := strings.IndexAny(s, "Tt")
+	if i < 0 {
+		return errors.New("invalid date-time")
+	}
+	if isDate(s[:i]) != nil || isTime(s[i+1:]) != nil {
+		return errors.New("invalid date-time")
+	}
+	return nil
+}
+
+// isDuration checks for a ISO 8601 duration, as described in
+// RFC 3339 Appendix A
+func isDuration(s string) error {
+	if !durationRx.MatchString(s) {
+		return errors.New("invalid duration")
+	}
+	return nil
+}
+
+// isEmail checks for a RFC 5321 mailbox
+func isEmail(s string) error {
+	if err := checkMailbox(s, false); err != nil {
+		return errors.New("invalid email address: " + err.Error())
+	}
+	return nil
+}
+
+// isIDNEmail checks for a RFC 6531 mailbox, which allows UTF-8 in
+// both the local part and the domain
+func isIDNEmail(s string) error {
+	if err := checkMailbox(s, true); err != nil {
+		return errors.New("invalid email address: " + err.Error())
+	}
+	return nil
+}
+
+func checkMailbox(s string, idn bool) error {
+	i := strings.LastIndexByte(s, '@')
+	if i < 0 {
+		return errors.New("missing '@'")
+	}
+	local, domain := s[:i], s[i+1:]
+
+	switch {
+	case local == "":
+		return errors.New("empty local part")
+	case len(local) > 1 && local[0] == '"' && local[len(local)-1] == '"':
+		for _, r := range local[1 : len(local)-1] {
+			if r < 0x20 || r == 0x7f || (r >= 0x80 && !idn) {
+				return errors.New("invalid character in quoted local part")
+			}
+		}
+	default:
+		for _, atom := range strings.Split(local, ".") {
+			if atom == "" {
+				return errors.New("empty atom in local part")
+			}
+			for _, r := range atom {
+				if !isAtext(r) && !(idn && r >= 0x80) {
+					return errors.New("invalid character in local part")
+				}
+			}
+		}
+	}
+
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		lit := domain[1 : len(domain)-1]
+		if strings.HasPrefix(lit, "IPv6:") {
+			return isIPv6(lit[5:])
+		}
+		return isIPv4(lit)
+	}
+
+	if idn {
+		return isIDNHostname(domain)
+	}
+	return isHostname(domain)
+}
+
+// isAtext returns true for the characters allowed in an atom (RFC 5322)
+func isAtext(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+// isHostname checks for a RFC 1123 hostname
+func isHostname(s string) error {
+	return checkHostname(s, false)
+}
+
+// isIDNHostname checks for an internationalized hostname (RFC 5890).
+// Labels may contain letters, marks and digits of any script.
+func isIDNHostname(s string) error {
+	return checkHostname(s, true)
+}
+
+func checkHostname(s string, idn bool) error {
+	// A single trailing dot denotes the root, and is allowed
+	if len(s) > 1 {
+		s = strings.TrimSuffix(s, ".")
+	}
+	if s == "" || len(s) > 253 {
+		return errors.New("invalid hostname")
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return errors.New("invalid hostname: labels must be between 1 and 63 characters")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.New("invalid hostname: labels must not start or end with '-'")
+		}
+		if r, _ := utf8.DecodeRuneInString(label); unicode.IsMark(r) {
+			return errors.New("invalid hostname: labels must not start with a combining mark")
+		}
+		for _, r := range label {
+			switch {
+			case r == '-', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			case idn && r >= 0x80 && (unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)):
+			default:
+				return errors.New("invalid hostname: invalid character " + strconv.QuoteRune(r))
+			}
+		}
+	}
+	return nil
+}
+
+// isIPv4 checks for a dotted-quad IPv4 address (RFC 2673). Leading
+// zeros are not allowed, as they are ambiguous.
+func isIPv4(s string) error {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return errors.New("invalid IPv4 address")
+	}
+	for _, part := range parts {
+		if part == "" || len(part) > 3 || (len(part) > 1 && part[0] == '0') {
+			return errors.New("invalid IPv4 address")
+		}
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return errors.New("invalid IPv4 address")
+			}
+		}
+		if n, _ := strconv.Atoi(part); n > 255 {
+			return errors.New("invalid IPv4 address")
+		}
+	}
+	return nil
+}
+
+// isIPv6 checks for a RFC 4291 IPv6 address. Zone identifiers are
+// not allowed.
+func isIPv6(s string) error {
+	if !strings.Contains(s, ":") {
+		return errors.New("invalid IPv6 address")
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ':' || c == '.':
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return errors.New("invalid IPv6 address")
+		}
+	}
+
+	// An embedded IPv4 address must be a valid dotted-quad
+	if i := strings.LastIndexByte(s, ':'); strings.Contains(s[i:], ".") {
+		if err := isIPv4(s[i+1:]); err != nil {
+			return errors.New("invalid IPv6 address")
+		}
+	}
+
+	if net.ParseIP(s) == nil {
+		return errors.New("invalid IPv6 address")
+	}
+	return nil
+}
+
+// checkURIChars makes sure that s only contains characters allowed
+// in a URI (RFC 3986), and that percent signs start a valid
+// percent-encoded octet. If iri is true, non-ASCII characters are
+// allowed as well (RFC 3987).
+func checkURIChars(s string, iri bool) error {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~:/?#[]@!$&'()*+,;=", c) >= 0:
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return errors.New("invalid percent-encoding")
+			}
+			i += 2
+		case iri && c >= 0x80:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError {
+				return errors.New("invalid UTF-8 sequence")
+			}
+			i += size - 1
+		default:
+			return errors.New("invalid character " + strconv.Quote(string(c)))
+		}
+	}
+	return nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func checkURI(s, kind string, iri, absolute bool) error {
+	if err := checkURIChars(s, iri); err != nil {
+		return errors.New("invalid " + kind + ": " + err.Error())
+	}
+	if absolute && !uriSchemeRx.MatchString(s) {
+		return errors.New("invalid " + kind + ": missing scheme")
+	}
+	if _, err := url.Parse(s); err != nil {
+		return errors.New("invalid " + kind)
+	}
+	return nil
+}
+
+// isURI checks for an absolute URI (RFC 3986)
+func isURI(s string) error {
+	return checkURI(s, "URI", false, true)
+}
+
+// isURIReference checks for a URI or a relative reference (RFC 3986)
+func isURIReference(s string) error {
+	return checkURI(s, "URI reference", false, false)
+}
+
+// isIRI checks for an absolute IRI (RFC 3987)
+func isIRI(s string) error {
+	return checkURI(s, "IRI", true, true)
+}
+
+// isIRIReference checks for an IRI or a relative reference (RFC 3987)
+func isIRIReference(s string) error {
+	return checkURI(s, "IRI reference", true, false)
+}
+
+// isURITemplate checks for a RFC 6570 URI template
+func isURITemplate(s string) error {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return errors.New("invalid URI template: unterminated expression")
+			}
+			if err := checkURITemplateExpr(s[i+1 : i+end]); err != nil {
+				return errors.New("invalid URI template: " + err.Error())
+			}
+			i += end
+		case c == '}':
+			return errors.New("invalid URI template: unexpected '}'")
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return errors.New("invalid URI template: invalid percent-encoding")
+			}
+			i += 2
+		case c <= 0x20 || c == 0x7f || strings.IndexByte("\"'<>\\^`|", c) >= 0:
+			return errors.New("invalid URI template: invalid character " + strconv.Quote(string(c)))
+		}
+	}
+	return nil
+}
+
+func checkURITemplateExpr(expr string) error {
+	if expr != "" && strings.IndexByte("+#./;?&=,!@|", expr[0]) >= 0 {
+		expr = expr[1:]
+	}
+	if expr == "" {
+		return errors.New("empty expression")
+	}
+
+	for _, spec := range strings.Split(expr, ",") {
+		name := spec
+		if strings.HasSuffix(spec, "*") {
+			name = spec[:len(spec)-1]
+		} else if i := strings.IndexByte(spec, ':'); i >= 0 {
+			name = spec[:i]
+			n, err := strconv.Atoi(spec[i+1:])
+			if err != nil || n < 1 || n > 9999 || spec[i+1] == '0' {
+				return errors.New("invalid prefix modifier in '" + spec + "'")
+			}
+		}
+
+		if name == "" || name[0] == '.' || name[len(name)-1] == '.' || strings.Contains(name, "..") {
+			return errors.New("invalid variable name '" + name + "'")
+		}
+		for i := 0; i < len(name); i++ {
+			switch c := name[i]; {
+			case c == '_' || c == '.':
+			case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			case c == '%':
+				if i+2 >= len(name) || !isHex(name[i+1]) || !isHex(name[i+2]) {
+					return errors.New("invalid percent-encoding in '" + name + "'")
+				}
+				i += 2
+			default:
+				return errors.New("invalid variable name '" + name + "'")
+			}
+		}
+	}
+	return nil
+}
+
+func isUUID(s string) error {
+	if !uuidRx.MatchString(s) {
+		return errors.New("invalid UUID")
+	}
+	return nil
+}
+
+// isJSONPointer checks for a RFC 6901 JSON Pointer
+func isJSONPointer(s string) error {
+	if s != "" && s[0] != '/' {
+		return errors.New("invalid JSON pointer: must start with '/'")
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '~' {
+			continue
+		}
+		if i+1 >= len(s) || (s[i+1] != '0' && s[i+1] != '1') {
+			return errors.New("invalid JSON pointer: '~' must be followed by '0' or '1'")
+		}
+	}
+	return nil
+}
+
+func isRelativeJSONPointer(s string) error {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || (s[0] == '0' && i > 1) {
+		return errors.New("invalid relative JSON pointer: must start with a non-negative integer")
+	}
+
+	rest := s[i:]
+	if rest == "#" {
+		return nil
+	}
+	if err := isJSONPointer(rest); err != nil {
+		return errors.New("invalid relative JSON pointer")
+	}
+	return nil
+}
+
+func isRegex(s string) error {
+	if _, err := regexp.Compile(s); err != nil {
+		return errors.New("invalid regular expression: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Code generated by jsval accessors. DO NOT EDIT.
+
+package jsval_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/lestrrat-go/jsval"
+)
+
+// GetPropNames returns the names of the properties that are present in accessorTestRecord
+func (v accessorTestRecord) GetPropNames() ([]string, error) {
+	l := make([]string, 0, 7)
+	if v.accessorTestOwner != nil {
+		l = append(l, "owner")
+	}
+	if v.accessorTestOwner != nil && v.accessorTestOwner.Version != 0 {
+		l = append(l, "version")
+	}
+	l = append(l, "id")
+	if v.Name != "" {
+		l = append(l, "name")
+	}
+	if v.Count.Valid() {
+		l = append(l, "count")
+	}
+	if len(v.Tags) != 0 {
+		l = append(l, "tags")
+	}
+	l = append(l, "Score")
+	return l, nil
+}
+
+// GetPropValue returns the value of the property pname of accessorTestRecord,
+// or nil if it is not present
+func (v accessorTestRecord) GetPropValue(pname string) (interface{}, error) {
+	switch pname {
+	case "owner":
+		if v.accessorTestOwner == nil {
+			return nil, nil
+		}
+		return v.accessorTestOwner.Owner, nil
+	case "version":
+		if v.accessorTestOwner == nil || v.accessorTestOwner.Version == 0 {
+			return nil, nil
+		}
+		buf, err := json.Marshal(v.accessorTestOwner.Version)
+		if err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	case "id":
+		return v.ID, nil
+	case "name":
+		if v.Name == "" {
+			return nil, nil
+		}
+		return v.Name, nil
+	case "count":
+		if !v.Count.Valid() {
+			return nil, nil
+		}
+		return v.Count.Value(), nil
+	case "tags":
+		if len(v.Tags) == 0 {
+			return nil, nil
+		}
+		return v.Tags, nil
+	case "Score":
+		return v.Score, nil
+	}
+	return nil, nil
+}
+
+// SetPropValue sets the value of the property pname of accessorTestRecord
+func (v *accessorTestRecord) SetPropValue(pname string, x interface{}) error {
+	switch pname {
+	case "owner":
+		if v.accessorTestOwner == nil {
+			v.accessorTestOwner = &accessorTestOwner{}
+		}
+		if x, ok := x.(string); ok {
+			v.accessorTestOwner.Owner = x
+			return nil
+		}
+		return jsval.AssignValue(&v.accessorTestOwner.Owner, x)
+	case "version":
+		if v.accessorTestOwner == nil {
+			v.accessorTestOwner = &accessorTestOwner{}
+		}
+		return jsval.AssignValue(&v.accessorTestOwner.Version, x)
+	case "id":
+		if x, ok := x.(string); ok {
+			v.ID = x
+			return nil
+		}
+		return jsval.AssignValue(&v.ID, x)
+	case "name":
+		if x, ok := x.(string); ok {
+			v.Name = x
+			return nil
+		}
+		return jsval.AssignValue(&v.Name, x)
+	case "count":
+		return jsval.AssignValue(&v.Count, x)
+	case "tags":
+		return jsval.AssignValue(&v.Tags, x)
+	case "Score":
+		if x, ok := x.(float64); ok {
+			v.Score = x
+			return nil
+		}
+		return jsval.AssignValue(&v.Score, x)
+	}
+	return errors.New("unknown property '" + pname + "'")
+}
@@ -0,0 +1,572 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/jsval"
+)
+
+type Compiled0Type struct {
+	Customer *Compiled0TypeCustomer `json:"customer,omitempty"`
+	Discount jsval.MaybeFloat       `json:"discount"`
+	Gift     jsval.MaybeBool        `json:"gift"`
+	ID       string                 `json:"id"`
+	Items    []LineItem             `json:"items"`
+	Priority jsval.MaybeInt         `json:"priority"`
+	Status   Compiled0TypeStatus    `json:"status"`
+	Tags     []string               `json:"tags,omitempty"`
+	Weight   jsval.MaybeFloat       `json:"weight"`
+}
+
+func (v *Compiled0Type) Validate() error {
+	return Compiled0.Validate(v)
+}
+
+type LineItem struct {
+	Note     jsval.MaybeString `json:"note"`
+	Quantity Quantity          `json:"quantity"`
+	Sku      Sku               `json:"sku"`
+}
+
+func (v *LineItem) Validate() error {
+	return jsvalCompiledCheck0(v, jsval.ReferenceDepth{})
+}
+
+type Quantity int64
+
+func (v *Quantity) Validate() error {
+	return jsvalCompiledCheck1(int64(*v), jsval.ReferenceDepth{})
+}
+
+type Sku string
+
+func (v *Sku) Validate() error {
+	return jsvalCompiledCheck2(string(*v), jsval.ReferenceDepth{})
+}
+
+type Compiled0TypeCustomer struct {
+	Email jsval.MaybeString `json:"email"`
+	Name  string            `json:"name"`
+}
+
+func (v *Compiled0TypeCustomer) Validate() error {
+	return jsvalCompiledCheck3(v, jsval.ReferenceDepth{})
+}
+
+type Compiled0TypeStatus string
+
+const (
+	Compiled0TypeStatusPending Compiled0TypeStatus = "pending"
+	Compiled0TypeStatusShipped Compiled0TypeStatus = "shipped"
+)
+
+func (v *Compiled0TypeStatus) Validate() error {
+	return jsvalCompiledCheck4(string(*v), jsval.ReferenceDepth{})
+}
+
+var Compiled0 *jsval.JSVal
+var jsvalCompiledM *jsval.ConstraintMap
+var jsvalCompiledC0 jsval.Constraint
+var jsvalCompiledC1 jsval.Constraint
+var jsvalCompiledC2 jsval.Constraint
+var jsvalCompiledC3 jsval.Constraint
+var jsvalCompiledC4 jsval.Constraint
+var jsvalCompiledC5 jsval.Constraint
+var jsvalCompiledC6 jsval.Constraint
+var jsvalCompiledC7 jsval.Constraint
+var jsvalCompiledC8 jsval.Constraint
+var jsvalCompiledC9 jsval.Constraint
+var jsvalCompiledC10 jsval.Constraint
+var jsvalCompiledC11 jsval.Constraint
+var jsvalCompiledRx0 = regexp.MustCompile("^[A-Z]{3}-[0-9]{4}$")
+
+func init() {
+	jsvalCompiledM = &jsval.ConstraintMap{}
+	jsvalCompiledM.SetReference("#/definitions/lineItem", jsval.ConstraintFunc(jsvalCompiledCheck0))
+	jsvalCompiledM.SetReference("#/definitions/quantity", jsval.ConstraintFunc(jsvalCompiledCheck1))
+	jsvalCompiledM.SetReference("#/definitions/sku", jsval.ConstraintFunc(jsvalCompiledCheck2))
+	jsvalCompiledC0 = jsval.Integer().Minimum(1).Maximum(100)
+	jsvalCompiledC1 = jsval.String().RegexpString("^[A-Z]{3}-[0-9]{4}$")
+	jsvalCompiledC2 = jsval.String().Enum("pending", "shipped")
+	jsvalCompiledC3 = jsval.String().MaxLength(40)
+	jsvalCompiledC4 = jsval.String().Format("email")
+	jsvalCompiledC5 = jsval.String()
+	jsvalCompiledC6 = jsval.Number()
+	jsvalCompiledC7 = jsval.Boolean()
+	jsvalCompiledC8 = jsval.String().MinLength(1)
+	jsvalCompiledC9 = jsval.Integer().Minimum(0).Default(1)
+	jsvalCompiledC10 = jsval.Number().Minimum(0).Maximum(1000)
+	jsvalCompiledC11 = jsval.String()
+	Compiled0 = jsval.New().
+		SetName("Compiled0").
+		SetConstraintMap(jsvalCompiledM).
+		SetRoot(jsval.ConstraintFunc(jsvalCompiledCheck5))
+}
+
+func jsvalCompiledCheck0(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if x == nil {
+			break
+		}
+		if pv, ok := x["note"]; ok {
+			if err := jsvalCompiledCheck6(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "note")
+			}
+		}
+		if pv, ok := x["quantity"]; ok {
+			if err := jsvalCompiledCheck7(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "quantity")
+			}
+		} else {
+			return jsval.NewValidationError("required", "quantity", v, "object property 'quantity' is required")
+		}
+		if pv, ok := x["sku"]; ok {
+			if err := jsvalCompiledCheck8(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "sku")
+			}
+		} else {
+			return jsval.NewValidationError("required", "sku", v, "object property 'sku' is required")
+		}
+		var extra []string
+		for pname := range x {
+			switch pname {
+			case "note", "quantity", "sku":
+				continue
+			}
+			extra = append(extra, pname)
+		}
+		sort.Strings(extra)
+		if len(extra) > 0 {
+			return jsval.ErrorAt(jsval.NewValidationError("additionalProperties", false, x[extra[0]], "additional properties are not allowed"), extra[0])
+		}
+		return nil
+	case *LineItem:
+		if x == nil {
+			break
+		}
+		if x.Note.Valid() {
+			if err := jsvalCompiledCheck6(x.Note.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "note")
+			}
+		}
+		if err := jsvalCompiledCheck7(int64(x.Quantity), depth); err != nil {
+			return jsval.ErrorAt(jsvalCompiledCheck7(x.Quantity, depth), "quantity")
+		}
+		if err := jsvalCompiledCheck8(string(x.Sku), depth); err != nil {
+			return jsval.ErrorAt(jsvalCompiledCheck8(x.Sku, depth), "sku")
+		}
+		return nil
+	}
+	if m, ok := jsval.JSONValue(v).(map[string]interface{}); ok {
+		return jsvalCompiledCheck0(m, depth)
+	}
+	return jsval.Object().Validate(v)
+}
+
+func jsvalCompiledCheck1(v interface{}, depth jsval.ReferenceDepth) error {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case int64:
+		if n > 1<<53 || n < -(1<<53) {
+			return jsvalCompiledC0.Validate(v)
+		}
+		f = float64(n)
+	default:
+		return jsvalCompiledC0.Validate(v)
+	}
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return jsvalCompiledC0.Validate(v)
+	}
+	if f < 1 {
+		return jsvalCompiledC0.Validate(v)
+	}
+	if f > 100 {
+		return jsvalCompiledC0.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck2(v interface{}, depth jsval.ReferenceDepth) error {
+	s, ok := v.(string)
+	if !ok {
+		return jsvalCompiledC1.Validate(v)
+	}
+	if !jsvalCompiledRx0.MatchString(s) {
+		return jsvalCompiledC1.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck3(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if x == nil {
+			break
+		}
+		if pv, ok := x["email"]; ok {
+			if err := jsvalCompiledCheck9(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "email")
+			}
+		}
+		if pv, ok := x["name"]; ok {
+			if err := jsvalCompiledCheck10(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "name")
+			}
+		} else {
+			return jsval.NewValidationError("required", "name", v, "object property 'name' is required")
+		}
+		return nil
+	case *Compiled0TypeCustomer:
+		if x == nil {
+			break
+		}
+		if x.Email.Valid() {
+			if err := jsvalCompiledCheck9(x.Email.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "email")
+			}
+		}
+		if err := jsvalCompiledCheck10(x.Name, depth); err != nil {
+			return jsval.ErrorAt(err, "name")
+		}
+		return nil
+	}
+	if m, ok := jsval.JSONValue(v).(map[string]interface{}); ok {
+		return jsvalCompiledCheck3(m, depth)
+	}
+	return jsval.Object().Validate(v)
+}
+
+func jsvalCompiledCheck4(v interface{}, depth jsval.ReferenceDepth) error {
+	s, ok := v.(string)
+	if !ok {
+		return jsvalCompiledC2.Validate(v)
+	}
+	switch s {
+	case "pending", "shipped":
+		return nil
+	}
+	return jsvalCompiledC2.Validate(v)
+}
+
+func jsvalCompiledCheck5(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if x == nil {
+			break
+		}
+		if pv, ok := x["customer"]; ok {
+			if err := jsvalCompiledCheck3(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "customer")
+			}
+		}
+		if pv, ok := x["discount"]; ok {
+			if err := jsvalCompiledCheck11(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "discount")
+			}
+		}
+		if pv, ok := x["gift"]; ok {
+			if err := jsvalCompiledCheck12(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "gift")
+			}
+		}
+		if pv, ok := x["id"]; ok {
+			if err := jsvalCompiledCheck13(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "id")
+			}
+		} else {
+			return jsval.NewValidationError("required", "id", v, "object property 'id' is required")
+		}
+		if pv, ok := x["items"]; ok {
+			if err := jsvalCompiledCheck14(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "items")
+			}
+		} else {
+			return jsval.NewValidationError("required", "items", v, "object property 'items' is required")
+		}
+		if pv, ok := x["priority"]; ok {
+			if err := jsvalCompiledCheck15(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "priority")
+			}
+		} else {
+			x["priority"] = float64(1)
+		}
+		if pv, ok := x["status"]; ok {
+			if err := jsvalCompiledCheck4(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "status")
+			}
+		} else {
+			return jsval.NewValidationError("required", "status", v, "object property 'status' is required")
+		}
+		if pv, ok := x["tags"]; ok {
+			if err := jsvalCompiledCheck16(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "tags")
+			}
+		}
+		if pv, ok := x["weight"]; ok {
+			if err := jsvalCompiledCheck17(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "weight")
+			}
+		}
+		return nil
+	case *Compiled0Type:
+		if x == nil {
+			break
+		}
+		if x.Customer != nil {
+			if err := jsvalCompiledCheck3(x.Customer, depth); err != nil {
+				return jsval.ErrorAt(err, "customer")
+			}
+		}
+		if x.Discount.Valid() {
+			if err := jsvalCompiledCheck11(x.Discount.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "discount")
+			}
+		}
+		if x.Gift.Valid() {
+			if err := jsvalCompiledCheck12(x.Gift.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "gift")
+			}
+		}
+		if err := jsvalCompiledCheck13(x.ID, depth); err != nil {
+			return jsval.ErrorAt(err, "id")
+		}
+		if err := jsvalCompiledCheck14(x.Items, depth); err != nil {
+			return jsval.ErrorAt(err, "items")
+		}
+		if x.Priority.Valid() {
+			if err := jsvalCompiledCheck15(x.Priority.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "priority")
+			}
+		} else if err := jsval.AssignValue(&x.Priority, float64(1)); err != nil {
+			return errors.New("failed to set default value for property 'priority': " + err.Error())
+		}
+		if err := jsvalCompiledCheck4(string(x.Status), depth); err != nil {
+			return jsval.ErrorAt(jsvalCompiledCheck4(x.Status, depth), "status")
+		}
+		if len(x.Tags) != 0 {
+			if err := jsvalCompiledCheck16(x.Tags, depth); err != nil {
+				return jsval.ErrorAt(err, "tags")
+			}
+		}
+		if x.Weight.Valid() {
+			if err := jsvalCompiledCheck17(x.Weight.Value(), depth); err != nil {
+				return jsval.ErrorAt(err, "weight")
+			}
+		}
+		return nil
+	}
+	if m, ok := jsval.JSONValue(v).(map[string]interface{}); ok {
+		return jsvalCompiledCheck5(m, depth)
+	}
+	return jsval.Object().Validate(v)
+}
+
+func jsvalCompiledCheck6(v interface{}, depth jsval.ReferenceDepth) error {
+	s, ok := v.(string)
+	if !ok {
+		return jsvalCompiledC3.Validate(v)
+	}
+	if n := utf8.RuneCountInString(s); n > 40 {
+		return jsvalCompiledC3.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck7(v interface{}, depth jsval.ReferenceDepth) error {
+	depth, err := depth.Follow("#/definitions/quantity")
+	if err != nil {
+		return err
+	}
+	return jsvalCompiledCheck1(v, depth)
+}
+
+func jsvalCompiledCheck8(v interface{}, depth jsval.ReferenceDepth) error {
+	depth, err := depth.Follow("#/definitions/sku")
+	if err != nil {
+		return err
+	}
+	return jsvalCompiledCheck2(v, depth)
+}
+
+func jsvalCompiledCheck9(v interface{}, depth jsval.ReferenceDepth) error {
+	return depth.Validate(jsvalCompiledC4, v)
+}
+
+func jsvalCompiledCheck10(v interface{}, depth jsval.ReferenceDepth) error {
+	if _, ok := v.(string); ok {
+		return nil
+	}
+	return jsvalCompiledC5.Validate(v)
+}
+
+func jsvalCompiledCheck11(v interface{}, depth jsval.ReferenceDepth) error {
+	switch n := v.(type) {
+	case int64:
+		return nil
+	case float64:
+		if !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return nil
+		}
+	}
+	return jsvalCompiledC6.Validate(v)
+}
+
+func jsvalCompiledCheck12(v interface{}, depth jsval.ReferenceDepth) error {
+	if _, ok := v.(bool); ok {
+		return nil
+	}
+	return jsvalCompiledC7.Validate(v)
+}
+
+func jsvalCompiledCheck13(v interface{}, depth jsval.ReferenceDepth) error {
+	s, ok := v.(string)
+	if !ok {
+		return jsvalCompiledC8.Validate(v)
+	}
+	if n := utf8.RuneCountInString(s); n < 1 {
+		return jsvalCompiledC8.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck14(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case []interface{}:
+		if x == nil {
+			break
+		}
+		if len(x) < 1 {
+			return jsval.NewValidationError("minItems", 1, len(x), "fewer items than minItems")
+		}
+		for i := range x {
+			if err := jsvalCompiledCheck18(x[i], depth); err != nil {
+				return jsval.ErrorAt(err, strconv.Itoa(i))
+			}
+		}
+		return nil
+	case []LineItem:
+		if x == nil {
+			break
+		}
+		if len(x) < 1 {
+			return jsval.NewValidationError("minItems", 1, len(x), "fewer items than minItems")
+		}
+		for i := range x {
+			if err := jsvalCompiledCheck18(&x[i], depth); err != nil {
+				return jsval.ErrorAt(err, strconv.Itoa(i))
+			}
+		}
+		return nil
+	}
+	if l, ok := jsval.JSONValue(v).([]interface{}); ok {
+		return jsvalCompiledCheck14(l, depth)
+	}
+	return jsval.Array().Validate(v)
+}
+
+func jsvalCompiledCheck15(v interface{}, depth jsval.ReferenceDepth) error {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case int64:
+		if n > 1<<53 || n < -(1<<53) {
+			return jsvalCompiledC9.Validate(v)
+		}
+		f = float64(n)
+	default:
+		return jsvalCompiledC9.Validate(v)
+	}
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return jsvalCompiledC9.Validate(v)
+	}
+	if f < 0 {
+		return jsvalCompiledC9.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck16(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case []interface{}:
+		if x == nil {
+			break
+		}
+		if len(x) > 5 {
+			return jsval.NewValidationError("maxItems", 5, len(x), "more items than maxItems")
+		}
+		for i := range x {
+			if err := jsvalCompiledCheck19(x[i], depth); err != nil {
+				return jsval.ErrorAt(err, strconv.Itoa(i))
+			}
+		}
+		return nil
+	case []string:
+		if x == nil {
+			break
+		}
+		if len(x) > 5 {
+			return jsval.NewValidationError("maxItems", 5, len(x), "more items than maxItems")
+		}
+		for i := range x {
+			if err := jsvalCompiledCheck19(x[i], depth); err != nil {
+				return jsval.ErrorAt(err, strconv.Itoa(i))
+			}
+		}
+		return nil
+	}
+	if l, ok := jsval.JSONValue(v).([]interface{}); ok {
+		return jsvalCompiledCheck16(l, depth)
+	}
+	return jsval.Array().Validate(v)
+}
+
+func jsvalCompiledCheck17(v interface{}, depth jsval.ReferenceDepth) error {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case int64:
+		if n > 1<<53 || n < -(1<<53) {
+			return jsvalCompiledC10.Validate(v)
+		}
+		f = float64(n)
+	default:
+		return jsvalCompiledC10.Validate(v)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return jsvalCompiledC10.Validate(v)
+	}
+	if f < 0 {
+		return jsvalCompiledC10.Validate(v)
+	}
+	if f > 1000 {
+		return jsvalCompiledC10.Validate(v)
+	}
+	return nil
+}
+
+func jsvalCompiledCheck18(v interface{}, depth jsval.ReferenceDepth) error {
+	depth, err := depth.Follow("#/definitions/lineItem")
+	if err != nil {
+		return err
+	}
+	return jsvalCompiledCheck0(v, depth)
+}
+
+func jsvalCompiledCheck19(v interface{}, depth jsval.ReferenceDepth) error {
+	if _, ok := v.(string); ok {
+		return nil
+	}
+	return jsvalCompiledC11.Validate(v)
+}
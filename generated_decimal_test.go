@@ -0,0 +1,39 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import "github.com/lestrrat-go/jsval"
+
+var DecimalV0 *jsval.JSVal
+
+func init() {
+	DecimalV0 = jsval.New().
+		SetName("DecimalV0").
+		SetRoot(
+			jsval.Object().
+				AdditionalProperties(
+					jsval.EmptyConstraint,
+				).
+				AddProp(
+					"count",
+					jsval.Integer().Maximum(1e+21).MultipleOf(3),
+				).
+				AddProp(
+					"n",
+					jsval.Integer().MaximumDecimal("9007199254740993"),
+				).
+				AddProp(
+					"price",
+					jsval.Number().Minimum(0.1).MultipleOf(0.01),
+				).
+				AddProp(
+					"ratio",
+					jsval.Number().Minimum(0.0001).MultipleOf(1e-07),
+				).
+				AddProp(
+					"share",
+					jsval.Number().MaximumDecimal("0.10000000000000000001"),
+				),
+		)
+
+}
@@ -0,0 +1,91 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import "github.com/lestrrat-go/jsval"
+
+var Draft07V0 *jsval.JSVal
+
+func init() {
+	Draft07V0 = jsval.New().
+		SetName("Draft07V0").
+		SetRoot(
+			jsval.All().
+				Add(
+					jsval.If(
+						jsval.Object().
+							Required("kind").
+							AdditionalProperties(
+								jsval.EmptyConstraint,
+							).
+							AddProp(
+								"kind",
+								jsval.Const("circle"),
+							),
+					).
+						Then(
+							jsval.Object().
+								Required("radius").
+								AdditionalProperties(
+									jsval.EmptyConstraint,
+								).
+								AddProp(
+									"radius",
+									jsval.EmptyConstraint,
+								),
+						).
+						Else(
+							jsval.Object().
+								Required("tags").
+								AdditionalProperties(
+									jsval.EmptyConstraint,
+								).
+								AddProp(
+									"tags",
+									jsval.EmptyConstraint,
+								),
+						),
+				).
+				Add(
+					jsval.Object().
+						AdditionalProperties(
+							jsval.EmptyConstraint,
+						).
+						AddProp(
+							"forbidden",
+							jsval.Not(
+								jsval.EmptyConstraint,
+							),
+						).
+						AddProp(
+							"kind",
+							jsval.Const("circle"),
+						).
+						AddProp(
+							"meta",
+							jsval.Object().
+								AdditionalProperties(
+									jsval.EmptyConstraint,
+								).
+								PropertyNames(
+									jsval.String().RegexpString("^[a-z]+$"),
+								),
+						).
+						AddProp(
+							"radius",
+							jsval.Number().Minimum(0).ExclusiveMinimum(true).Maximum(100).ExclusiveMaximum(true),
+						).
+						AddProp(
+							"tags",
+							jsval.Array().
+								AdditionalItems(
+									jsval.EmptyConstraint,
+								).
+								Contains(
+									jsval.String().RegexpString("^shape$"),
+								),
+						),
+				),
+		)
+
+}
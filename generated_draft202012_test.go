@@ -0,0 +1,110 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import "github.com/lestrrat-go/jsval"
+
+var Draft202012V0 *jsval.JSVal
+var Draft202012VM *jsval.ConstraintMap
+var Draft202012VR0 jsval.Constraint
+
+func init() {
+	Draft202012VM = &jsval.ConstraintMap{}
+	Draft202012VR0 = jsval.String().MinLength(1)
+	Draft202012VM.SetReference("#/$defs/name", Draft202012VR0)
+	Draft202012V0 = jsval.New().
+		SetName("Draft202012V0").
+		SetConstraintMap(Draft202012VM).
+		SetRoot(
+			jsval.All().
+				Add(
+					jsval.Object().
+						AdditionalProperties(
+							jsval.EmptyConstraint,
+						).
+						AddProp(
+							"extra",
+							jsval.Boolean(),
+						),
+				).
+				Add(
+					jsval.Object().
+						AdditionalProperties(
+							jsval.EmptyConstraint,
+						).
+						AddProp(
+							"card",
+							jsval.String(),
+						).
+						AddProp(
+							"labels",
+							jsval.Array().
+								AdditionalItems(
+									jsval.EmptyConstraint,
+								).
+								PositionalItems([]jsval.Constraint{
+									jsval.Reference(Draft202012VM).RefersTo("#/$defs/name"),
+								}).
+								Contains(
+									jsval.Const("main"),
+								).
+								UnevaluatedItems(
+									jsval.Not(
+										jsval.EmptyConstraint,
+									),
+								),
+						).
+						AddProp(
+							"owner",
+							jsval.All().
+								Add(
+									jsval.Reference(Draft202012VM).RefersTo("#/$defs/name"),
+								).
+								Add(
+									jsval.String().MaxLength(3),
+								),
+						).
+						AddProp(
+							"point",
+							jsval.Array().
+								AdditionalItems(
+									jsval.Not(
+										jsval.EmptyConstraint,
+									),
+								).
+								PositionalItems([]jsval.Constraint{
+									jsval.Number(),
+									jsval.Number(),
+								}),
+						).
+						PropDependency("card", "billing").
+						SchemaDependency(
+							"billing",
+							jsval.Object().
+								AdditionalProperties(
+									jsval.EmptyConstraint,
+								).
+								AddProp(
+									"billing",
+									jsval.String(),
+								),
+						).
+						UnevaluatedProperties(
+							jsval.Not(
+								jsval.EmptyConstraint,
+							),
+						).
+						Siblings(
+							jsval.Object().
+								AdditionalProperties(
+									jsval.EmptyConstraint,
+								).
+								AddProp(
+									"extra",
+									jsval.Boolean(),
+								),
+						),
+				),
+		)
+
+}
@@ -0,0 +1,60 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import (
+	"github.com/lestrrat-go/jsval"
+)
+
+var Recursive0 *jsval.JSVal
+var jsvalRecursiveM *jsval.ConstraintMap
+var jsvalRecursiveC0 jsval.Constraint
+
+func init() {
+	jsvalRecursiveM = &jsval.ConstraintMap{}
+	jsvalRecursiveM.SetReference("#", jsval.ConstraintFunc(jsvalRecursiveCheck0))
+	jsvalRecursiveC0 = jsval.String()
+	Recursive0 = jsval.New().
+		SetName("Recursive0").
+		SetConstraintMap(jsvalRecursiveM).
+		SetRoot(jsval.ConstraintFunc(jsvalRecursiveCheck0))
+}
+
+func jsvalRecursiveCheck0(v interface{}, depth jsval.ReferenceDepth) error {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if x == nil {
+			break
+		}
+		if pv, ok := x["name"]; ok {
+			if err := jsvalRecursiveCheck1(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "name")
+			}
+		}
+		if pv, ok := x["next"]; ok {
+			if err := jsvalRecursiveCheck2(pv, depth); err != nil {
+				return jsval.ErrorAt(err, "next")
+			}
+		}
+		return nil
+	}
+	if m, ok := jsval.JSONValue(v).(map[string]interface{}); ok {
+		return jsvalRecursiveCheck0(m, depth)
+	}
+	return jsval.Object().Validate(v)
+}
+
+func jsvalRecursiveCheck1(v interface{}, depth jsval.ReferenceDepth) error {
+	if _, ok := v.(string); ok {
+		return nil
+	}
+	return jsvalRecursiveC0.Validate(v)
+}
+
+func jsvalRecursiveCheck2(v interface{}, depth jsval.ReferenceDepth) error {
+	depth, err := depth.Follow("#")
+	if err != nil {
+		return err
+	}
+	return jsvalRecursiveCheck0(v, depth)
+}
@@ -0,0 +1,143 @@
+// Code generated by jsval. DO NOT EDIT.
+
+package jsval_test
+
+import "github.com/lestrrat-go/jsval"
+
+type TypesV0Type struct {
+	Age   *PositiveInteger      `json:"age,omitempty"`
+	Color Color                 `json:"color"`
+	Home  *Address              `json:"home,omitempty"`
+	ID    string                `json:"id"`
+	Name  jsval.MaybeString     `json:"name"`
+	Pets  []TypesV0TypePetsItem `json:"pets,omitempty"`
+	Size  *TypesV0TypeSize      `json:"size,omitempty"`
+}
+
+func (v *TypesV0Type) Validate() error {
+	return TypesV0.Validate(v)
+}
+
+type Address struct {
+	Street string `json:"street"`
+}
+
+func (v *Address) Validate() error {
+	return TypesVR0.Validate(v)
+}
+
+type Color string
+
+const (
+	ColorRed      Color = "red"
+	ColorDarkBlue Color = "dark-blue"
+)
+
+func (v *Color) Validate() error {
+	return TypesVR1.Validate(v)
+}
+
+type PositiveInteger int64
+
+func (v *PositiveInteger) Validate() error {
+	return TypesVR2.Validate(v)
+}
+
+type TypesV0TypePetsItem struct {
+	Name jsval.MaybeString `json:"name"`
+}
+
+func (v *TypesV0TypePetsItem) Validate() error {
+	return TypesVT0.Validate(v)
+}
+
+type TypesV0TypeSize string
+
+const (
+	TypesV0TypeSizeS TypesV0TypeSize = "S"
+	TypesV0TypeSizeM TypesV0TypeSize = "M"
+)
+
+func (v *TypesV0TypeSize) Validate() error {
+	return TypesVT1.Validate(v)
+}
+
+var TypesV0 *jsval.JSVal
+var TypesVM *jsval.ConstraintMap
+var TypesVR0 jsval.Constraint
+var TypesVR1 jsval.Constraint
+var TypesVR2 jsval.Constraint
+var TypesVT0 jsval.Constraint
+var TypesVT1 jsval.Constraint
+
+func init() {
+	TypesVM = &jsval.ConstraintMap{}
+	TypesVT1 = jsval.String().Enum("S", "M")
+	TypesVT0 = jsval.Object().
+		AdditionalProperties(
+			jsval.EmptyConstraint,
+		).
+		AddProp(
+			"name",
+			jsval.String(),
+		)
+	TypesVR0 = jsval.Object().
+		Required("street").
+		AdditionalProperties(
+			jsval.EmptyConstraint,
+		).
+		AddProp(
+			"street",
+			jsval.String(),
+		)
+	TypesVR1 = jsval.String().Enum("red", "dark-blue")
+	TypesVR2 = jsval.Integer().Minimum(1)
+	TypesVM.SetReference("#/definitions/address", TypesVR0)
+	TypesVM.SetReference("#/definitions/color", TypesVR1)
+	TypesVM.SetReference("#/definitions/positive-integer", TypesVR2)
+	TypesV0 = jsval.New().
+		SetName("TypesV0").
+		SetConstraintMap(TypesVM).
+		SetRoot(
+			jsval.Object().
+				Required("color", "id").
+				AdditionalProperties(
+					jsval.EmptyConstraint,
+				).
+				AddProp(
+					"age",
+					jsval.Reference(TypesVM).RefersTo("#/definitions/positive-integer"),
+				).
+				AddProp(
+					"color",
+					jsval.Reference(TypesVM).RefersTo("#/definitions/color"),
+				).
+				AddProp(
+					"home",
+					jsval.Reference(TypesVM).RefersTo("#/definitions/address"),
+				).
+				AddProp(
+					"id",
+					jsval.String(),
+				).
+				AddProp(
+					"name",
+					jsval.String(),
+				).
+				AddProp(
+					"pets",
+					jsval.Array().
+						Items(
+							TypesVT0,
+						).
+						AdditionalItems(
+							jsval.EmptyConstraint,
+						),
+				).
+				AddProp(
+					"size",
+					TypesVT1,
+				),
+		)
+
+}
@@ -14,67 +14,225 @@ import (
 	"unicode"
 )
 
+// NameStrategy specifies how the generator names the variables that
+// hold the validators and the referenced constraints
+type NameStrategy int
+
+const (
+	// NameByIndex names validators `V0`, `V1`, ... and referenced
+	// constraints `R0`, `R1`, ... This is the default
+	NameByIndex NameStrategy = iota
+	// NameByTitle names validators and referenced constraints after
+	// the `title` of their schemas. Referenced constraints without
+	// a title are named after their JSON pointers (see NameByPointer)
+	NameByTitle
+	// NameByPointer names referenced constraints after the last
+	// segment of their JSON pointers, so `#/definitions/positiveInteger`
+	// becomes `PositiveInteger`
+	NameByPointer
+)
+
+// GeneratorOptions holds the options for Generator
+type GeneratorOptions struct {
+	// ImportAlias is the name used to refer to the jsval package from
+	// the generated code. The default is "jsval"
+	ImportAlias string
+	// Package is the name of the package that the generated code
+	// belongs to. If specified, the output is a complete Go source
+	// file, with the package clause and the imports. Otherwise, only
+	// the declarations are generated. Either way, the output starts
+	// with a "Code generated" header
+	Package string
+	// Names specifies how validators and referenced constraints are
+	// named. Validators whose Name is set keep their names
+	Names NameStrategy
+	// Comments enables doc comments generated from the `description`
+	// of the schemas
+	Comments bool
+	// Prefix is prepended to the names of the validators, the
+	// ConstraintMap, the referenced constraints and the constraints that
+	// generated types are bound to, so that the code generated for
+	// several schemas can live in the same package. Validators named by
+	// index are named `<Prefix>0`, `<Prefix>1`, ... instead of `V0`
+	Prefix string
+}
+
 // Generator is responsible for generating Go code that
 // sets up a validator
-type Generator struct{}
+type Generator struct {
+	options GeneratorOptions
+	types   bool
+}
 
 // NewGenerator creates a new Generator
 func NewGenerator() *Generator {
 	return &Generator{}
 }
 
-// Process takes a validator and prints out Go code to out.
+// SetOptions sets the options for the generated code
+func (g *Generator) SetOptions(o GeneratorOptions) *Generator {
+	g.options = o
+	return g
+}
+
+// EmitTypes specifies if Go types should be generated along with
+// the validators. If enabled, a struct type is generated for each
+// object, and a named type for each referenced definition, with a
+// `Validate()` method bound to the generated validator. Properties
+// that are not required use the Maybe types (e.g. `MaybeString`),
+// and string enumerations become typed constants.
+func (g *Generator) EmitTypes(b bool) *Generator {
+	g.types = b
+	return g
+}
+
+// Process takes a validator and prints out Go code to out. The output
+// only depends on the validators and the options, so the same input
+// always generates the same code.
 func (g *Generator) Process(out io.Writer, validators ...*JSVal) error {
+	opts := g.options
 	ctx := genctx{
+		hoisted:  make(map[Constraint]string),
 		pkgname:  "jsval",
+		prefix:   opts.Prefix,
 		refnames: make(map[string]string),
 		vname:    "V",
 	}
+	if opts.ImportAlias != "" {
+		ctx.pkgname = opts.ImportAlias
+	}
 
 	buf := bytes.Buffer{}
 
 	// First get all of the references so we can refer to it later
 	refs := map[string]Constraint{}
+	annotations := map[string]Annotation{}
 	refnames := []string{}
 	valnames := []string{}
+	used := map[string]struct{}{}
+	for _, v := range validators {
+		if v.Name != "" {
+			used[v.Name] = struct{}{}
+		}
+	}
 	for i, v := range validators {
 		for rname, rc := range v.refs {
 			if _, ok := refs[rname]; ok {
 				continue
 			}
 			refs[rname] = rc
+			annotations[rname] = v.GetAnnotation(rname)
 			refnames = append(refnames, rname)
 		}
 
 		if v.Name == "" {
-			v.Name = fmt.Sprintf("V%d", i)
+			if name := exportedName(v.Title); name != "" && opts.Names == NameByTitle {
+				v.Name = uniqueVarName(used, opts.Prefix+name)
+			}
+			if v.Name == "" {
+				v.Name = uniqueVarName(used, validatorName(opts.Prefix, i))
+			}
 		}
 		valnames = append(valnames, v.Name)
 	}
 
-	sort.Strings(valnames)
-	for _, vname := range valnames {
-		fmt.Fprintf(&buf, "\nvar %s *%s.JSVal", vname, ctx.pkgname)
+	sort.Sort(JSValSlice(validators))
+	for _, v := range validators {
+		if opts.Comments {
+			generateDocComment(&buf, v.Description)
+		}
+		fmt.Fprintf(&buf, "\nvar %s *%s.JSVal", v.Name, ctx.pkgname)
 	}
 
 	ctx.refs = refs
 	if len(refs) > 0 { // have refs
-		ctx.cmname = "M"
+		ctx.cmname = uniqueVarName(used, opts.Prefix+"M")
 		// sort them by reference name
 		sort.Strings(refnames)
+		ctx.refnamelist = refnames
 		fmt.Fprintf(&buf, "\nvar %s *%s.ConstraintMap", ctx.cmname, ctx.pkgname)
 
 		// Generate reference constraint names
 		for i, rname := range refnames {
-			vname := fmt.Sprintf("R%d", i)
+			var vname string
+			switch opts.Names {
+			case NameByTitle:
+				vname = exportedName(annotations[rname].Title)
+				if vname == "" {
+					vname = refTypeName(rname)
+				}
+			case NameByPointer:
+				vname = refTypeName(rname)
+			}
+			if vname == "" {
+				vname = fmt.Sprintf("R%d", i)
+			}
+			vname = uniqueVarName(used, opts.Prefix+vname)
 			ctx.refnames[rname] = vname
+			if opts.Comments {
+				generateDocComment(&buf, annotations[rname].Description)
+			}
 			fmt.Fprintf(&buf, "\nvar %s %s.Constraint", vname, ctx.pkgname)
 		}
 	}
 
+	var tc *typectx
+	if g.types {
+		tc = newTypectx(&ctx)
+		for _, vname := range valnames {
+			tc.reserve(vname)
+		}
+		for _, rname := range refnames {
+			tc.reserve(ctx.refnames[rname])
+		}
+		tc.reserve(ctx.cmname)
+
+		for _, v := range validators {
+			if name := tc.declare(v.root, v.Name+"Type", v.Name); name != "" && opts.Comments {
+				tc.docs[name] = v.Description
+			}
+		}
+		for _, rname := range refnames {
+			// Referenced constraints may have been named after their
+			// definitions already
+			tname := refTypeName(rname)
+			if _, ok := tc.used[tname]; ok && tname != "" {
+				tname += "Type"
+			}
+			if name := tc.declare(refs[rname], tname, ctx.refnames[rname]); name != "" {
+				tc.refnames[rname] = name
+				if opts.Comments {
+					tc.docs[name] = annotations[rname].Description
+				}
+			}
+		}
+		tc.build()
+
+		for _, t := range tc.hoisted {
+			fmt.Fprintf(&buf, "\nvar %s %s.Constraint", t.vname, ctx.pkgname)
+		}
+	}
+
 	fmt.Fprintf(&buf, "\nfunc init() {")
 	if len(refs) > 0 {
 		fmt.Fprintf(&buf, "\n%s = &%s.ConstraintMap{}", ctx.cmname, ctx.pkgname)
+	}
+
+	if tc != nil {
+		// Constraints for nested types are generated first, so that
+		// they can be referred to by name. Nested types are always
+		// found after their parents
+		for i := len(tc.hoisted) - 1; i >= 0; i-- {
+			t := tc.hoisted[i]
+			fmt.Fprintf(&buf, "\n%s = ", t.vname)
+			if err := generateCode(&ctx, &buf, t.c); err != nil {
+				return err
+			}
+			ctx.hoisted[t.c] = t.vname
+		}
+	}
+
+	if len(refs) > 0 {
 		// Now generate code for references
 		for _, rname := range refnames {
 			fmt.Fprintf(&buf, "\n%s = ", ctx.refnames[rname])
@@ -99,7 +257,6 @@ func (g *Generator) Process(out io.Writer, validators ...*JSVal) error {
 	}
 
 	// Now dump the validators
-	sort.Sort(JSValSlice(validators))
 	for _, v := range validators {
 		fmt.Fprintf(&buf, "\n%s = ", v.Name)
 		if err := generateCode(&ctx, &buf, v); err != nil {
@@ -108,6 +265,26 @@ func (g *Generator) Process(out io.Writer, validators ...*JSVal) error {
 	}
 	fmt.Fprintf(&buf, "\n}")
 
+	if tc != nil {
+		var tbuf bytes.Buffer
+		tc.generate(&tbuf)
+		buf.WriteTo(&tbuf)
+		buf = tbuf
+	}
+
+	var hbuf bytes.Buffer
+	fmt.Fprintf(&hbuf, "// Code generated by jsval. DO NOT EDIT.\n")
+	if opts.Package != "" {
+		fmt.Fprintf(&hbuf, "\npackage %s", opts.Package)
+		fmt.Fprintf(&hbuf, "\n\nimport ")
+		if ctx.pkgname != "jsval" {
+			fmt.Fprintf(&hbuf, "%s ", ctx.pkgname)
+		}
+		fmt.Fprintf(&hbuf, "%s\n", strconv.Quote(jsvalImportPath))
+	}
+	buf.WriteTo(&hbuf)
+	buf = hbuf
+
 	fsrc, err := format.Source(buf.Bytes())
 	if err != nil {
 		os.Stderr.Write(buf.Bytes())
@@ -117,12 +294,53 @@ func (g *Generator) Process(out io.Writer, validators ...*JSVal) error {
 	return nil
 }
 
+// uniqueVarName returns name, or name followed by a number if name
+// is already used, and marks the result as used
+// validatorName returns the name of the i-th validator that is
+// named by index
+func validatorName(prefix string, i int) string {
+	if prefix == "" {
+		return fmt.Sprintf("V%d", i)
+	}
+	return fmt.Sprintf("%s%d", prefix, i)
+}
+
+func uniqueVarName(used map[string]struct{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := used[candidate]; !ok {
+			break
+		}
+		candidate = name + strconv.Itoa(i)
+	}
+	used[candidate] = struct{}{}
+	return candidate
+}
+
+// generateDocComment prints out s as a comment
+func generateDocComment(out io.Writer, s string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(out, "\n")
+	for _, line := range strings.Split(s, "\n") {
+		fmt.Fprintf(out, "\n// %s", strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+}
+
 type genctx struct {
-	cmname   string
-	pkgname  string
-	refs     map[string]Constraint
-	refnames map[string]string
-	vname    string
+	cmname      string
+	hoisted     map[Constraint]string
+	pkgname     string
+	prefix      string
+	refs        map[string]Constraint
+	refnames    map[string]string
+	refnamelist []string // sorted keys of refs
+	vname       string
 }
 
 func generateEmptyCode(ctx *genctx, out io.Writer, c emptyConstraint) error {
@@ -143,8 +361,8 @@ func generateValidatorCode(ctx *genctx, out io.Writer, v *JSVal) error {
 		fmt.Fprintf(out, ".\nSetConstraintMap(%s)", cmname)
 	}
 
-	for rname, rc := range ctx.refs {
-		if v.root == rc {
+	for _, rname := range ctx.refnamelist {
+		if v.root == ctx.refs[rname] {
 			fmt.Fprintf(out, ".\nSetRoot(%s)", ctx.refnames[rname])
 			found = true
 			break
@@ -165,6 +383,13 @@ func generateValidatorCode(ctx *genctx, out io.Writer, v *JSVal) error {
 func generateCode(ctx *genctx, out io.Writer, c interface {
 	Validate(interface{}) error
 }) error {
+	if cc, ok := c.(Constraint); ok {
+		if name, ok := ctx.hoisted[cc]; ok {
+			fmt.Fprint(out, name)
+			return nil
+		}
+	}
+
 	buf := &bytes.Buffer{}
 
 	switch c.(type) {
@@ -196,6 +421,18 @@ func generateCode(ctx *genctx, out io.Writer, c interface {
 		if err := generateBooleanCode(ctx, buf, c.(*BooleanConstraint)); err != nil {
 			return err
 		}
+	case *ConstConstraint:
+		if err := generateConstCode(ctx, buf, c.(*ConstConstraint)); err != nil {
+			return err
+		}
+	case *EnumConstraint:
+		if err := generateEnumConstraintCode(ctx, buf, c.(*EnumConstraint)); err != nil {
+			return err
+		}
+	case *IfThenElseConstraint:
+		if err := generateIfThenElseCode(ctx, buf, c.(*IfThenElseConstraint)); err != nil {
+			return err
+		}
 	case *IntegerConstraint:
 		if err := generateIntegerCode(ctx, buf, c.(*IntegerConstraint)); err != nil {
 			return err
@@ -270,7 +507,7 @@ func generateIntegerCode(ctx *genctx, out io.Writer, c *IntegerConstraint) error
 	fmt.Fprintf(out, "%s.Integer()", ctx.pkgname)
 
 	if c.applyMinimum {
-		fmt.Fprintf(out, ".Minimum(%d)", int(c.minimum))
+		generateDecimalCode(out, "Minimum", c.minimum)
 	}
 
 	if c.exclusiveMinimum {
@@ -278,13 +515,17 @@ func generateIntegerCode(ctx *genctx, out io.Writer, c *IntegerConstraint) error
 	}
 
 	if c.applyMaximum {
-		fmt.Fprintf(out, ".Maximum(%d)", int(c.maximum))
+		generateDecimalCode(out, "Maximum", c.maximum)
 	}
 
 	if c.exclusiveMaximum {
 		fmt.Fprintf(out, ".ExclusiveMaximum(true)")
 	}
 
+	if c.applyMultipleOf {
+		generateDecimalCode(out, "MultipleOf", c.multipleOf)
+	}
+
 	if c.HasDefault() {
 		fmt.Fprintf(out, ".Default(%d)", int(c.DefaultValue().(float64)))
 	}
@@ -292,11 +533,22 @@ func generateIntegerCode(ctx *genctx, out io.Writer, c *IntegerConstraint) error
 	return nil
 }
 
+// generateDecimalCode generates the call to the setter for a number
+// in a NumberConstraint. Numbers that were given as decimal literals
+// are kept as such
+func generateDecimalCode(out io.Writer, method string, d decimal) {
+	if d.exact {
+		fmt.Fprintf(out, ".%sDecimal(%s)", method, strconv.Quote(d.literal))
+		return
+	}
+	fmt.Fprintf(out, ".%s(%s)", method, d.literal)
+}
+
 func generateNumberCode(ctx *genctx, out io.Writer, c *NumberConstraint) error {
 	fmt.Fprintf(out, "%s.Number()", ctx.pkgname)
 
 	if c.applyMinimum {
-		fmt.Fprintf(out, ".Minimum(%f)", c.minimum)
+		generateDecimalCode(out, "Minimum", c.minimum)
 	}
 
 	if c.exclusiveMinimum {
@@ -304,13 +556,17 @@ func generateNumberCode(ctx *genctx, out io.Writer, c *NumberConstraint) error {
 	}
 
 	if c.applyMaximum {
-		fmt.Fprintf(out, ".Maximum(%f)", c.maximum)
+		generateDecimalCode(out, "Maximum", c.maximum)
 	}
 
 	if c.exclusiveMaximum {
 		fmt.Fprintf(out, ".ExclusiveMaximum(true)")
 	}
 
+	if c.applyMultipleOf {
+		generateDecimalCode(out, "MultipleOf", c.multipleOf)
+	}
+
 	if c.HasDefault() {
 		fmt.Fprintf(out, ".Default(%f)", c.DefaultValue())
 	}
@@ -331,7 +587,9 @@ func generateEnumCode(ctx *genctx, out io.Writer, c *EnumConstraint) error {
 		case reflect.Float32, reflect.Float64:
 			fmt.Fprintf(out, "%f", rv.Float())
 		default:
-			return fmt.Errorf("failed to stringify enum value %#v", rv.Interface())
+			if err := generateValueCode(out, v); err != nil {
+				return err
+			}
 		}
 		if i < l-1 {
 			fmt.Fprintf(out, ", ")
@@ -413,6 +671,14 @@ func generateObjectCode(ctx *genctx, out io.Writer, c *ObjectConstraint) error {
 		fmt.Fprintf(out, ",\n)")
 	}
 
+	if pc := c.propertyNames; pc != nil {
+		fmt.Fprintf(out, ".\nPropertyNames(\n")
+		if err := generateCode(ctx, out, pc); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, ",\n)")
+	}
+
 	pnames := make([]string, 0, len(c.properties))
 	for pname := range c.properties {
 		pnames = append(pnames, pname)
@@ -465,6 +731,46 @@ func generateObjectCode(ctx *genctx, out io.Writer, c *ObjectConstraint) error {
 		}
 	}
 
+	if m := c.schemadeps; len(m) > 0 {
+		keys := make([]string, 0, len(m))
+		for from := range m {
+			keys = append(keys, from)
+		}
+		sort.Strings(keys)
+
+		for _, from := range keys {
+			fmt.Fprintf(out, ".\nSchemaDependency(\n%s,\n", strconv.Quote(from))
+			if err := generateCode(ctx, out, m[from]); err != nil {
+				return err
+			}
+			fmt.Fprint(out, ",\n)")
+		}
+	}
+
+	if uc := c.unevaluatedProperties; uc != nil {
+		fmt.Fprint(out, ".\nUnevaluatedProperties(\n")
+		if err := generateCode(ctx, out, uc); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n)")
+	}
+
+	return generateSiblingsCode(ctx, out, c.siblings)
+}
+
+func generateSiblingsCode(ctx *genctx, out io.Writer, l []Constraint) error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	fmt.Fprint(out, ".\nSiblings(\n")
+	for _, c1 := range l {
+		if err := generateCode(ctx, out, c1); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n")
+	}
+	fmt.Fprint(out, ")")
 	return nil
 }
 
@@ -496,6 +802,13 @@ func generateArrayCode(ctx *genctx, out io.Writer, c *ArrayConstraint) error {
 		}
 		fmt.Fprint(out, "})")
 	}
+	if cc := c.contains; cc != nil {
+		fmt.Fprint(out, ".\nContains(\n")
+		if err := generateCode(ctx, out, cc); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n)")
+	}
 	if c.minItems > -1 {
 		fmt.Fprintf(out, ".\nMinItems(%d)", c.minItems)
 	}
@@ -505,7 +818,14 @@ func generateArrayCode(ctx *genctx, out io.Writer, c *ArrayConstraint) error {
 	if c.uniqueItems {
 		fmt.Fprint(out, ".\nUniqueItems(true)")
 	}
-	return nil
+	if cc := c.unevaluatedItems; cc != nil {
+		fmt.Fprint(out, ".\nUnevaluatedItems(\n")
+		if err := generateCode(ctx, out, cc); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n)")
+	}
+	return generateSiblingsCode(ctx, out, c.siblings)
 }
 
 func generateBooleanCode(ctx *genctx, out io.Writer, c *BooleanConstraint) error {
@@ -521,6 +841,99 @@ func generateNotCode(ctx *genctx, out io.Writer, c *NotConstraint) error {
 	if err := generateCode(ctx, out, c.child); err != nil {
 		return err
 	}
-	fmt.Fprint(out, "\n)")
+	fmt.Fprint(out, ",\n)")
+	return nil
+}
+
+func generateConstCode(ctx *genctx, out io.Writer, c *ConstConstraint) error {
+	fmt.Fprintf(out, "%s.Const(", ctx.pkgname)
+	if err := generateValueCode(out, c.value); err != nil {
+		return err
+	}
+	fmt.Fprint(out, ")")
+	return nil
+}
+
+func generateEnumConstraintCode(ctx *genctx, out io.Writer, c *EnumConstraint) error {
+	fmt.Fprintf(out, "%s.Enum(", ctx.pkgname)
+	for _, v := range c.enums {
+		if err := generateValueCode(out, v); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ", ")
+	}
+	fmt.Fprint(out, ")")
+	return nil
+}
+
+func generateIfThenElseCode(ctx *genctx, out io.Writer, c *IfThenElseConstraint) error {
+	if c.cond == nil {
+		return generateEmptyCode(ctx, out, EmptyConstraint)
+	}
+
+	fmt.Fprintf(out, "%s.If(\n", ctx.pkgname)
+	if err := generateCode(ctx, out, c.cond); err != nil {
+		return err
+	}
+	fmt.Fprint(out, ",\n)")
+
+	if c.then != nil {
+		fmt.Fprint(out, ".\nThen(\n")
+		if err := generateCode(ctx, out, c.then); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n)")
+	}
+
+	if c.els != nil {
+		fmt.Fprint(out, ".\nElse(\n")
+		if err := generateCode(ctx, out, c.els); err != nil {
+			return err
+		}
+		fmt.Fprint(out, ",\n)")
+	}
+	return nil
+}
+
+// generateValueCode generates a Go literal for values decoded from JSON
+func generateValueCode(out io.Writer, v interface{}) error {
+	switch v.(type) {
+	case nil:
+		fmt.Fprint(out, "nil")
+	case bool:
+		fmt.Fprintf(out, "%t", v.(bool))
+	case string:
+		fmt.Fprint(out, strconv.Quote(v.(string)))
+	case float64:
+		fmt.Fprintf(out, "float64(%s)", strconv.FormatFloat(v.(float64), 'g', -1, 64))
+	case []interface{}:
+		fmt.Fprint(out, "[]interface{}{")
+		for _, e := range v.([]interface{}) {
+			if err := generateValueCode(out, e); err != nil {
+				return err
+			}
+			fmt.Fprint(out, ", ")
+		}
+		fmt.Fprint(out, "}")
+	case map[string]interface{}:
+		m := v.(map[string]interface{})
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprint(out, "map[string]interface{}{")
+		for _, k := range keys {
+			fmt.Fprintf(out, "%s: ", strconv.Quote(k))
+			if err := generateValueCode(out, m[k]); err != nil {
+				return err
+			}
+			fmt.Fprint(out, ", ")
+		}
+		fmt.Fprint(out, "}")
+	default:
+		return fmt.Errorf("failed to generate code for value %#v", v)
+	}
 	return nil
 }
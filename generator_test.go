@@ -0,0 +1,255 @@
+package jsval_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkGenerated makes sure that the code generated for v, with the
+// names prefixed by prefix, is well formatted Go, and that it is what
+// `jsval generate` wrote to fn (see generate.json), so that tests can
+// run the compiled copy of v as well
+func checkGenerated(t *testing.T, v *jsval.JSVal, prefix, fn string) bool {
+	g := jsval.NewGenerator().SetOptions(jsval.GeneratorOptions{Package: "jsval_test", Prefix: prefix})
+	return checkGeneratedCode(t, g, v, fn)
+}
+
+// checkGeneratedCode is like checkGenerated, with the code generated
+// by g
+func checkGeneratedCode(t *testing.T, g *jsval.Generator, v *jsval.JSVal, fn string) bool {
+	var buf bytes.Buffer
+	if !assert.NoError(t, g.Process(&buf, v), "Generator.Process should succeed") {
+		return false
+	}
+
+	fsrc, err := format.Source(buf.Bytes())
+	if !assert.NoError(t, err, "generated code should be valid Go") {
+		return false
+	}
+	if !assert.Equal(t, string(fsrc), buf.String(), "generated code should be formatted") {
+		return false
+	}
+
+	expected, err := ioutil.ReadFile(fn)
+	if !assert.NoError(t, err, "ioutil.ReadFile should succeed") {
+		return false
+	}
+	return assert.Equal(t, string(expected), buf.String(), "%s should be up to date (run go generate)", fn)
+}
+
+func TestGenerateTypes(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/types-schema.json")
+	if !assert.NoError(t, err, "ioutil.ReadFile should succeed") {
+		return
+	}
+
+	v, ok := buildFromJSON(t, string(src))
+	if !ok {
+		return
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var buf bytes.Buffer
+		if !assert.NoError(t, jsval.NewGenerator().Process(&buf, v), "Generator.Process should succeed") {
+			return
+		}
+		if !assert.False(t, strings.Contains(buf.String(), "type "), "no types are generated") {
+			return
+		}
+	})
+
+	g := jsval.NewGenerator().SetOptions(jsval.GeneratorOptions{Package: "jsval_test", Prefix: "TypesV"}).EmitTypes(true)
+	if !checkGeneratedCode(t, g, v.SetName("TypesV0"), "generated_types_test.go") {
+		return
+	}
+
+	t.Run("Validate", func(t *testing.T) {
+		age := PositiveInteger(0)
+		size := TypesV0TypeSize("L")
+		for _, o := range []*TypesV0Type{
+			{ID: "1", Color: ColorDarkBlue, Age: &age},
+			{ID: "1", Color: Color("green")},
+			{ID: "1", Color: ColorRed, Size: &size},
+		} {
+			if !assert.Error(t, o.Validate(), "%#v should be invalid", o) {
+				return
+			}
+		}
+
+		age = 2
+		o := &TypesV0Type{
+			ID:    "1",
+			Color: ColorRed,
+			Age:   &age,
+			Home:  &Address{Street: "Main St"},
+			Pets:  []TypesV0TypePetsItem{{}},
+		}
+		if !assert.NoError(t, o.Validate(), "%#v should be valid", o) {
+			return
+		}
+		if !assert.NoError(t, (&Address{}).Validate(), "Address.Validate should succeed") {
+			return
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		const doc = `{"id": "1", "name": "Bob", "age": 3, "color": "red", "home": {"street": "Main St"}, "size": "S", "pets": [{"name": "Rex"}]}`
+		var o TypesV0Type
+		if !assert.NoError(t, json.Unmarshal([]byte(doc), &o), "json.Unmarshal should succeed") {
+			return
+		}
+		if !assert.NoError(t, o.Validate(), "Validate should succeed") {
+			return
+		}
+		if !assert.Equal(t, "Rex", o.Pets[0].Name.Value(), "nested types are decoded") {
+			return
+		}
+		if !assert.NoError(t, v.ValidateJSON([]byte(doc)), "the validator agrees") {
+			return
+		}
+	})
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	const src = `{
+  "title": "order",
+  "description": "An order placed by a customer",
+  "definitions": {
+    "positiveInteger": { "type": "integer", "minimum": 1, "description": "A positive integer" },
+    "address": { "title": "postal address", "type": "object", "properties": { "street": { "type": "string" } } },
+    "name": { "type": "string" }
+  },
+  "type": "object",
+  "properties": {
+    "qty": { "$ref": "#/definitions/positiveInteger" },
+    "home": { "$ref": "#/definitions/address" },
+    "name": { "$ref": "#/definitions/name" }
+  }
+}`
+	s, err := schema.Read(strings.NewReader(src))
+	if !assert.NoError(t, err, "schema.Read should succeed") {
+		return
+	}
+
+	build := func() *jsval.JSVal {
+		v, err := builder.New().Build(s)
+		if !assert.NoError(t, err, "builder.Build should succeed") {
+			return nil
+		}
+		return v
+	}
+
+	v := build()
+	if v == nil {
+		return
+	}
+	if !assert.Equal(t, "order", v.Title, "title is copied from the schema") {
+		return
+	}
+	if !assert.Equal(t, "A positive integer", v.GetAnnotation("#/definitions/positiveInteger").Description, "description of the reference is recorded") {
+		return
+	}
+
+	generate := func(opts jsval.GeneratorOptions) string {
+		var buf bytes.Buffer
+		if !assert.NoError(t, jsval.NewGenerator().SetOptions(opts).Process(&buf, build()), "Generator.Process should succeed") {
+			return ""
+		}
+		return buf.String()
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		code := generate(jsval.GeneratorOptions{})
+		for _, s := range []string{"var V0 *jsval.JSVal", "var R0 jsval.Constraint", "R2 = jsval.Integer().Minimum(1)"} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+		if !assert.True(t, strings.HasPrefix(code, "// Code generated by jsval. DO NOT EDIT.\n\n"), "header is generated") {
+			return
+		}
+		for _, s := range []string{"package ", "import ", "// A positive integer"} {
+			if !assert.NotContains(t, code, s, "generated code does not contain %q", s) {
+				return
+			}
+		}
+	})
+
+	t.Run("Package", func(t *testing.T) {
+		code := generate(jsval.GeneratorOptions{Package: "foo", ImportAlias: "js"})
+		if !assert.True(t, strings.HasPrefix(code, "// Code generated by jsval. DO NOT EDIT.\n\npackage foo\n\nimport js \"github.com/lestrrat-go/jsval\"\n"), "header, package and import are generated") {
+			return
+		}
+		if !assert.Contains(t, code, "var V0 *js.JSVal", "import alias is used") {
+			return
+		}
+	})
+
+	t.Run("NameByTitle", func(t *testing.T) {
+		code := generate(jsval.GeneratorOptions{Names: jsval.NameByTitle, Comments: true})
+		for _, s := range []string{
+			"// An order placed by a customer\nvar Order *jsval.JSVal",
+			"var PostalAddress jsval.Constraint",
+			"var Name jsval.Constraint",
+			"// A positive integer\nvar PositiveInteger jsval.Constraint",
+			"M.SetReference(\"#/definitions/address\", PostalAddress)",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+	})
+
+	t.Run("NameByPointer", func(t *testing.T) {
+		code := generate(jsval.GeneratorOptions{Names: jsval.NameByPointer})
+		for _, s := range []string{
+			"var V0 *jsval.JSVal",
+			"var Address jsval.Constraint",
+			"PositiveInteger = jsval.Integer().Minimum(1)",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		code := generate(jsval.GeneratorOptions{Prefix: "Order", Names: jsval.NameByPointer})
+		for _, s := range []string{
+			"var Order0 *jsval.JSVal",
+			"var OrderM *jsval.ConstraintMap",
+			"var OrderAddress jsval.Constraint",
+			"OrderM.SetReference(\"#/definitions/address\", OrderAddress)",
+		} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+
+		code = generate(jsval.GeneratorOptions{Prefix: "My", Names: jsval.NameByTitle})
+		for _, s := range []string{"var MyOrder *jsval.JSVal", "var MyPostalAddress jsval.Constraint"} {
+			if !assert.Contains(t, code, s, "generated code contains %q", s) {
+				return
+			}
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		opts := jsval.GeneratorOptions{Package: "foo", Names: jsval.NameByTitle, Comments: true}
+		expected := generate(opts)
+		for i := 0; i < 10; i++ {
+			if !assert.Equal(t, expected, generate(opts), "output should be the same every time") {
+				return
+			}
+		}
+	})
+}
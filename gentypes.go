@@ -0,0 +1,376 @@
+package jsval
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// gentype describes a Go type generated for a constraint
+type gentype struct {
+	name   string
+	base   string // underlying type, for non-struct types
+	c      Constraint
+	enums  []string
+	fields []genfield
+	vname  string // name of the variable that Validate() is bound to
+	call   string // function called by Validate(), if not vname.Validate
+	arg    string // argument passed to call, if not the receiver
+}
+
+type genfield struct {
+	name      string
+	typ       string
+	pname     string
+	c         Constraint
+	omitempty bool
+}
+
+// typectx holds the state while Go types for the constraints are
+// being generated
+type typectx struct {
+	gen       *genctx
+	names     map[Constraint]string
+	docs      map[string]string // doc comments, by type name
+	refnames  map[string]string
+	resolving map[string]bool
+	used      map[string]struct{}
+	types     []*gentype
+	hoisted   []*gentype
+}
+
+func newTypectx(gen *genctx) *typectx {
+	return &typectx{
+		gen:       gen,
+		names:     make(map[Constraint]string),
+		docs:      make(map[string]string),
+		refnames:  make(map[string]string),
+		resolving: make(map[string]bool),
+		used:      make(map[string]struct{}),
+	}
+}
+
+// reserve marks name as used, so that no generated type or
+// constant is given the same name
+func (tc *typectx) reserve(name string) {
+	tc.used[name] = struct{}{}
+}
+
+func (tc *typectx) uniqueName(name string) string {
+	if name == "" {
+		name = "X"
+	}
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := tc.used[candidate]; !ok {
+			break
+		}
+		candidate = name + strconv.Itoa(i)
+	}
+	tc.reserve(candidate)
+	return candidate
+}
+
+// declare registers a named type for the root of a validator or
+// a referenced definition. The type's Validate() method is bound
+// to the variable vname, which holds the constraint c
+func (tc *typectx) declare(c Constraint, name, vname string) string {
+	typed := typedConstraint(c)
+	switch typed.(type) {
+	case nil, *ReferenceConstraint:
+		return ""
+	}
+	if name, ok := tc.names[typed]; ok {
+		return name
+	}
+
+	t := &gentype{name: tc.uniqueName(name), c: typed, vname: vname}
+	tc.names[typed] = t.name
+	tc.types = append(tc.types, t)
+	return t.name
+}
+
+// nested registers a named type for an object or an enumeration
+// that appears inside of another type. Its constraint is hoisted
+// to a variable of its own, so that Validate() can be bound to it
+func (tc *typectx) nested(c Constraint, name string) string {
+	if name, ok := tc.names[c]; ok {
+		return name
+	}
+
+	t := &gentype{
+		name:  tc.uniqueName(name),
+		c:     c,
+		vname: fmt.Sprintf("%sT%d", tc.gen.prefix, len(tc.hoisted)),
+	}
+	tc.names[c] = t.name
+	tc.types = append(tc.types, t)
+	tc.hoisted = append(tc.hoisted, t)
+	return t.name
+}
+
+// build fills in the fields, the enumerations or the underlying type
+// of each type. Building a type may discover new nested types, which
+// are built in turn
+func (tc *typectx) build() {
+	for i := 0; i < len(tc.types); i++ {
+		t := tc.types[i]
+		switch c := t.c.(type) {
+		case *ObjectConstraint:
+			if len(c.properties) == 0 {
+				t.base = "map[string]interface{}"
+				continue
+			}
+			tc.buildFields(t, c)
+		case *StringConstraint, *EnumConstraint:
+			t.base = "string"
+			t.enums = stringEnums(c)
+		default:
+			t.base = tc.goType(c, t.name)
+		}
+	}
+}
+
+func (tc *typectx) buildFields(t *gentype, c *ObjectConstraint) {
+	pnames := make([]string, 0, len(c.properties))
+	for pname := range c.properties {
+		pnames = append(pnames, pname)
+	}
+	sort.Strings(pnames)
+
+	fnames := map[string]struct{}{}
+	for _, pname := range pnames {
+		fname := exportedName(pname)
+		candidate := fname
+		for i := 2; ; i++ {
+			if _, ok := fnames[candidate]; !ok {
+				break
+			}
+			candidate = fname + strconv.Itoa(i)
+		}
+		fnames[candidate] = struct{}{}
+
+		f := genfield{
+			name:  candidate,
+			pname: pname,
+			c:     c.properties[pname],
+			typ:   tc.goType(c.properties[pname], t.name+fname),
+		}
+		if !c.IsPropRequired(pname) {
+			switch f.typ {
+			case "bool":
+				f.typ = tc.gen.pkgname + ".MaybeBool"
+			case "float64":
+				f.typ = tc.gen.pkgname + ".MaybeFloat"
+			case "int64":
+				f.typ = tc.gen.pkgname + ".MaybeInt"
+			case "string":
+				f.typ = tc.gen.pkgname + ".MaybeString"
+			case "interface{}":
+				f.omitempty = true
+			default:
+				if !strings.HasPrefix(f.typ, "[]") && !strings.HasPrefix(f.typ, "map[") {
+					f.typ = "*" + f.typ
+				}
+				f.omitempty = true
+			}
+		}
+		t.fields = append(t.fields, f)
+	}
+}
+
+// goType returns the Go type to use for values matching c. Objects
+// and string enumerations that have not been named yet become nested
+// types, using hint as their name
+func (tc *typectx) goType(c Constraint, hint string) string {
+	switch c := typedConstraint(c).(type) {
+	case *ReferenceConstraint:
+		if name, ok := tc.refnames[c.reference]; ok {
+			return name
+		}
+		rc, ok := tc.gen.refs[c.reference]
+		if !ok || tc.resolving[c.reference] {
+			return "interface{}"
+		}
+		tc.resolving[c.reference] = true
+		defer delete(tc.resolving, c.reference)
+		return tc.goType(rc, hint)
+	case *ObjectConstraint:
+		if len(c.properties) == 0 {
+			return "map[string]interface{}"
+		}
+		return tc.nested(c, hint)
+	case *StringConstraint:
+		if len(stringEnums(c)) > 0 {
+			return tc.nested(c, hint)
+		}
+		return "string"
+	case *EnumConstraint:
+		return tc.nested(c, hint)
+	case *IntegerConstraint:
+		return "int64"
+	case *NumberConstraint:
+		return "float64"
+	case *BooleanConstraint:
+		return "bool"
+	case *ArrayConstraint:
+		if c.items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + tc.goType(c.items, hint+"Item")
+	}
+	return "interface{}"
+}
+
+// typedConstraint returns the constraint that determines the Go type
+// of the values matching c, or nil if there is no such constraint
+func typedConstraint(c Constraint) Constraint {
+	switch c := c.(type) {
+	case *AllConstraint:
+		var found Constraint
+		for _, c1 := range c.constraints {
+			t := typedConstraint(c1)
+			if t == nil {
+				continue
+			}
+			if found != nil {
+				return nil
+			}
+			found = t
+		}
+		return found
+	case *EnumConstraint:
+		if len(stringEnums(c)) == 0 {
+			return nil
+		}
+		return c
+	case *ArrayConstraint, *BooleanConstraint, *IntegerConstraint, *NumberConstraint, *ObjectConstraint, *ReferenceConstraint, *StringConstraint:
+		return c
+	}
+	return nil
+}
+
+// stringEnums returns the enumerated values of c, if all of them
+// are strings
+func stringEnums(c Constraint) []string {
+	var ec *EnumConstraint
+	switch c := c.(type) {
+	case *StringConstraint:
+		ec = c.enums
+	case *EnumConstraint:
+		ec = c
+	}
+	if ec == nil || len(ec.enums) == 0 {
+		return nil
+	}
+
+	l := make([]string, len(ec.enums))
+	for i, e := range ec.enums {
+		s, ok := e.(string)
+		if !ok {
+			return nil
+		}
+		l[i] = s
+	}
+	return l
+}
+
+// exportedName converts a property or definition name such as
+// "first_name" or "positive-integer" to an exported Go identifier
+// ("FirstName", "PositiveInteger").
+func exportedName(s string) string {
+	var buf strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if buf.Len() == 0 && unicode.IsDigit(r) {
+			buf.WriteByte('X')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+
+	name := buf.String()
+	switch name {
+	case "Id", "Url", "Uri", "Json", "Http", "Api":
+		name = strings.ToUpper(name)
+	}
+	return name
+}
+
+// refTypeName returns the name for the type generated for the
+// referenced definition at pointer ref, i.e. its last path segment.
+// References to entire documents are named after their files
+func refTypeName(ref string) string {
+	ref = strings.TrimSuffix(ref, "#")
+	if !strings.ContainsRune(ref, '#') {
+		ref = strings.TrimSuffix(path.Base(ref), path.Ext(ref))
+	}
+	if i := strings.LastIndexByte(ref, '/'); i > -1 {
+		ref = ref[i+1:]
+	}
+	ref = strings.Replace(ref, "~1", "/", -1)
+	ref = strings.Replace(ref, "~0", "~", -1)
+	return exportedName(ref)
+}
+
+func (tc *typectx) generate(out io.Writer) {
+	for _, t := range tc.types {
+		fmt.Fprintf(out, "\n")
+		generateDocComment(out, tc.docs[t.name])
+		if t.base != "" {
+			fmt.Fprintf(out, "\ntype %s %s", t.name, t.base)
+		} else {
+			fmt.Fprintf(out, "\ntype %s struct {", t.name)
+			for _, f := range t.fields {
+				tag := f.pname
+				if f.omitempty {
+					tag += ",omitempty"
+				} else if tag == "-" {
+					tag += ","
+				}
+				tag = "json:" + strconv.Quote(tag)
+				if strings.ContainsRune(tag, '`') {
+					tag = strconv.Quote(tag)
+				} else {
+					tag = "`" + tag + "`"
+				}
+				fmt.Fprintf(out, "\n%s %s %s", f.name, f.typ, tag)
+			}
+			fmt.Fprintf(out, "\n}")
+		}
+
+		if len(t.enums) > 0 {
+			fmt.Fprintf(out, "\n\nconst (")
+			for i, e := range t.enums {
+				suffix := exportedName(e)
+				if suffix == "" {
+					suffix = strconv.Itoa(i)
+				}
+				fmt.Fprintf(out, "\n%s %s = %s", tc.uniqueName(t.name+suffix), t.name, strconv.Quote(e))
+			}
+			fmt.Fprintf(out, "\n)")
+		}
+
+		call, arg := t.call, t.arg
+		if call == "" {
+			call = t.vname + ".Validate"
+		}
+		if arg == "" {
+			arg = "v"
+		}
+		fmt.Fprintf(out, "\n\nfunc (v *%s) Validate() error {", t.name)
+		fmt.Fprintf(out, "\nreturn %s(%s)", call, arg)
+		fmt.Fprintf(out, "\n}")
+	}
+}
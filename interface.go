@@ -18,9 +18,22 @@ type JSVal struct {
 	// `V2`, etc. If you want to generate more meaningful names, you should
 	// set this value manually. For example, if you are using jsval with a
 	// scaffold generator, you might want to set this to a human-readable value
-	Name     string
-	root     Constraint
-	resolver *jsref.Resolver
+	Name string
+	// Title and Description are copied from the schema by the builder.
+	// They don't affect validation, but the generator may use them to
+	// name and document the generated code (see `GeneratorOptions`)
+	Title       string
+	Description string
+	root        Constraint
+	resolver    *jsref.Resolver
+	maxDepth    int
+}
+
+// Annotation holds the title and the description of the schema that
+// a referenced constraint was built from.
+type Annotation struct {
+	Title       string
+	Description string
 }
 
 // JSValSlice is a list of JSVal validators. This exists in order to define
@@ -35,6 +48,11 @@ type Constraint interface {
 	Validate(interface{}) error
 }
 
+// ConstraintFunc adapts a function generated by the Compiler to a
+// Constraint. Besides the value, the function is given the number of
+// references that are being followed (see ReferenceDepth).
+type ConstraintFunc func(interface{}, ReferenceDepth) error
+
 type emptyConstraint struct{}
 
 // EmptyConstraint is a constraint that returns true for any value
@@ -66,6 +84,7 @@ type StringConstraint struct {
 	minLength int
 	regexp    *regexp.Regexp
 	format    string
+	formats   *FormatRegistry
 }
 
 // NumberConstraint implements a constraint to match against
@@ -75,9 +94,9 @@ type NumberConstraint struct {
 	applyMinimum     bool
 	applyMaximum     bool
 	applyMultipleOf  bool
-	minimum          float64
-	maximum          float64
-	multipleOf       float64
+	minimum          decimal
+	maximum          decimal
+	multipleOf       decimal
 	exclusiveMinimum bool
 	exclusiveMaximum bool
 	enums            *EnumConstraint
@@ -95,55 +114,71 @@ type IntegerConstraint struct {
 // various aspects of a Array/Slice structure
 type ArrayConstraint struct {
 	defaultValue
-	items           Constraint
-	positionalItems []Constraint
-	additionalItems Constraint
-	minItems        int
-	maxItems        int
-	uniqueItems     bool
+	items            Constraint
+	positionalItems  []Constraint
+	additionalItems  Constraint
+	minItems         int
+	maxItems         int
+	uniqueItems      bool
+	contains         Constraint
+	unevaluatedItems Constraint
+	siblings         []Constraint
 }
 
 // ObjectConstraint implements a constraint to match against
 // various aspects of a Map-like structure.
 type ObjectConstraint struct {
 	defaultValue
-	additionalProperties Constraint
-	deplock              sync.Mutex
-	patternProperties    map[*regexp.Regexp]Constraint
-	proplock             sync.Mutex
-	properties           map[string]Constraint
-	propdeps             map[string][]string
-	reqlock              sync.Mutex
-	required             map[string]struct{}
-	maxProperties        int
-	minProperties        int
-	schemadeps           map[string]Constraint
+	additionalProperties  Constraint
+	deplock               sync.Mutex
+	patternProperties     map[*regexp.Regexp]Constraint
+	proplock              sync.Mutex
+	properties            map[string]Constraint
+	propdeps              map[string][]string
+	reqlock               sync.Mutex
+	required              map[string]struct{}
+	maxProperties         int
+	minProperties         int
+	schemadeps            map[string]Constraint
+	propertyNames         Constraint
+	unevaluatedProperties Constraint
+	siblings              []Constraint
+	structInfo            *StructInfoRegistry
 
 	// FieldNameFromName takes a struct wrapped in reflect.Value, and a
 	// field name -- in JSON format (i.e. what you specified in your
 	// JSON struct tags, or the actual field name). It returns the
 	// struct field name to pass to `Value.FieldByName()`. If you do
-	// not specify one, DefaultFieldNameFromName will be used.
+	// not specify one, the StructInfoRegistry given to `StructInfo`
+	// (or DefaultStructInfoRegistry) will be used.
 	FieldNameFromName func(reflect.Value, string) string
 
 	// FieldNamesFromStruct takes a struct wrapped in reflect.Value, and
 	// returns the name of all public fields. Note that the returned
 	// names will be JSON names, which may not necessarily be the same
-	// as the field name. If you do not specify one, DefaultFieldNamesFromStruct
-	// will be used
+	// as the field name. If you do not specify one, the StructInfoRegistry
+	// given to `StructInfo` (or DefaultStructInfoRegistry) will be used
 	FieldNamesFromStruct func(reflect.Value) []string
 }
 
 // EnumConstraint implements a constraint where the incoming
 // value must match one of the values enumerated in the constraint.
-// Note that due to various language specific reasons, you should
-// only use simple types where the "==" operator can distinguish
-// between different values
+// Values are compared according to the JSON data model, so numbers
+// match regardless of their Go types, and objects and arrays are
+// compared deeply.
 type EnumConstraint struct {
 	emptyConstraint
 	enums []interface{}
 }
 
+// ConstConstraint implements a constraint where the incoming
+// value must be equal to the value specified in the constraint.
+// Values are compared the same way as EnumConstraint does.
+type ConstConstraint struct {
+	emptyConstraint
+	value interface{}
+}
+
 type comboconstraint struct {
 	emptyConstraint
 	constraints []Constraint
@@ -176,3 +211,14 @@ type OneOfConstraint struct {
 type NotConstraint struct {
 	child Constraint
 }
+
+// IfThenElseConstraint implements a conditional constraint. If the
+// value passes validation against the "if" constraint, it must
+// also pass the "then" constraint. Otherwise it must pass the "else"
+// constraint. Missing "then" or "else" constraints always pass.
+type IfThenElseConstraint struct {
+	emptyConstraint
+	cond Constraint
+	then Constraint
+	els  Constraint
+}
@@ -30,11 +30,7 @@ func _main() int {
 	}
 
 	var out bytes.Buffer
-	out.WriteString("package jsval_test")
-	out.WriteString("\n\nimport \"github.com/lestrrat-go/jsval\"")
-	out.WriteString("\n")
-
-	g := jsval.NewGenerator()
+	g := jsval.NewGenerator().SetOptions(jsval.GeneratorOptions{Package: "jsval_test"})
 	if err := g.Process(&out, v); err != nil {
 		log.Printf("%s", err)
 		return 1
@@ -0,0 +1,175 @@
+// Package diff computes line based differences between two texts, and
+// formats them as unified diffs. It is used to report generated files
+// that are out of date.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 3
+
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns the unified diff that turns a into b, using from and
+// to as the names of the files. If a and b are the same, an empty string
+// is returned.
+func Unified(from, to string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	edits := compute(splitLines(a), splitLines(b))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+
+	// Line numbers (0-based) in a and b at the start of each edit
+	aline := make([]int, len(edits)+1)
+	bline := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aline[i+1], bline[i+1] = aline[i], bline[i]
+		if e.kind != '+' {
+			aline[i+1]++
+		}
+		if e.kind != '-' {
+			bline[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough
+		start := i - Context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].kind == ' ' {
+				continue
+			}
+			if j-end-1 > 2*Context {
+				break
+			}
+			end = j
+		}
+		end += Context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(aline[start], aline[end]), hunkRange(bline[start], bline[end]))
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.kind)
+			buf.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+func hunkRange(from, to int) string {
+	if from == to {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits b into lines, keeping the line endings
+func splitLines(b []byte) []string {
+	var l []string
+	s := string(b)
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			l = append(l, s)
+			break
+		}
+		l = append(l, s[:i+1])
+		s = s[i+1:]
+	}
+	return l
+}
+
+// compute finds the shortest list of edits that turns a into b, using
+// the algorithm described in "An O(ND) Difference Algorithm and Its
+// Variations" by Eugene W. Myers
+func compute(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end, following the path that was found
+	edits := make([]edit, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevk int
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevk = k + 1
+		} else {
+			prevk = k - 1
+		}
+		prevx := v[max+prevk]
+		prevy := prevx - prevk
+
+		for x > prevx && y > prevy {
+			edits = append(edits, edit{kind: ' ', line: a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevx {
+			edits = append(edits, edit{kind: '+', line: b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{kind: '-', line: a[x-1]})
+			x--
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,60 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsval/internal/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	t.Run("Same", func(t *testing.T) {
+		if !assert.Equal(t, "", diff.Unified("a", "b", []byte("foo\nbar\n"), []byte("foo\nbar\n")), "no diff for the same input") {
+			return
+		}
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+		b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n14\n15\n16\n"
+		expected := strings.Join([]string{
+			"--- a.go",
+			"+++ b.go",
+			"@@ -1,6 +1,6 @@",
+			" 1",
+			" 2",
+			"-3",
+			"+three",
+			" 4",
+			" 5",
+			" 6",
+			"@@ -10,6 +10,6 @@",
+			" 10",
+			" 11",
+			" 12",
+			"-13",
+			" 14",
+			" 15",
+			"+16",
+			"",
+		}, "\n")
+		if !assert.Equal(t, expected, diff.Unified("a.go", "b.go", []byte(a), []byte(b)), "diff should match") {
+			return
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		expected := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+foo\n+bar\n"
+		if !assert.Equal(t, expected, diff.Unified("a", "b", nil, []byte("foo\nbar\n")), "diff should match") {
+			return
+		}
+	})
+
+	t.Run("NoNewline", func(t *testing.T) {
+		expected := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n foo\n-bar\n\\ No newline at end of file\n+bar\n"
+		if !assert.Equal(t, expected, diff.Unified("a", "b", []byte("foo\nbar"), []byte("foo\nbar\n")), "diff should match") {
+			return
+		}
+	})
+}
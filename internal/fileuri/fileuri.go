@@ -0,0 +1,24 @@
+// Package fileuri converts file names to "file" URIs. It is shared by
+// the builder, which resolves references against them, and the
+// Registry, which registers schema files under them.
+package fileuri
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// FromPath returns the "file" URI of the file fn. Relative names are
+// made absolute first
+func FromPath(fn string) (string, error) {
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs // Windows drive letters
+	}
+	return (&url.URL{Scheme: "file", Path: abs}).String(), nil
+}
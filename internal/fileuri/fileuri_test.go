@@ -0,0 +1,25 @@
+package fileuri_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/jsval/internal/fileuri"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err, "Getwd should succeed") {
+		return
+	}
+
+	uri, err := fileuri.FromPath(filepath.Join("schemas", "a b.json"))
+	if !assert.NoError(t, err, "FromPath should succeed") {
+		return
+	}
+	if !assert.Equal(t, "file://"+filepath.ToSlash(wd)+"/schemas/a%20b.json", uri, "relative names are made absolute, and escaped") {
+		return
+	}
+}
@@ -1,5 +1,6 @@
-//go:generate go run internal/cmd/gentest/gentest.go schema.json generated_validator_test.go
 //go:generate go run internal/cmd/genmaybe/genmaybe.go
+//go:generate go run cmd/jsval/jsval.go accessors -t accessorTestRecord -o generated_accessors_test.go accessors_types_test.go
+//go:generate go run cmd/jsval/jsval.go generate -m generate.json
 
 // Package jsval implements an input validator, based on JSON Schema.
 // The main purpose is to validate JSON Schemas (see
@@ -8,7 +9,14 @@
 // of JSON Schema.
 package jsval
 
-import "github.com/pkg/errors"
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
 
 // New creates a new JSVal instance.
 func New() *JSVal {
@@ -18,13 +26,75 @@ func New() *JSVal {
 }
 
 // Validate validates the input, and return an error
-// if any of the validations fail
+// if any of the validations fail. The cause of the returned error
+// (see `errors.Cause`) is usually a `*ValidationError`, which describes
+// where and why the validation failed.
 func (v *JSVal) Validate(x interface{}) error {
+	l := v.errorList(false)
+	l.check(v.root, x)
+	return v.wrapError(l.err())
+}
+
+// ValidateJSON decodes buf as JSON, and validates the result. Numbers
+// are decoded as `json.Number` instead of float64, so that integers
+// larger than 2^53 and decimals are checked without losing precision.
+// If the cause of the returned error is a `*ValidationError`, its
+// Offset field holds the byte offset of the offending value in buf.
+func (v *JSVal) ValidateJSON(buf []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return errors.Wrap(err, "failed to decode JSON")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("failed to decode JSON: extra data after the top-level value")
+	}
+
+	l := v.errorList(false)
+	l.check(v.root, x)
+	err := l.err()
+	if verr, ok := err.(*ValidationError); ok {
+		cp := *verr
+		cp.Offset = pointerOffset(buf, cp.Pointer)
+		err = &cp
+	}
+	return v.wrapError(err)
+}
+
+// ValidateReader is the same as ValidateJSON, except that it reads
+// the JSON input from r. The entire input is read into memory, so that
+// byte offsets can be reported.
+func (v *JSVal) ValidateReader(r io.Reader) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read JSON")
+	}
+	return v.ValidateJSON(buf)
+}
+
+func (v *JSVal) wrapError(err error) error {
 	name := v.Name
 	if len(name) == 0 {
-		return errors.Wrapf(v.root.Validate(x), "validator %p failed", v)
+		return errors.Wrapf(err, "validator %p failed", v)
 	}
-	return errors.Wrapf(v.root.Validate(x), "validator %s failed", name)
+	return errors.Wrapf(err, "validator %s failed", name)
+}
+
+// ValidateAll validates the input, and returns all of the errors
+// found, instead of stopping at the first one. Errors found in
+// nested objects and arrays are reported with the JSON Pointer
+// of the offending value (see `ValidationError`). If the input is
+// valid, nil is returned.
+func (v *JSVal) ValidateAll(x interface{}) []error {
+	l := v.errorList(true)
+	l.check(v.root, x)
+	return l.errors
+}
+
+func (v *JSVal) errorList(collect bool) errorList {
+	return errorList{collect: collect, maxDepth: v.maxDepth}
 }
 
 // SetName sets the name for the validator
@@ -33,12 +103,33 @@ func (v *JSVal) SetName(s string) *JSVal {
 	return v
 }
 
+// SetTitle sets the title for the validator
+func (v *JSVal) SetTitle(s string) *JSVal {
+	v.Title = s
+	return v
+}
+
+// SetDescription sets the description for the validator
+func (v *JSVal) SetDescription(s string) *JSVal {
+	v.Description = s
+	return v
+}
+
 // SetRoot sets the root Constraint object.
 func (v *JSVal) SetRoot(c Constraint) *JSVal {
 	v.root = c
 	return v
 }
 
+// SetMaxReferenceDepth sets the maximum number of references that may
+// be followed one inside another while validating a value. Values that
+// are nested deeper than this fail to validate. If n is zero (the
+// default), DefaultMaxReferenceDepth is used.
+func (v *JSVal) SetMaxReferenceDepth(n int) *JSVal {
+	v.maxDepth = n
+	return v
+}
+
 // Root returns the root Constraint object.
 func (v *JSVal) Root() Constraint {
 	return v.root
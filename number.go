@@ -1,9 +1,7 @@
 package jsval
 
 import (
-	"errors"
-	"math"
-	"reflect"
+	"encoding/json"
 
 	"github.com/lestrrat-go/pdebug"
 )
@@ -27,23 +25,50 @@ func (nc *NumberConstraint) Default(v interface{}) *NumberConstraint {
 // Maximum sepcifies the maximum value that the constraint can allow
 func (nc *NumberConstraint) Maximum(n float64) *NumberConstraint {
 	nc.applyMaximum = true
-	nc.maximum = n
+	nc.maximum = floatDecimal(n)
+	return nc
+}
+
+// MaximumDecimal is the same as Maximum, except the value is given
+// as a decimal literal (e.g. "0.01"), and the validated values are
+// compared against it exactly. It panics if n is not a valid number.
+func (nc *NumberConstraint) MaximumDecimal(n json.Number) *NumberConstraint {
+	nc.applyMaximum = true
+	nc.maximum = parseDecimal(n)
 	return nc
 }
 
 // Minimum sepcifies the minimum value that the constraint can allow
 func (nc *NumberConstraint) Minimum(n float64) *NumberConstraint {
 	nc.applyMinimum = true
-	nc.minimum = n
+	nc.minimum = floatDecimal(n)
+	return nc
+}
+
+// MinimumDecimal is the same as Minimum, except the value is given
+// as a decimal literal (e.g. "0.01"), and the validated values are
+// compared against it exactly. It panics if n is not a valid number.
+func (nc *NumberConstraint) MinimumDecimal(n json.Number) *NumberConstraint {
+	nc.applyMinimum = true
+	nc.minimum = parseDecimal(n)
 	return nc
 }
 
 // MultipleOf specifies the number that the given value must be
-// divisible by. That is, the constraint will return an error unless
-// the given value satisfies `math.Mod(v, n) == 0`
+// divisible by. The division is computed using exact decimal
+// arithmetic, so that 0.3 is considered a multiple of 0.1
 func (nc *NumberConstraint) MultipleOf(n float64) *NumberConstraint {
 	nc.applyMultipleOf = true
-	nc.multipleOf = n
+	nc.multipleOf = floatDecimal(n)
+	return nc
+}
+
+// MultipleOfDecimal is the same as MultipleOf, except the value is
+// given as a decimal literal (e.g. "0.01"). It panics if n is not a
+// valid number.
+func (nc *NumberConstraint) MultipleOfDecimal(n json.Number) *NumberConstraint {
+	nc.applyMultipleOf = true
+	nc.multipleOf = parseDecimal(n)
 	return nc
 }
 
@@ -74,69 +99,85 @@ func (nc *NumberConstraint) Validate(v interface{}) (err error) {
 		}()
 	}
 
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		rv = rv.Elem()
-	}
+	l := errorList{}
+	nc.validate(v, &l)
+	return l.err()
+}
 
-	switch rv.Kind() {
-	case reflect.Float32, reflect.Float64:
-	default:
-		return errors.New("value is not a float")
+func (nc *NumberConstraint) validate(v interface{}, l *errorList) {
+	n, ok := toNumericValue(v)
+	if !ok {
+		l.add(newValidationError("type", "number", v, "value is not a number"))
+		return
 	}
+	// Every comparison with NaN is false, so it would pass the
+	// minimum and the maximum
+	if !n.isFinite() {
+		l.add(newValidationError("type", "number", v, "value is not a finite number"))
+		return
+	}
+	nc.validateValue(n, l)
+}
 
-	f := rv.Float()
+func (nc *NumberConstraint) validateValue(n numericValue, l *errorList) {
 	if nc.applyMinimum {
 		if pdebug.Enabled {
-			pdebug.Printf("Checking Minimum (%f)", nc.minimum)
+			pdebug.Printf("Checking Minimum (%s)", nc.minimum.literal)
 		}
 
 		if nc.exclusiveMinimum {
-			if nc.minimum >= f {
-				return errors.New("numeric value is less than the minimum (exclusive minimum)")
+			if n.cmp(nc.minimum) <= 0 {
+				if l.add(newValidationError("exclusiveMinimum", nc.minimum.float, n.value(), "numeric value is less than the minimum (exclusive minimum)")) {
+					return
+				}
 			}
 		} else {
-			if nc.minimum > f {
-				return errors.New("numeric value is less than the minimum")
+			if n.cmp(nc.minimum) < 0 {
+				if l.add(newValidationError("minimum", nc.minimum.float, n.value(), "numeric value is less than the minimum")) {
+					return
+				}
 			}
 		}
 	}
 
 	if nc.applyMaximum {
 		if pdebug.Enabled {
-			pdebug.Printf("Checking Maximum (%f)", nc.maximum)
+			pdebug.Printf("Checking Maximum (%s)", nc.maximum.literal)
 		}
 		if nc.exclusiveMaximum {
-			if nc.maximum <= f {
-				return errors.New("numeric value is greater than maximum (exclusive maximum)")
+			if n.cmp(nc.maximum) >= 0 {
+				if l.add(newValidationError("exclusiveMaximum", nc.maximum.float, n.value(), "numeric value is greater than maximum (exclusive maximum)")) {
+					return
+				}
 			}
 		} else {
-			if nc.maximum < f {
-				return errors.New("numeric value is greater than maximum")
+			if n.cmp(nc.maximum) > 0 {
+				if l.add(newValidationError("maximum", nc.maximum.float, n.value(), "numeric value is greater than maximum")) {
+					return
+				}
 			}
 		}
 	}
 
 	if nc.applyMultipleOf {
 		if pdebug.Enabled {
-			pdebug.Printf("Checking MultipleOf (%f)", nc.multipleOf)
+			pdebug.Printf("Checking MultipleOf (%s)", nc.multipleOf.literal)
 		}
 
-		if nc.multipleOf != 0 {
-	    if math.Mod(f, nc.multipleOf) != 0 {
-				return errors.New("numeric value is fails multipleOf validation")
+		if !nc.multipleOf.isZero() {
+			if !n.isMultipleOf(nc.multipleOf) {
+				if l.add(newValidationError("multipleOf", nc.multipleOf.float, n.value(), "numeric value is fails multipleOf validation")) {
+					return
+				}
 			}
 		}
 	}
 
 	if enum := nc.enums; enum != nil {
-		if err := enum.Validate(f); err != nil {
-			return err
+		if l.check(enum, n.value()) {
+			return
 		}
 	}
-
-	return nil
 }
 
 // Number creates a new NumberConstraint
@@ -161,18 +202,43 @@ func (ic *IntegerConstraint) Default(v interface{}) *IntegerConstraint {
 	return ic
 }
 
-// Maximum sepcifies the maximum value that the constraint can allow
 // Maximum sepcifies the maximum value that the constraint can allow
 func (ic *IntegerConstraint) Maximum(n float64) *IntegerConstraint {
-	ic.applyMaximum = true
-	ic.maximum = n
+	ic.NumberConstraint.Maximum(n)
+	return ic
+}
+
+// MaximumDecimal is the same as Maximum, except the value is given
+// as a decimal literal. See NumberConstraint.MaximumDecimal
+func (ic *IntegerConstraint) MaximumDecimal(n json.Number) *IntegerConstraint {
+	ic.NumberConstraint.MaximumDecimal(n)
 	return ic
 }
 
 // Minimum sepcifies the minimum value that the constraint can allow
 func (ic *IntegerConstraint) Minimum(n float64) *IntegerConstraint {
-	ic.applyMinimum = true
-	ic.minimum = n
+	ic.NumberConstraint.Minimum(n)
+	return ic
+}
+
+// MinimumDecimal is the same as Minimum, except the value is given
+// as a decimal literal. See NumberConstraint.MinimumDecimal
+func (ic *IntegerConstraint) MinimumDecimal(n json.Number) *IntegerConstraint {
+	ic.NumberConstraint.MinimumDecimal(n)
+	return ic
+}
+
+// MultipleOf specifies the number that the given value must be
+// divisible by
+func (ic *IntegerConstraint) MultipleOf(n float64) *IntegerConstraint {
+	ic.NumberConstraint.MultipleOf(n)
+	return ic
+}
+
+// MultipleOfDecimal is the same as MultipleOf, except the value is
+// given as a decimal literal. See NumberConstraint.MultipleOfDecimal
+func (ic *IntegerConstraint) MultipleOfDecimal(n json.Number) *IntegerConstraint {
+	ic.NumberConstraint.MultipleOfDecimal(n)
 	return ic
 }
 
@@ -191,9 +257,9 @@ func (ic *IntegerConstraint) ExclusiveMaximum(b bool) *IntegerConstraint {
 }
 
 // Validate validates the value against integer validation rules.
-// Note that because when Go decodes JSON it FORCES float64 on numbers,
-// this method will return true even if the *type* of the value is
-// float32/64. We just check that `math.Floor(v) == v`
+// Any numeric value whose value is integral is accepted, including
+// floats such as 1.0, because that is how Go decodes JSON numbers
+// by default.
 func (ic *IntegerConstraint) Validate(v interface{}) (err error) {
 	if pdebug.Enabled {
 		g := pdebug.IPrintf("START IntegerConstraint.Validate")
@@ -206,24 +272,20 @@ func (ic *IntegerConstraint) Validate(v interface{}) (err error) {
 		}()
 	}
 
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Interface, reflect.Ptr:
-		rv = rv.Elem()
-	}
+	l := errorList{}
+	ic.validate(v, &l)
+	return l.err()
+}
 
-	switch rv.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return ic.NumberConstraint.Validate(float64(rv.Int()))
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return ic.NumberConstraint.Validate(float64(rv.Uint()))
-	case reflect.Float32, reflect.Float64:
-		fv := rv.Float()
-		if math.Floor(fv) != fv {
-			return errors.New("value is not an int/uint")
-		}
-		return ic.NumberConstraint.Validate(fv)
-	default:
-		return errors.New("value is not numeric")
+func (ic *IntegerConstraint) validate(v interface{}, l *errorList) {
+	n, ok := toNumericValue(v)
+	if !ok {
+		l.add(newValidationError("type", "integer", v, "value is not numeric"))
+		return
+	}
+	if !n.isInteger() {
+		l.add(newValidationError("type", "integer", v, "value is not an integer"))
+		return
 	}
+	ic.NumberConstraint.validateValue(n, l)
 }
@@ -1,12 +1,17 @@
 package jsval_test
 
 import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/lestrrat-go/jsschema"
 	"github.com/lestrrat-go/jsval"
 	"github.com/lestrrat-go/jsval/builder"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,4 +67,240 @@ func TestNumber(t *testing.T) {
 	if !assert.NoError(t, c.Validate(s), "validate should succeed") {
 		return
 	}
-}
\ No newline at end of file
+}
+func TestNumberKinds(t *testing.T) {
+	big53 := uint64(1) << 53
+
+	t.Run("Number", func(t *testing.T) {
+		c := jsval.Number().Minimum(1).Maximum(100)
+		valid := []interface{}{
+			5,
+			int8(5),
+			uint64(100),
+			float32(1.5),
+			json.Number("99.999"),
+			big.NewInt(42),
+			big.NewFloat(1),
+			jsval.MaybeInt{ValidFlag: true, Int: 5},
+			&jsval.MaybeFloat{ValidFlag: true, Float: 5.5},
+		}
+		for _, v := range valid {
+			if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+				return
+			}
+		}
+
+		invalid := []interface{}{
+			0,
+			uint(101),
+			json.Number("100.0000000000000001"),
+			big.NewInt(101),
+			jsval.MaybeInt{},
+			"5",
+			nil,
+		}
+		for _, v := range invalid {
+			if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("Integer", func(t *testing.T) {
+		c := jsval.Integer().Maximum(float64(big53))
+		valid := []interface{}{
+			int64(big53),
+			big53,
+			json.Number("9007199254740992"),
+			json.Number("1e2"),
+			float64(5),
+			new(big.Int).SetUint64(big53),
+		}
+		for _, v := range valid {
+			if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+				return
+			}
+		}
+
+		invalid := []interface{}{
+			// These are rounded to 2^53 when converted to float64
+			int64(big53 + 1),
+			big53 + 1,
+			json.Number("9007199254740993"),
+			new(big.Int).SetUint64(big53 + 1),
+			json.Number("1.5"),
+			1.5,
+			big.NewFloat(1.5),
+		}
+		for _, v := range invalid {
+			if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("NonFinite", func(t *testing.T) {
+		for _, c := range []jsval.Constraint{
+			jsval.Number(),
+			jsval.Number().Minimum(1).Maximum(100),
+			jsval.Number().Minimum(1).ExclusiveMinimum(true),
+			jsval.Number().MaximumDecimal("100"),
+			jsval.Integer().Maximum(100),
+		} {
+			for _, v := range []interface{}{
+				math.NaN(),
+				math.Inf(1),
+				math.Inf(-1),
+				float32(math.NaN()),
+				new(big.Float).SetInf(false),
+				jsval.MaybeFloat{ValidFlag: true, Float: math.NaN()},
+			} {
+				if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+					return
+				}
+			}
+		}
+	})
+
+	t.Run("MultipleOf", func(t *testing.T) {
+		c := jsval.Integer().MultipleOf(3)
+		if !assert.NoError(t, c.Validate(uint64(1<<63)+1), "large uint64 is a multiple of 3") {
+			return
+		}
+		if !assert.Error(t, c.Validate(uint64(1<<63)), "large uint64 is not a multiple of 3") {
+			return
+		}
+	})
+}
+
+func TestNumberDecimal(t *testing.T) {
+	t.Run("MultipleOf", func(t *testing.T) {
+		c := jsval.Number().MultipleOf(0.1)
+		for _, v := range []interface{}{0.3, float32(0.3), 19.9, json.Number("0.30"), 1e10} {
+			if !assert.NoError(t, c.Validate(v), "%#v should be a multiple of 0.1", v) {
+				return
+			}
+		}
+		for _, v := range []interface{}{0.31, json.Number("0.3000000000000000001")} {
+			if !assert.Error(t, c.Validate(v), "%#v should not be a multiple of 0.1", v) {
+				return
+			}
+		}
+
+		c = jsval.Number().MultipleOfDecimal("0.01")
+		if !assert.NoError(t, c.Validate(19.99), "19.99 is a multiple of 0.01") {
+			return
+		}
+		if !assert.Error(t, c.Validate(19.999), "19.999 is not a multiple of 0.01") {
+			return
+		}
+	})
+
+	t.Run("Bounds", func(t *testing.T) {
+		c := jsval.Number().MinimumDecimal("0.10000000000000000001").MaximumDecimal("1e2")
+		for _, v := range []interface{}{json.Number("0.10000000000000000001"), 0.2, 100} {
+			if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+				return
+			}
+		}
+		// 0.1 and the minimum are the same number when rounded to float64
+		for _, v := range []interface{}{json.Number("0.1"), 0.1, json.Number("100.000000000000000001")} {
+			if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("ValidateJSON", func(t *testing.T) {
+		v := jsval.New().SetRoot(jsval.Object().AddProp("amount", jsval.Number().MultipleOf(0.01).Maximum(100)))
+		if !assert.NoError(t, v.ValidateJSON([]byte(`{"amount": 99.99}`)), "ValidateJSON should succeed") {
+			return
+		}
+		if !assert.Error(t, v.ValidateJSON([]byte(`{"amount": 100.000000000000001}`)), "ValidateJSON should fail") {
+			return
+		}
+	})
+
+	t.Run("Exponent", func(t *testing.T) {
+		// The exponents are too large to compare the numbers exactly, so
+		// they are compared as float64s
+		v := jsval.New().SetRoot(jsval.Object().
+			AddProp("n", jsval.Number().Minimum(0)).
+			AddProp("i", jsval.Integer().Maximum(100)),
+		)
+		for _, buf := range []string{`{"n": 1e100000000}`, `{"n": 1e-100000000}`, `{"i": -1e100000000}`} {
+			if !assert.NoError(t, v.ValidateJSON([]byte(buf)), "%s should be valid", buf) {
+				return
+			}
+		}
+		for _, buf := range []string{`{"n": -1e100000000}`, `{"i": 1e100000000}`} {
+			err := v.ValidateJSON([]byte(buf))
+			if !assert.Error(t, err, "%s should be invalid", buf) {
+				return
+			}
+			verr, ok := errors.Cause(err).(*jsval.ValidationError)
+			if !assert.True(t, ok, "error is a ValidationError") {
+				return
+			}
+			if !assert.Contains(t, []string{"minimum", "maximum"}, verr.Keyword, "the bound fails, not the type") {
+				return
+			}
+		}
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		// 9007199254740993 is 2^53+1, which rounds to 2^53 as a float64
+		f, err := os.Open("testdata/decimal-schema.json")
+		if !assert.NoError(t, err, "os.Open should succeed") {
+			return
+		}
+		defer f.Close()
+
+		dec := json.NewDecoder(f)
+		dec.UseNumber()
+		var m map[string]interface{}
+		if !assert.NoError(t, dec.Decode(&m), "Decode should succeed") {
+			return
+		}
+		m = builder.NormalizeSchema(m)
+
+		s := schema.New()
+		if !assert.NoError(t, s.Extract(m), "schema.Extract should succeed") {
+			return
+		}
+		v, err := builder.New().BuildWithCtx(s, m)
+		if !assert.NoError(t, err, "Build should succeed") {
+			return
+		}
+		if !checkGenerated(t, v.SetName("DecimalV0"), "DecimalV", "generated_decimal_test.go") {
+			return
+		}
+
+		for _, v := range []*jsval.JSVal{v, DecimalV0} {
+			for _, buf := range []string{
+				`{"n": 9007199254740993}`,
+				`{"price": 0.1}`,
+				`{"price": 19.99}`,
+				`{"ratio": 0.0001234}`,
+				`{"share": 0.1}`,
+				`{"count": 999999999999999999999}`,
+			} {
+				if !assert.NoError(t, v.ValidateJSON([]byte(buf)), "%s should be valid", buf) {
+					return
+				}
+			}
+			for _, buf := range []string{
+				`{"n": 9007199254740994}`,
+				`{"price": 0.09999999999999999}`,
+				`{"price": 19.999}`,
+				`{"ratio": 0.00012345}`,
+				`{"share": 0.10000000000000000002}`,
+				`{"count": 1000000000000000000002}`,
+			} {
+				if !assert.Error(t, v.ValidateJSON([]byte(buf)), "%s should be invalid", buf) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package jsval
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+var (
+	jsonNumberType = reflect.TypeOf(json.Number(""))
+	bigIntType     = reflect.TypeOf((*big.Int)(nil))
+	bigFloatType   = reflect.TypeOf((*big.Float)(nil))
+)
+
+// numericValue is a number that is being validated by a
+// NumberConstraint or an IntegerConstraint
+type numericValue interface {
+	// cmp compares the number against d, and returns -1, 0, or +1
+	cmp(d decimal) int
+	// isFinite reports whether the number is neither NaN nor infinite,
+	// which JSON numbers can not be
+	isFinite() bool
+	isInteger() bool
+	isMultipleOf(d decimal) bool
+	// value returns the value to report in validation errors
+	value() interface{}
+}
+
+// decimal is a number that is specified in a constraint, such as
+// the value of "minimum". Besides the float64 value, it holds the
+// exact decimal value, which is used to compare numbers exactly.
+type decimal struct {
+	float   float64
+	rat     *big.Rat // nil if float is NaN or infinity
+	literal string
+	// exact is true if the number was specified as a decimal literal
+	// instead of a float64
+	exact bool
+}
+
+func floatDecimal(f float64) decimal {
+	d := decimal{
+		float:   f,
+		literal: strconv.FormatFloat(f, 'g', -1, 64),
+	}
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		d.rat = decimalRat(f)
+	}
+	return d
+}
+
+// parseDecimal parses a decimal literal, such as "0.01" or "1e-3".
+// It panics if n is not a valid number.
+func parseDecimal(n json.Number) decimal {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		panic("invalid decimal literal '" + string(n) + "'")
+	}
+	f, _ := r.Float64()
+	return decimal{
+		float:   f,
+		rat:     r,
+		literal: string(n),
+		exact:   true,
+	}
+}
+
+func (d decimal) isZero() bool {
+	if d.rat == nil {
+		return false
+	}
+	return d.rat.Sign() == 0
+}
+
+// toNumericValue converts v to a numericValue. All int, uint and float
+// kinds, json.Number, *big.Int, *big.Float, and anything that jsonValueOf
+// resolves to one of these (e.g. MaybeInt and MaybeFloat) are accepted.
+// Values other than floats are compared exactly, except for JSON numbers
+// whose exponent is too large to do so (such as 1e100000000), which are
+// compared as float64s (see literalFloat). It returns false if v is not
+// a number.
+func toNumericValue(v interface{}) (numericValue, bool) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonNumber {
+		return nil, false
+	}
+
+	switch n := rv.Interface().(type) {
+	case *big.Int:
+		return ratValue{rat: new(big.Rat).SetInt(n), orig: n}, true
+	case *big.Float:
+		if n.IsInf() {
+			return floatValue(math.Inf(n.Sign())), true
+		}
+		r, _ := n.Rat(nil)
+		return ratValue{rat: r, orig: n}, true
+	}
+
+	if isJSONNumber(rv) {
+		if r, ok := new(big.Rat).SetString(rv.String()); ok {
+			return ratValue{rat: r, orig: json.Number(rv.String())}, true
+		}
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, false
+		}
+		return literalFloat{floatValue: floatValue(f), orig: json.Number(rv.String())}, true
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ratValue{rat: new(big.Rat).SetInt64(rv.Int()), orig: rv.Interface()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i := new(big.Int).SetUint64(rv.Uint())
+		return ratValue{rat: new(big.Rat).SetInt(i), orig: rv.Interface()}, true
+	case reflect.Float32:
+		// Use the float64 that is closest to the decimal number that the
+		// float32 stands for, i.e. 0.3 instead of 0.30000001192092896
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(rv.Float(), 'g', -1, 32), 64)
+		return floatValue(f), true
+	case reflect.Float64:
+		return floatValue(rv.Float()), true
+	}
+	return nil, false
+}
+
+// floatValue is a number that was given as a float. Unless the number
+// in the constraint was given as a decimal literal, it is compared
+// against the float64 value of the constraint. For multipleOf, the
+// decimal number closest to the float is used, so that 0.3 is a
+// multiple of 0.1
+type floatValue float64
+
+func (n floatValue) cmp(d decimal) int {
+	f := float64(n)
+	if d.exact && d.rat != nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return decimalRat(f).Cmp(d.rat)
+	}
+
+	switch {
+	case float64(n) < d.float:
+		return -1
+	case float64(n) > d.float:
+		return 1
+	}
+	return 0
+}
+
+func (n floatValue) isFinite() bool {
+	f := float64(n)
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func (n floatValue) isInteger() bool {
+	f := float64(n)
+	return !math.IsInf(f, 0) && math.Floor(f) == f
+}
+
+func (n floatValue) isMultipleOf(d decimal) bool {
+	f := float64(n)
+	if d.rat == nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+	q := new(big.Rat).Quo(decimalRat(f), d.rat)
+	return q.IsInt()
+}
+
+func (n floatValue) value() interface{} {
+	return float64(n)
+}
+
+// literalFloat is a JSON number that is too large or too small to be
+// compared exactly, and is compared as the float64 closest to it
+// instead. Numbers that are too large for a float64 become infinity,
+// which compares greater (or less) than any bound, but unlike the
+// float64 they are still finite numbers, and integers.
+type literalFloat struct {
+	floatValue
+	orig json.Number
+}
+
+func (n literalFloat) isFinite() bool {
+	return true
+}
+
+func (n literalFloat) isInteger() bool {
+	return math.IsInf(float64(n.floatValue), 0) || n.floatValue.isInteger()
+}
+
+func (n literalFloat) value() interface{} {
+	return n.orig
+}
+
+// ratValue is a number that is compared exactly, without rounding
+// it to a float64
+type ratValue struct {
+	rat  *big.Rat
+	orig interface{}
+}
+
+func (n ratValue) cmp(d decimal) int {
+	if d.rat == nil {
+		switch {
+		case math.IsInf(d.float, 1):
+			return -1
+		case math.IsInf(d.float, -1):
+			return 1
+		}
+		// NaN can't be compared
+		return 0
+	}
+	return n.rat.Cmp(d.rat)
+}
+
+func (n ratValue) isFinite() bool {
+	return true
+}
+
+func (n ratValue) isInteger() bool {
+	return n.rat.IsInt()
+}
+
+func (n ratValue) isMultipleOf(d decimal) bool {
+	if d.rat == nil {
+		return false
+	}
+	q := new(big.Rat).Quo(n.rat, d.rat)
+	return q.IsInt()
+}
+
+func (n ratValue) value() interface{} {
+	return n.orig
+}
+
+// decimalRat converts f to the decimal number that is closest to it.
+// This is the number that was most likely written in the schema, e.g.
+// 0.1 instead of 0.1000000000000000055511151231257827...
+// f must be finite.
+func decimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r
+}
+
+func isJSONNumber(rv reflect.Value) bool {
+	return rv.IsValid() && rv.Type() == jsonNumberType
+}
@@ -4,20 +4,35 @@ import (
 	"errors"
 	"reflect"
 	"regexp"
+	"sort"
 
 	"github.com/lestrrat-go/pdebug"
 )
 
-type getPropValuer interface {
-	// Given a JSON property name, return the actual field value
+// GetPropValuer is implemented by structs that know how to get the
+// values of their properties without using reflection. See
+// `jsval accessors` to generate the methods.
+type GetPropValuer interface {
+	// Given a JSON property name, return the actual field value.
+	// A nil value means that the property is not present. If an
+	// error is returned, the value is looked up using reflection.
 	GetPropValue(string) (interface{}, error)
 }
 
-type getPropNameser interface {
+// GetPropNameser is implemented by structs that know the names of
+// the properties that they have without using reflection
+type GetPropNameser interface {
 	// Given a lst of JSON property names
 	GetPropNames() ([]string, error)
 }
 
+// SetPropValuer is implemented by structs that know how to set the
+// values of their properties without using reflection. It is used
+// to apply default values.
+type SetPropValuer interface {
+	SetPropValue(string, interface{}) error
+}
+
 // Object creates a new ObjectConstraint
 func Object() *ObjectConstraint {
 	return &ObjectConstraint{
@@ -74,6 +89,43 @@ func (o *ObjectConstraint) AdditionalProperties(c Constraint) *ObjectConstraint
 	return o
 }
 
+// PropertyNames specifies the constraint that the names of all
+// properties in the object must be validated against.
+func (o *ObjectConstraint) PropertyNames(c Constraint) *ObjectConstraint {
+	o.propertyNames = c
+	return o
+}
+
+// UnevaluatedProperties specifies the constraint that properties not
+// evaluated by any other part of the schema must be validated against.
+// A property counts as evaluated if it is covered by `AddProp`,
+// `PatternProperties`, `AdditionalProperties` (unless it is
+// `EmptyConstraint`), a schema dependency, or any of the constraints
+// given to `Siblings` that the value successfully validates against.
+func (o *ObjectConstraint) UnevaluatedProperties(c Constraint) *ObjectConstraint {
+	o.unevaluatedProperties = c
+	return o
+}
+
+// Siblings specifies the constraints that are applied to the same value
+// alongside this constraint, such as those created from allOf, anyOf,
+// oneOf, if/then/else and $ref in the same schema. They are not
+// validated by this constraint, but the properties that they evaluate
+// are excluded from `UnevaluatedProperties`.
+func (o *ObjectConstraint) Siblings(l ...Constraint) *ObjectConstraint {
+	o.siblings = append(o.siblings, l...)
+	return o
+}
+
+// StructInfo specifies the StructInfoRegistry that maps property names
+// to struct fields, when the value being validated is a struct. If
+// unspecified, DefaultStructInfoRegistry is used. FieldNameFromName and
+// FieldNamesFromStruct take precedence over the registry.
+func (o *ObjectConstraint) StructInfo(r *StructInfoRegistry) *ObjectConstraint {
+	o.structInfo = r
+	return o
+}
+
 // AddProp adds constraints for a named property.
 func (o *ObjectConstraint) AddProp(name string, c Constraint) *ObjectConstraint {
 	o.proplock.Lock()
@@ -153,49 +205,53 @@ func (o *ObjectConstraint) GetSchemaDependency(from string) Constraint {
 	return c
 }
 
-var structInfoRegistry = StructInfoRegistry{
-	registry: make(map[reflect.Type]StructInfo),
+func (o *ObjectConstraint) getPropConstraint(pname string) Constraint {
+	o.proplock.Lock()
+	defer o.proplock.Unlock()
+
+	return o.properties[pname]
 }
 
-// getProps return all of the property names for this object.
-// XXX Map keys can be something other than strings, but
-// we can't really allow it?
-func (o *ObjectConstraint) getPropNames(rv reflect.Value) ([]string, error) {
-	switch rv.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		rv = rv.Elem()
-	}
+func (o *ObjectConstraint) matchesPatternProperty(pname string) bool {
+	o.proplock.Lock()
+	defer o.proplock.Unlock()
 
-	var keys []string
-	switch rv.Kind() {
-	case reflect.Map:
-		vk := rv.MapKeys()
-		keys = make([]string, len(vk))
-		for i, v := range vk {
-			if v.Kind() != reflect.String {
-				return nil, errors.New("panic: can only handle maps with string keys")
-			}
-			keys[i] = v.String()
-		}
-	case reflect.Struct:
-		if gpv, ok := rv.Interface().(getPropNameser); ok {
-			pv, err := gpv.GetPropNames()
-			if err == nil {
-				return pv, nil
-			}
+	for pat := range o.patternProperties {
+		if pat.MatchString(pname) {
+			return true
 		}
+	}
+	return false
+}
 
-		si, ok := structInfoRegistry.Lookup(rv.Type())
-		if !ok {
-			si = structInfoRegistry.Register(rv.Type())
+// getPatternPropConstraints returns the constraints of all patternProperties
+// that match pname, sorted by their patterns
+func (o *ObjectConstraint) getPatternPropConstraints(pname string) []Constraint {
+	o.proplock.Lock()
+	defer o.proplock.Unlock()
+
+	var pats []*regexp.Regexp
+	for pat := range o.patternProperties {
+		if pat.MatchString(pname) {
+			pats = append(pats, pat)
 		}
+	}
+	sort.Slice(pats, func(i, j int) bool { return pats[i].String() < pats[j].String() })
 
-		return si.PropNames(rv), nil
-	default:
-		return nil, errors.New("cannot get property names from this value (Kind: " + rv.Kind().String() + ")")
+	l := make([]Constraint, len(pats))
+	for i, pat := range pats {
+		l[i] = o.patternProperties[pat]
 	}
+	return l
+}
 
-	return keys, nil
+// getPropNames returns all of the property names for this object.
+// rv must be an object, as returned by jsonValueOf
+func (o *ObjectConstraint) getPropNames(rv reflect.Value) ([]string, error) {
+	if rv.Kind() == reflect.Struct {
+		return structPropNames(rv, o.structInfo, o.FieldNamesFromStruct), nil
+	}
+	return propNames(rv)
 }
 
 var (
@@ -212,6 +268,10 @@ func coerceValue(v interface{}, t reflect.Type) reflect.Value {
 		vv = vv.Elem()
 	}
 
+	if !vv.IsValid() {
+		return vv
+	}
+
 	// For known Maybe types, we should do our best, too
 	switch {
 	case t == maybefloatT:
@@ -226,11 +286,56 @@ func coerceValue(v interface{}, t reflect.Type) reflect.Value {
 	return vv
 }
 
-type setPropValuer interface {
-	SetPropValue(string, interface{}) error
+// AssignValue assigns val to the variable that dst points to. Numeric
+// values are converted to the type of the variable, and Maybe values
+// are set via their Set method. This is how default values are applied
+// to struct fields, and is meant to be used by SetPropValue methods.
+func AssignValue(dst interface{}, val interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("AssignValue: destination must be a non-nil pointer")
+	}
+	return assignValue(rv.Elem(), val)
 }
 
-var spvType = reflect.TypeOf((*setPropValuer)(nil)).Elem()
+func assignValue(f reflect.Value, val interface{}) error {
+	if !f.CanSet() {
+		return errors.New("value is not settable (pass a pointer to the struct to set default values)")
+	}
+
+	// Usability: If you specify `Default(10)` on an int64 value,
+	// it doesn't work. But these values are compatible. We should
+	// do our best to align them
+	dv := coerceValue(val, f.Type())
+
+	// Is this a Maybe value? If so, we should use its Set() method
+	var m Maybe
+	switch {
+	case f.Type().Implements(maybeif):
+		m, _ = f.Interface().(Maybe)
+	case f.CanAddr() && f.Addr().Type().Implements(maybeif):
+		m, _ = f.Addr().Interface().(Maybe)
+	default:
+		if !dv.IsValid() {
+			f.Set(reflect.Zero(f.Type()))
+			return nil
+		}
+		if !dv.Type().AssignableTo(f.Type()) {
+			return errors.New("cannot assign value of type " + dv.Type().String() + " to " + f.Type().String())
+		}
+		f.Set(dv)
+		return nil
+	}
+
+	if m == nil {
+		return errors.New("setProp: could not get 'Set' method for value")
+	}
+
+	if !dv.IsValid() {
+		return m.Set(nil)
+	}
+	return m.Set(dv.Interface())
+}
 
 func (o *ObjectConstraint) setProp(rv reflect.Value, pname string, val interface{}) error {
 	switch rv.Kind() {
@@ -240,84 +345,49 @@ func (o *ObjectConstraint) setProp(rv reflect.Value, pname string, val interface
 
 	switch rv.Kind() {
 	case reflect.Map:
-		rv.SetMapIndex(reflect.ValueOf(pname), reflect.ValueOf(val))
+		rv.SetMapIndex(mapKeyFor(rv, pname), reflect.ValueOf(val))
 		return nil
 	case reflect.Struct:
-		spvm := rv.MethodByName("SetPropValue")
-		if spvm != zeroval {
-			out := spvm.Call([]reflect.Value{reflect.ValueOf(pname), reflect.ValueOf(val)})
-			if !out[0].IsNil() {
-				return out[0].Interface().(error)
+		if rv.CanAddr() {
+			if spv, ok := rv.Addr().Interface().(SetPropValuer); ok {
+				return spv.SetPropValue(pname, val)
 			}
-			return nil
 		}
 
-		f := o.getProp(rv, pname)
+		f := o.getField(rv, pname)
 		if f == zeroval {
 			return errors.New("setProp: could not find field '" + pname + "'")
 		}
-
-		// Usability: If you specify `Default(10)` on an int64 value,
-		// it doesn't work. But these values are compatible. We should
-		// do our best to align them
-		dv := coerceValue(val, f.Type())
-
-		// Is this a Maybe value? If so, we should use its Set() method
-		var mv reflect.Value
-		switch {
-		case f.Type().Implements(maybeif):
-			mv = f.MethodByName("Set")
-		case f.CanAddr() && f.Addr().Type().Implements(maybeif):
-			mv = f.Addr().MethodByName("Set")
-		default:
-			f.Set(dv)
-			return nil
-		}
-
-		if mv == zeroval {
-			return errors.New("setProp: could not get 'Set' method for value")
-		}
-
-		out := mv.Call([]reflect.Value{dv})
-		if !out[0].IsNil() {
-			return out[0].Interface().(error)
-		}
-		return nil
+		return assignValue(f, val)
 	default:
 		return errors.New("setProp: don't know what to do with '" + rv.Kind().String() + "'")
 	}
 }
 
 func (o *ObjectConstraint) getProp(rv reflect.Value, pname string) reflect.Value {
-	switch rv.Kind() {
-	case reflect.Map:
-		pv := reflect.ValueOf(pname)
-		return rv.MapIndex(pv)
-	case reflect.Struct:
-		// This guy knows how to grab the value, given a name. Use that
-		if gpv, ok := rv.Interface().(getPropValuer); ok {
-			pv, err := gpv.GetPropValue(pname)
-			if err == nil {
-				return reflect.ValueOf(pv)
-			}
-		}
-
-		si, ok := structInfoRegistry.Lookup(rv.Type())
-		if !ok {
-			si = structInfoRegistry.Register(rv.Type())
-		}
+	if rv.Kind() == reflect.Struct {
+		return structPropValue(rv, o.structInfo, o.FieldNameFromName, pname)
+	}
+	return propValue(rv, pname)
+}
 
-		fn, ok := si.FieldName(pname)
-		if !ok {
-			if pdebug.Enabled {
-				pdebug.Printf("Could not resolve name '%s'", pname)
-			}
+// getField returns the struct field that holds the property pname,
+// regardless of its value
+func (o *ObjectConstraint) getField(rv reflect.Value, pname string) reflect.Value {
+	if hook := o.FieldNameFromName; hook != nil {
+		fn := hook(rv, pname)
+		if fn == "" {
 			return zeroval
 		}
-		return rv.FieldByName(fn)
-	default:
-		return zeroval
+		return fieldByName(rv, fn)
 	}
+
+	r := o.structInfo
+	if r == nil {
+		r = DefaultStructInfoRegistry
+	}
+	si := r.lookupOrRegister(rv.Type())
+	return si.Field(rv, pname)
 }
 
 // Validate validates the given value against this ObjectConstraint
@@ -333,30 +403,50 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 		}()
 	}
 
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		rv = rv.Elem()
+	l := errorList{}
+	o.validate(v, &l)
+	return l.err()
+}
+
+func (o *ObjectConstraint) validate(v interface{}, l *errorList) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonObject {
+		l.add(newValidationError("type", "object", v, "value is not an object (was: "+kind.String()+")"))
+		return
 	}
 
 	fields, err := o.getPropNames(rv)
 	if err != nil {
-		return err
+		l.add(newValidationError("type", "object", v, err.Error()))
+		return
 	}
 
 	lf := len(fields)
 	if o.minProperties > -1 && lf < o.minProperties {
-		return errors.New("fewer properties than minProperties")
+		if l.add(newValidationError("minProperties", o.minProperties, lf, "fewer properties than minProperties")) {
+			return
+		}
 	}
 	if o.maxProperties > -1 && lf > o.maxProperties {
-		return errors.New("more properties than maxProperties")
+		if l.add(newValidationError("maxProperties", o.maxProperties, lf, "more properties than maxProperties")) {
+			return
+		}
+	}
+
+	if c := o.propertyNames; c != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Checking property names")
+		}
+		for _, pname := range fields {
+			if l.checkAt(c, pname, pname) {
+				return
+			}
+		}
 	}
 
 	// Find the list of field names that were passed to us
 	// "premain" shows extra props, if any.
-	// "pseen" shows props that we have already seen
 	premain := map[string]struct{}{}
-	pseen := map[string]struct{}{}
 	for _, k := range fields {
 		premain[k] = struct{}{}
 	}
@@ -378,23 +468,21 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 		pval := o.getProp(rv, pname)
 		propExists := false
 
+		var pv interface{}
 		switch {
 		case pval == zeroval:
 			// If we got a zeroval, we're done for.
 		case pval.Type().Implements(maybeif) || reflect.PtrTo(pval.Type()).Implements(maybeif):
 			// If we have a Maybe value, we check the Valid() flag
-			mv := pval.MethodByName("Valid")
-			out := mv.Call(nil)
-			if out[0].Bool() {
+			if mv, ok := pval.Interface().(maybeValuer); ok && mv.Valid() {
 				propExists = true
-				// Swap out pval to be the value pointed to by the Maybe value
-				mv = pval.MethodByName("Value")
-				out = mv.Call(nil)
-				pval = out[0]
+				// Swap out the value to be the value held by the Maybe value
+				pv = mv.Value()
 			}
 		default:
 			// Everything else, we have *something*
 			propExists = true
+			pv = pval.Interface()
 		}
 
 		if !propExists {
@@ -403,7 +491,10 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 			}
 
 			if o.IsPropRequired(pname) { // required, and not present.
-				return errors.New("object property '" + pname + "' is required")
+				if l.add(newValidationError("required", pname, v, "object property '"+pname+"' is required")) {
+					return
+				}
+				continue
 			}
 
 			// At this point we know that the property was not present
@@ -416,9 +507,10 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 				dv := c.DefaultValue()
 
 				if err := o.setProp(rv, pname, dv); err != nil {
-					return errors.New("failed to set default value for property '" + pname + "': " + err.Error())
+					if l.add(errors.New("failed to set default value for property '" + pname + "': " + err.Error())) {
+						return
+					}
 				}
-				pval = reflect.ValueOf(dv)
 			}
 
 			continue
@@ -426,19 +518,44 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 
 		// delete from remaining props
 		delete(premain, pname)
-		// ...and add to props that we have seen
-		pseen[pname] = struct{}{}
 
-		if err := c.Validate(pval.Interface()); err != nil {
-			return errors.New("object property '" + pname + "' validation failed: " + err.Error())
+		if l.checkAt(c, pv, pname) {
+			return
 		}
 	}
 
+	// Required properties that do not have a constraint of their own
+	// were not checked above
+	present := make(map[string]struct{}, len(fields))
+	for _, pname := range fields {
+		present[pname] = struct{}{}
+	}
+
+	o.reqlock.Lock()
+	reqnames := make([]string, 0, len(o.required))
+	for pname := range o.required {
+		if _, ok := propdefs[pname]; !ok {
+			reqnames = append(reqnames, pname)
+		}
+	}
+	o.reqlock.Unlock()
+	sort.Strings(reqnames)
+
+	for _, pname := range reqnames {
+		if _, ok := present[pname]; !ok {
+			if l.add(newValidationError("required", pname, v, "object property '"+pname+"' is required")) {
+				return
+			}
+		}
+	}
+
+	// patternProperties apply to all properties, including those that
+	// are listed in properties, and those matched by other patterns
 	for pat, c := range o.patternProperties {
 		if pdebug.Enabled {
 			pdebug.Printf("Checking patternProperty '%s'", pat.String())
 		}
-		for pname := range premain {
+		for _, pname := range fields {
 			if !pat.MatchString(pname) {
 				if pdebug.Enabled {
 					pdebug.Printf("Property '%s' does not match pattern...", pname)
@@ -449,39 +566,52 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 				pdebug.Printf("Property '%s' matches!", pname)
 			}
 			// No need to check if this pname exists, as we're taking
-			// this from "premain"
+			// this from the list of fields
 			pval := o.getProp(rv, pname)
 
 			delete(premain, pname)
-			pseen[pname] = struct{}{}
-			if err := c.Validate(pval.Interface()); err != nil {
-				return errors.New("object property '" + pname + "' validation failed: " + err.Error())
+			if l.checkAt(c, pval.Interface(), pname) {
+				return
 			}
 		}
 	}
 
 	if len(premain) > 0 {
-		c := o.additionalProperties
-		if c == nil {
-			return errors.New("additional properties are not allowed")
+		// Sort the remaining names so that the reported errors are
+		// deterministic
+		pnames := make([]string, 0, len(premain))
+		for pname := range premain {
+			pnames = append(pnames, pname)
 		}
+		sort.Strings(pnames)
 
-		for pname := range premain {
+		c := o.additionalProperties
+		for _, pname := range pnames {
 			pval := o.getProp(rv, pname)
-			if err := c.Validate(pval.Interface()); err != nil {
-				return errors.New("object property for '" + pname + "' validation failed: " + err.Error())
+			if c == nil {
+				if l.add(withPointer(newValidationError("additionalProperties", false, pval.Interface(), "additional properties are not allowed"), pname)) {
+					return
+				}
+				continue
+			}
+			if l.checkAt(c, pval.Interface(), pname) {
+				return
 			}
 		}
 	}
 
-	for pname := range pseen {
+	// Dependencies are triggered by (and satisfied by) any property
+	// that is present, not just the ones with a constraint
+	for _, pname := range fields {
 		if deps := o.GetPropDependencies(pname); len(deps) > 0 {
 			if pdebug.Enabled {
 				pdebug.Printf("Property '%s' has dependencies", pname)
 			}
 			for _, dep := range deps {
-				if _, ok := pseen[dep]; !ok {
-					return errors.New("required dependency '" + dep + "' is mising")
+				if _, ok := present[dep]; !ok {
+					if l.add(newValidationError("dependencies", dep, v, "required dependency '"+dep+"' is mising")) {
+						return
+					}
 				}
 			}
 
@@ -490,11 +620,32 @@ func (o *ObjectConstraint) Validate(v interface{}) (err error) {
 		}
 
 		if depc := o.GetSchemaDependency(pname); depc != nil {
-			if err := depc.Validate(v); err != nil {
-				return err
+			if l.check(depc, v) {
+				return
 			}
 		}
 	}
 
-	return nil
+	if c := o.unevaluatedProperties; c != nil {
+		if pdebug.Enabled {
+			pdebug.Printf("Checking unevaluated properties")
+		}
+		a := newAnnotations(l)
+		o.annotateEvaluated(rv, fields, a)
+
+		pnames := make([]string, 0, len(fields))
+		for _, pname := range fields {
+			if _, ok := a.props[pname]; !ok {
+				pnames = append(pnames, pname)
+			}
+		}
+		sort.Strings(pnames)
+
+		for _, pname := range pnames {
+			pval := o.getProp(rv, pname)
+			if l.checkAt(c, pval.Interface(), pname) {
+				return
+			}
+		}
+	}
 }
@@ -0,0 +1,138 @@
+package jsval
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// pointerOffset returns the byte offset of the value that the JSON
+// Pointer ptr points to within buf, or -1 if it could not be found.
+// buf must hold a single, well-formed JSON value.
+func pointerOffset(buf []byte, ptr string) int {
+	i := skipSpaces(buf, 0)
+	if ptr == "" {
+		return i
+	}
+	if ptr[0] != '/' {
+		return -1
+	}
+
+	for _, token := range strings.Split(ptr[1:], "/") {
+		token = strings.Replace(token, "~1", "/", -1)
+		token = strings.Replace(token, "~0", "~", -1)
+
+		if i >= len(buf) {
+			return -1
+		}
+
+		switch buf[i] {
+		case '{':
+			i = memberOffset(buf, i, token)
+		case '[':
+			i = elementOffset(buf, i, token)
+		default:
+			return -1
+		}
+		if i < 0 {
+			return -1
+		}
+	}
+	return i
+}
+
+// memberOffset returns the offset of the value of the member named
+// name in the object that starts at i. Like encoding/json, the last
+// member wins when a name appears more than once.
+func memberOffset(buf []byte, i int, name string) int {
+	found := -1
+	i = skipSpaces(buf, i+1)
+	for i < len(buf) && buf[i] != '}' {
+		end := skipValue(buf, i)
+		var key string
+		if err := json.Unmarshal(buf[i:end], &key); err != nil {
+			return -1
+		}
+
+		i = skipSpaces(buf, end)
+		if i >= len(buf) || buf[i] != ':' {
+			return -1
+		}
+		i = skipSpaces(buf, i+1)
+		if key == name {
+			found = i
+		}
+
+		i = skipSpaces(buf, skipValue(buf, i))
+		if i < len(buf) && buf[i] == ',' {
+			i = skipSpaces(buf, i+1)
+		}
+	}
+	return found
+}
+
+// elementOffset returns the offset of the element at the index given
+// in token in the array that starts at i.
+func elementOffset(buf []byte, i int, token string) int {
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 0 {
+		return -1
+	}
+
+	i = skipSpaces(buf, i+1)
+	for ; i < len(buf) && buf[i] != ']'; n-- {
+		if n == 0 {
+			return i
+		}
+		i = skipSpaces(buf, skipValue(buf, i))
+		if i < len(buf) && buf[i] == ',' {
+			i = skipSpaces(buf, i+1)
+		}
+	}
+	return -1
+}
+
+// skipValue returns the offset right after the value that starts at i
+func skipValue(buf []byte, i int) int {
+	depth := 0
+	for ; i < len(buf); i++ {
+		switch c := buf[i]; c {
+		case '"':
+			for i++; i < len(buf) && buf[i] != '"'; i++ {
+				if buf[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+			if depth < 0 {
+				// end of the enclosing container
+				return i
+			}
+		case ',', ':', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+func skipSpaces(buf []byte, i int) int {
+	for ; i < len(buf); i++ {
+		switch buf[i] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return i
+		}
+	}
+	return i
+}
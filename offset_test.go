@@ -0,0 +1,127 @@
+package jsval_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJSON(t *testing.T) {
+	v := jsval.New().SetRoot(
+		jsval.Object().
+			AddProp(`id`, jsval.Integer().Maximum(9007199254740992)).
+			AddProp(`price`, jsval.Number().MultipleOf(0.01)).
+			AddProp(`tags`, jsval.Array().Items(jsval.String().MaxLength(3))).
+			AddProp(`a/b`, jsval.Object().AddProp(`c`, jsval.String())),
+	)
+
+	t.Run("Valid", func(t *testing.T) {
+		const src = `{"id": 9007199254740992, "price": 19.99, "tags": ["foo"], "a/b": {"c": "x"}}`
+		if !assert.NoError(t, v.ValidateJSON([]byte(src)), "ValidateJSON should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.ValidateReader(strings.NewReader(src)), "ValidateReader should succeed") {
+			return
+		}
+	})
+
+	data := []struct {
+		Name    string
+		Input   string
+		Pointer string
+		Keyword string
+		Offset  int
+	}{
+		{
+			Name:    "Integer above 2^53",
+			Input:   `{"id": 9007199254740993}`,
+			Pointer: "/id",
+			Keyword: "maximum",
+			Offset:  7,
+		},
+		{
+			Name:    "Exact decimal",
+			Input:   `{"price": 19.999}`,
+			Pointer: "/price",
+			Keyword: "multipleOf",
+			Offset:  10,
+		},
+		{
+			Name:    "Array element",
+			Input:   "{\n  \"tags\": [\"foo\", \"barbaz\"]\n}",
+			Pointer: "/tags/1",
+			Keyword: "maxLength",
+			Offset:  20,
+		},
+		{
+			Name:    "Escaped name",
+			Input:   `{"a/b": {"x": [1, {"y": "}"}], "c": 1}}`,
+			Pointer: "/a~1b/c",
+			Keyword: "type",
+			Offset:  36,
+		},
+		{
+			Name:    "Number is not a string",
+			Input:   `{"tags": [1]}`,
+			Pointer: "/tags/0",
+			Keyword: "type",
+			Offset:  10,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			err := v.ValidateJSON([]byte(d.Input))
+			if !assert.Error(t, err, "ValidateJSON should fail") {
+				return
+			}
+
+			verr, ok := errors.Cause(err).(*jsval.ValidationError)
+			if !assert.True(t, ok, "cause should be a *jsval.ValidationError (got %T)", errors.Cause(err)) {
+				return
+			}
+			if !assert.Equal(t, d.Pointer, verr.Pointer, "pointer matches") {
+				return
+			}
+			if !assert.Equal(t, d.Keyword, verr.Keyword, "keyword matches") {
+				return
+			}
+			if !assert.Equal(t, d.Offset, verr.Offset, "offset matches") {
+				return
+			}
+		})
+	}
+
+	t.Run("Value is a json.Number", func(t *testing.T) {
+		err := v.ValidateJSON([]byte(`{"id": 1.5}`))
+		if !assert.Error(t, err, "ValidateJSON should fail") {
+			return
+		}
+		verr := errors.Cause(err).(*jsval.ValidationError)
+		if !assert.Equal(t, json.Number("1.5"), verr.Value, "value is the json.Number") {
+			return
+		}
+	})
+
+	t.Run("Offset is unavailable from Validate", func(t *testing.T) {
+		err := v.Validate(map[string]interface{}{"tags": []interface{}{"barbaz"}})
+		if !assert.Error(t, err, "Validate should fail") {
+			return
+		}
+		if !assert.Equal(t, -1, errors.Cause(err).(*jsval.ValidationError).Offset, "offset is -1") {
+			return
+		}
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		for _, src := range []string{`{"id": `, `{} {}`, ``} {
+			if !assert.Error(t, v.ValidateJSON([]byte(src)), "ValidateJSON should fail for %q", src) {
+				return
+			}
+		}
+	})
+}
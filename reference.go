@@ -1,13 +1,12 @@
 package jsval
 
 import (
-	"errors"
 	"sync"
 
 	"github.com/lestrrat-go/pdebug"
 )
 
-// RefResolver is a mandatory object that you must pass to a 
+// RefResolver is a mandatory object that you must pass to a
 // ReferenceConstraint upon its creation. This is responsible
 // for resolving the reference to an actual constraint.
 type RefResolver interface {
@@ -16,8 +15,9 @@ type RefResolver interface {
 
 // ConstraintMap is an implementation of RefResolver
 type ConstraintMap struct {
-	lock sync.Mutex
-	refs map[string]Constraint
+	lock        sync.Mutex
+	refs        map[string]Constraint
+	annotations map[string]Annotation
 }
 
 // Len returns the number of references stored in this ConstraintMap
@@ -49,11 +49,71 @@ func (cm *ConstraintMap) GetReference(name string) (Constraint, error) {
 	refs := cm.refmap()
 	c, ok := refs[name]
 	if !ok {
-		return nil, errors.New("reference '" + name + "' not found")
+		return nil, newValidationError("$ref", name, nil, "reference '"+name+"' not found")
 	}
 	return c, nil
 }
 
+// SetAnnotation associates the title and the description of a schema
+// with the reference name
+func (cm *ConstraintMap) SetAnnotation(name string, a Annotation) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if cm.annotations == nil {
+		cm.annotations = make(map[string]Annotation)
+	}
+	cm.annotations[name] = a
+}
+
+// GetAnnotation fetches the Annotation associated with the given name.
+// If there is none, the zero value is returned
+func (cm *ConstraintMap) GetAnnotation(name string) Annotation {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	return cm.annotations[name]
+}
+
+// DefaultMaxReferenceDepth is the maximum number of references that
+// may be followed one inside another while validating a value, unless
+// the validator specifies otherwise (see `JSVal.SetMaxReferenceDepth`).
+// Values that are nested deeper than this, such as data structures that
+// refer to themselves, fail to validate instead of exhausting the stack.
+const DefaultMaxReferenceDepth = 1000
+
+// ReferenceDepth is the number of references that are being followed
+// one inside another, and the maximum number allowed. It is passed
+// around by the code generated by the Compiler, which has no other way
+// to stop runaway recursion the way the constraints do.
+type ReferenceDepth struct {
+	depth int
+	max   int // DefaultMaxReferenceDepth if zero
+}
+
+// Follow returns the depth for validating the target of the reference
+// ref. It fails if the reference is nested too deeply.
+func (d ReferenceDepth) Follow(ref string) (ReferenceDepth, error) {
+	limit := d.max
+	if limit <= 0 {
+		limit = DefaultMaxReferenceDepth
+	}
+	if d.depth >= limit {
+		return d, newValidationError("$ref", limit, nil, "reference '"+ref+"' is nested too deeply")
+	}
+	d.depth++
+	return d, nil
+}
+
+// Validate validates v against c at the depth d. It is used for the
+// constraints that the Compiler does not compile, which may refer back
+// to the compiled code.
+func (d ReferenceDepth) Validate(c Constraint, v interface{}) error {
+	l := errorList{depth: d.depth, maxDepth: d.max}
+	l.check(c, v)
+	return l.err()
+}
+
 // ReferenceConstraint is a constraint where its actual definition
 // is stored elsewhere.
 type ReferenceConstraint struct {
@@ -145,9 +205,25 @@ func (r *ReferenceConstraint) Validate(v interface{}) (err error) {
 		}()
 	}
 
+	l := errorList{}
+	r.validate(v, &l)
+	return l.err()
+}
+
+func (r *ReferenceConstraint) validate(v interface{}, l *errorList) {
+	d, err := ReferenceDepth{depth: l.depth, max: l.maxDepth}.Follow(r.reference)
+	if err != nil {
+		l.add(err)
+		return
+	}
+
 	c, err := r.Resolved()
 	if err != nil {
-		return err
+		l.add(err)
+		return
 	}
-	return c.Validate(v)
-}
\ No newline at end of file
+
+	defer func(depth int) { l.depth = depth }(l.depth)
+	l.depth = d.depth
+	l.check(c, v)
+}
@@ -0,0 +1,75 @@
+package jsval_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type referenceTestNode struct {
+	Next *referenceTestNode `json:"next,omitempty"`
+}
+
+func TestReferenceDepth(t *testing.T) {
+	// {"type": "object", "properties": {"next": {"$ref": "#"}}}
+	v := jsval.New()
+	v.SetRoot(jsval.Object().AddProp("next", jsval.Reference(v).RefersTo("#")))
+	v.SetReference("#", v.Root())
+
+	t.Run("Cycle", func(t *testing.T) {
+		n := &referenceTestNode{}
+		n.Next = n
+
+		err := v.Validate(n)
+		if !assert.Error(t, err, "Validate should fail") {
+			return
+		}
+		verr, ok := errors.Cause(err).(*jsval.ValidationError)
+		if !assert.True(t, ok, "cause should be a *jsval.ValidationError (got %T)", errors.Cause(err)) {
+			return
+		}
+		if !assert.Equal(t, "$ref", verr.Keyword, "the reference depth is exceeded") {
+			return
+		}
+
+		if !assert.NotEmpty(t, v.ValidateAll(n), "ValidateAll should fail") {
+			return
+		}
+	})
+
+	t.Run("AnyOf", func(t *testing.T) {
+		// The depth is kept track of through constraints that only
+		// check whether the value passes another one
+		v := jsval.New()
+		v.SetRoot(jsval.Any().
+			Add(jsval.NullConstraint).
+			Add(jsval.Object().AddProp("next", jsval.Reference(v).RefersTo("#"))),
+		)
+		v.SetReference("#", v.Root())
+
+		m := map[string]interface{}{}
+		m["next"] = m
+		if !assert.Error(t, v.Validate(m), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		v := jsval.New().SetMaxReferenceDepth(2)
+		v.SetRoot(jsval.Object().AddProp("next", jsval.Reference(v).RefersTo("#")))
+		v.SetReference("#", v.Root())
+
+		n := &referenceTestNode{Next: &referenceTestNode{Next: &referenceTestNode{Next: &referenceTestNode{}}}}
+		if !assert.Error(t, v.Validate(n), "Validate should fail") {
+			return
+		}
+		if !assert.Len(t, v.ValidateAll(n), 1, "ValidateAll should fail") {
+			return
+		}
+		if !assert.NoError(t, v.Validate(n.Next), "Validate should succeed") {
+			return
+		}
+	})
+}
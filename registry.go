@@ -0,0 +1,322 @@
+package jsval
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lestrrat-go/jsval/internal/fileuri"
+	"github.com/pkg/errors"
+)
+
+// SchemaBuilder builds the validators that a Registry hands out.
+// `builder.Builder` implements it.
+type SchemaBuilder interface {
+	// BuildShared builds the validator for the schema at uri. Documents
+	// are looked up with lookup, and the constraints of referenced
+	// schemas are stored in, and reused from, cm
+	BuildShared(uri string, lookup func(string) (interface{}, bool), cm *ConstraintMap) (*JSVal, error)
+}
+
+// Registry holds a set of JSON schemas keyed by URI, and hands out
+// validators for them. Validators are built the first time they are
+// asked for, and share the constraints of the schemas that they refer
+// to, so that schemas used by many others (such as "common.json") are
+// only built once. A Registry is safe for concurrent use.
+type Registry struct {
+	builder SchemaBuilder
+	lock    sync.RWMutex
+	set     *schemaSet
+}
+
+// schemaSet holds the schemas of a Registry, and the validators that
+// were built for them. The documents are never modified: registering
+// or reloading schemas replaces the whole set.
+type schemaSet struct {
+	lock       *sync.Mutex // serializes builds, which share cm
+	docs       map[string]interface{}
+	names      map[string]string
+	cm         *ConstraintMap
+	validators map[string]*JSVal
+	// aliases maps the "$id"s that documents can be referred to by to
+	// the URIs that the documents were registered under
+	aliases map[string]string
+	// deps holds the URIs of the documents that each validator was
+	// built from, including the ones that were looked up but missing
+	deps map[string]map[string]struct{}
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{
+		lock:       &sync.Mutex{},
+		docs:       make(map[string]interface{}),
+		names:      make(map[string]string),
+		aliases:    make(map[string]string),
+		cm:         &ConstraintMap{},
+		validators: make(map[string]*JSVal),
+		deps:       make(map[string]map[string]struct{}),
+	}
+}
+
+// NewRegistry creates a new, empty Registry that builds validators
+// with b, e.g. `jsval.NewRegistry(builder.New())`
+func NewRegistry(b SchemaBuilder) *Registry {
+	return &Registry{
+		builder: b,
+		set:     newSchemaSet(),
+	}
+}
+
+// Register adds the decoded JSON schema doc under the absolute URI
+// uri, and under name if it is not empty. If doc has an absolute
+// "$id" (or "id"), it can be referred to by that as well, unless a
+// schema is registered under that URI. Validators that were handed out
+// before keep working as they did.
+//
+// Validators that were built before, and do not use doc, are kept.
+// If none of them use doc, the constraints that they were built from
+// are kept as well, so registering all of the schemas before asking
+// for any validator, or registering new schemas, costs nothing.
+// Replacing a schema that is in use means that the validators built
+// afterwards do not share constraints with the ones from before.
+func (r *Registry) Register(name, uri string, doc interface{}) *Registry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	old := r.set
+	set := newSchemaSet()
+	for k, v := range old.docs {
+		set.docs[k] = v
+	}
+	for k, v := range old.names {
+		set.names[k] = v
+	}
+	for k, v := range old.aliases {
+		set.aliases[k] = v
+	}
+	changed := set.add(name, uri, doc)
+
+	old.lock.Lock()
+	defer old.lock.Unlock()
+
+	affected := false
+	for key, v := range old.validators {
+		if dependsOn(old.deps[key], changed) {
+			affected = true
+			continue
+		}
+		set.validators[key] = v
+		set.deps[key] = old.deps[key]
+	}
+	if !affected {
+		set.lock = old.lock
+		set.cm = old.cm
+	}
+	r.set = set
+	return r
+}
+
+func dependsOn(deps map[string]struct{}, uris []string) bool {
+	for _, uri := range uris {
+		if _, ok := deps[uri]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDir replaces the schemas in the registry with the files with a
+// ".json" extension in dir and its subdirectories. Each file is
+// registered under its "file" URI, and named after its path relative
+// to dir, without the extension (e.g. "orders/order"). The schemas are
+// replaced all at once, and only if all of the files could be read,
+// so LoadDir can be used to reload schemas while validators are in use.
+func (r *Registry) LoadDir(dir string) error {
+	set := newSchemaSet()
+	err := filepath.Walk(dir, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || filepath.Ext(fn) != ".json" {
+			return nil
+		}
+
+		doc, err := readDocument(fn)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		uri, err := fileuri.FromPath(fn)
+		if err != nil {
+			return err
+		}
+		set.add(strings.TrimSuffix(filepath.ToSlash(rel), ".json"), uri, doc)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to load schemas from %s", dir)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.set = set
+	return nil
+}
+
+// readDocument decodes the JSON document in the file fn. Numbers are
+// kept as json.Number, so that they are not rounded.
+func readDocument(fn string) (interface{}, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", fn)
+	}
+	return doc, nil
+}
+
+// Names returns the names of the schemas in the registry, sorted
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	l := make([]string, 0, len(r.set.names))
+	for name := range r.set.names {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// Validator returns the validator for the schema registered under
+// the name or URI key. The URI may have a fragment, such as
+// "https://example.com/common.json#/definitions/address".
+func (r *Registry) Validator(key string) (*JSVal, error) {
+	r.lock.RLock()
+	set := r.set
+	r.lock.RUnlock()
+
+	uri, ok := set.names[key]
+	if !ok {
+		uri = key
+	}
+	return set.validator(r.builder, key, uri)
+}
+
+// add adds doc to the set, and returns the URIs whose documents were
+// added, replaced or removed
+func (set *schemaSet) add(name, uri string, doc interface{}) []string {
+	if i := strings.IndexByte(uri, '#'); i > -1 {
+		uri = uri[:i]
+	}
+	set.docs[uri] = doc
+	delete(set.aliases, uri)
+	if name != "" {
+		set.names[name] = uri
+	}
+	changed := []string{uri}
+
+	// The identifiers of the document that doc replaces, if any, no
+	// longer refer to anything
+	for alias, target := range set.aliases {
+		if target == uri {
+			delete(set.docs, alias)
+			delete(set.aliases, alias)
+			changed = append(changed, alias)
+		}
+	}
+
+	// Other schemas may refer to doc by its identifier, unless a
+	// document was registered under that URI
+	if m, ok := doc.(map[string]interface{}); ok {
+		for _, key := range []string{"$id", "id"} {
+			id, ok := m[key].(string)
+			if !ok {
+				continue
+			}
+			u, err := url.Parse(id)
+			if err != nil || !u.IsAbs() {
+				continue
+			}
+			u.Fragment = ""
+			alias := u.String()
+			if _, ok := set.docs[alias]; ok {
+				if _, ok := set.aliases[alias]; !ok {
+					continue
+				}
+			}
+			set.docs[alias] = doc
+			set.aliases[alias] = uri
+			changed = append(changed, alias)
+		}
+	}
+	return changed
+}
+
+func (set *schemaSet) lookup(uri string) (interface{}, bool) {
+	doc, ok := set.docs[uri]
+	return doc, ok
+}
+
+func (set *schemaSet) validator(b SchemaBuilder, name, uri string) (*JSVal, error) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if v, ok := set.validators[uri]; ok {
+		return v, nil
+	}
+
+	doc := uri
+	if i := strings.IndexByte(uri, '#'); i > -1 {
+		doc = uri[:i]
+	}
+	if _, ok := set.docs[doc]; !ok {
+		return nil, errors.Errorf("schema %s is not registered", name)
+	}
+
+	deps := make(map[string]struct{})
+	lookup := func(uri string) (interface{}, bool) {
+		deps[uri] = struct{}{}
+		return set.lookup(uri)
+	}
+	v, err := b.BuildShared(uri, lookup, set.cm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to build validator for %s", name)
+	}
+	v.SetName(set.name(uri))
+	set.validators[uri] = v
+	set.deps[uri] = deps
+	return v, nil
+}
+
+// name returns the name that the schema at uri is registered under,
+// or uri itself if it has none. The first name in sort order is used
+// if there are several.
+func (set *schemaSet) name(uri string) string {
+	var names []string
+	for name, u := range set.names {
+		if u == uri {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return uri
+	}
+	sort.Strings(names)
+	return names[0]
+}
@@ -0,0 +1,351 @@
+package jsval_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+var registryTestFiles = map[string]string{
+	"common.json": `{
+  "definitions": {
+    "address": {
+      "type": "object",
+      "properties": { "zip": { "type": "string", "pattern": "^[0-9]+$" } },
+      "required": [ "zip" ]
+    }
+  }
+}`,
+	"orders/order.json": `{
+  "type": "object",
+  "properties": { "shipTo": { "$ref": "../common.json#/definitions/address" } },
+  "required": [ "shipTo" ]
+}`,
+	"users/user.json": `{
+  "type": "object",
+  "properties": { "home": { "$ref": "../common.json#/definitions/address" } }
+}`,
+}
+
+func writeRegistryTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, src := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(fn), 0755), "MkdirAll should succeed") {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(fn, []byte(src), 0644), "WriteFile should succeed") {
+			t.FailNow()
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsval-registry")
+	if !assert.NoError(t, err, "TempDir should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	writeRegistryTestFiles(t, dir, registryTestFiles)
+
+	r := jsval.NewRegistry(builder.New())
+	if !assert.NoError(t, r.LoadDir(dir), "LoadDir should succeed") {
+		return
+	}
+	if !assert.Equal(t, []string{"common", "orders/order", "users/user"}, r.Names(), "schemas are named after their paths") {
+		return
+	}
+
+	order, err := r.Validator("orders/order")
+	if !assert.NoError(t, err, "Validator should succeed") {
+		return
+	}
+	if !assert.NoError(t, order.Validate(map[string]interface{}{"shipTo": map[string]interface{}{"zip": "123"}}), "Validate should succeed") {
+		return
+	}
+	if !assert.Error(t, order.Validate(map[string]interface{}{"shipTo": map[string]interface{}{"zip": "abc"}}), "Validate should fail") {
+		return
+	}
+
+	t.Run("Cache", func(t *testing.T) {
+		uri := "file://" + filepath.ToSlash(filepath.Join(dir, "orders", "order.json"))
+		v, err := r.Validator(uri)
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.True(t, v == order, "the same validator is returned by name and by URI") {
+			return
+		}
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		user, err := r.Validator("users/user")
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.True(t, user.ConstraintMap == order.ConstraintMap, "validators share a ConstraintMap") {
+			return
+		}
+
+		address, err := r.Validator("file://" + filepath.ToSlash(filepath.Join(dir, "common.json")) + "#/definitions/address")
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.Error(t, address.Validate(map[string]interface{}{}), "Validate should fail") {
+			return
+		}
+
+		// The address constraint was built once, for the order schema
+		key := "file://" + filepath.ToSlash(filepath.Join(dir, "common.json")) + "#/definitions/address"
+		c, err := order.GetReference(key)
+		if !assert.NoError(t, err, "reference %s should exist", key) {
+			return
+		}
+		if !assert.True(t, c == address.Root(), "constraints are reused") {
+			return
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, name := range []string{"common", "orders/order", "users/user"} {
+					if _, err := r.Validator(name); !assert.NoError(t, err, "Validator should succeed") {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		writeRegistryTestFiles(t, dir, map[string]string{
+			"common.json": `{ "definitions": { "address": { "type": "object" } } }`,
+		})
+		if !assert.NoError(t, r.LoadDir(dir), "LoadDir should succeed") {
+			return
+		}
+
+		v, err := r.Validator("orders/order")
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.Validate(map[string]interface{}{"shipTo": map[string]interface{}{}}), "reloaded schemas are used") {
+			return
+		}
+		if !assert.Error(t, order.Validate(map[string]interface{}{"shipTo": map[string]interface{}{}}), "validators handed out before keep working") {
+			return
+		}
+
+		// Broken files leave the registry as it was
+		writeRegistryTestFiles(t, dir, map[string]string{"broken.json": `{`})
+		if !assert.Error(t, r.LoadDir(dir), "LoadDir should fail") {
+			return
+		}
+		if _, err := r.Validator("broken"); !assert.Error(t, err, "Validator should fail") {
+			return
+		}
+		if _, err := r.Validator("orders/order"); !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+	})
+
+	t.Run("Numbers", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "jsval-registry")
+		if !assert.NoError(t, err, "TempDir should succeed") {
+			return
+		}
+		defer os.RemoveAll(dir)
+		// 9007199254740993 can not be represented as a float64
+		writeRegistryTestFiles(t, dir, map[string]string{
+			"id.json": `{ "type": "integer", "maximum": 9007199254740993 }`,
+		})
+
+		r := jsval.NewRegistry(builder.New())
+		if !assert.NoError(t, r.LoadDir(dir), "LoadDir should succeed") {
+			return
+		}
+		v, err := r.Validator("id")
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.NoError(t, v.Validate(json.Number("9007199254740993")), "Validate should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(json.Number("9007199254740994")), "Validate should fail") {
+			return
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		r := jsval.NewRegistry(builder.New()).
+			Register("", "https://example.com/common.json", map[string]interface{}{
+				"definitions": map[string]interface{}{
+					"id": map[string]interface{}{"type": "string"},
+				},
+			}).
+			Register("item", "https://example.com/item.json", map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"$ref": "common.json#/definitions/id"},
+				},
+			})
+
+		v, err := r.Validator("item")
+		if !assert.NoError(t, err, "Validator should succeed") {
+			return
+		}
+		if !assert.Error(t, v.Validate(map[string]interface{}{"id": 1}), "Validate should fail") {
+			return
+		}
+
+		if _, err := r.Validator("https://example.com/missing.json"); !assert.Error(t, err, "Validator should fail") {
+			return
+		}
+
+		t.Run("Name", func(t *testing.T) {
+			v, err := r.Validator("https://example.com/item.json")
+			if !assert.NoError(t, err, "Validator should succeed") {
+				return
+			}
+			if !assert.Equal(t, "item", v.Name, "validators are named after the schema, not the key") {
+				return
+			}
+
+			v, err = r.Validator("https://example.com/common.json#/definitions/id")
+			if !assert.NoError(t, err, "Validator should succeed") {
+				return
+			}
+			if !assert.Equal(t, "https://example.com/common.json#/definitions/id", v.Name, "schemas without a name are named after their URI") {
+				return
+			}
+		})
+
+		t.Run("Keep", func(t *testing.T) {
+			r := jsval.NewRegistry(builder.New()).
+				Register("common", "https://example.com/common.json", map[string]interface{}{
+					"definitions": map[string]interface{}{
+						"id": map[string]interface{}{"type": "string"},
+					},
+				}).
+				Register("item", "https://example.com/item.json", map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{"$ref": "common.json#/definitions/id"},
+					},
+				}).
+				Register("user", "https://example.com/user.json", map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{"$ref": "common.json#/definitions/id"},
+					},
+				}).
+				Register("tag", "https://example.com/tag.json", map[string]interface{}{
+					"type": "string",
+				})
+
+			validators := make(map[string]*jsval.JSVal)
+			for _, name := range []string{"item", "user", "tag"} {
+				v, err := r.Validator(name)
+				if !assert.NoError(t, err, "Validator should succeed") {
+					return
+				}
+				validators[name] = v
+			}
+
+			// New schemas do not affect the validators that were built
+			r.Register("order", "https://example.com/order.json", map[string]interface{}{
+				"type": "object",
+			})
+			for name, v := range validators {
+				v2, err := r.Validator(name)
+				if !assert.NoError(t, err, "Validator should succeed") {
+					return
+				}
+				if !assert.True(t, v == v2, "validator %s is kept", name) {
+					return
+				}
+			}
+			order, err := r.Validator("order")
+			if !assert.NoError(t, err, "Validator should succeed") {
+				return
+			}
+			if !assert.True(t, order.ConstraintMap == validators["item"].ConstraintMap, "constraints are still shared") {
+				return
+			}
+
+			// Replacing a schema only rebuilds the validators that use it,
+			// even if they only refer to it through shared constraints
+			r.Register("common", "https://example.com/common.json", map[string]interface{}{
+				"definitions": map[string]interface{}{
+					"id": map[string]interface{}{"type": "integer"},
+				},
+			})
+			for name, kept := range map[string]bool{"item": false, "user": false, "tag": true} {
+				v, err := r.Validator(name)
+				if !assert.NoError(t, err, "Validator should succeed") {
+					return
+				}
+				if !assert.Equal(t, kept, v == validators[name], "validator %s is kept: %t", name, kept) {
+					return
+				}
+			}
+			for _, name := range []string{"item", "user"} {
+				v, err := r.Validator(name)
+				if !assert.NoError(t, err, "Validator should succeed") {
+					return
+				}
+				if !assert.NoError(t, v.Validate(map[string]interface{}{"id": 1}), "%s uses the new schema", name) {
+					return
+				}
+				if !assert.Error(t, validators[name].Validate(map[string]interface{}{"id": 1}), "%s handed out before uses the old schema", name) {
+					return
+				}
+			}
+		})
+
+		t.Run("ID", func(t *testing.T) {
+			limit := func(id string, max float64) map[string]interface{} {
+				return map[string]interface{}{"$id": id, "type": "number", "maximum": max}
+			}
+			r := jsval.NewRegistry(builder.New()).
+				Register("limit", "https://example.com/limit.json", limit("https://example.com/schemas/limit", 10)).
+				Register("item", "https://example.com/item.json", map[string]interface{}{
+					"properties": map[string]interface{}{
+						"count": map[string]interface{}{"$ref": "https://example.com/schemas/limit"},
+					},
+				})
+
+			v, err := r.Validator("item")
+			if !assert.NoError(t, err, "Validator should succeed") {
+				return
+			}
+			if !assert.Error(t, v.Validate(map[string]interface{}{"count": 50}), "Validate should fail") {
+				return
+			}
+
+			// The "$id" refers to the document that replaced the old one
+			r.Register("limit", "https://example.com/limit.json", limit("https://example.com/schemas/limit", 100))
+			v, err = r.Validator("item")
+			if !assert.NoError(t, err, "Validator should succeed") {
+				return
+			}
+			if !assert.NoError(t, v.Validate(map[string]interface{}{"count": 50}), "Validate should succeed") {
+				return
+			}
+
+			// The old "$id" no longer refers to anything
+			r.Register("limit", "https://example.com/limit.json", limit("https://example.com/schemas/limit2", 100))
+			if _, err := r.Validator("item"); !assert.Error(t, err, "Validator should fail") {
+				return
+			}
+		})
+	})
+}
@@ -165,16 +165,20 @@ func (s *Server) httpGenerateValidator(ctx context.Context, w http.ResponseWrite
 	buf := bytes.Buffer{}
 	io.Copy(&buf, r.Body)
 
-	sc, err := schema.Read(bytes.NewReader(buf.Bytes()))
-	if err != nil {
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseNumber()
+
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
 		resp["success"] = false
 		resp["message"] = err.Error()
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
+	m = builder.NormalizeSchema(m)
 
-	var m map[string]interface{}
-	if err := json.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&m); err != nil {
+	sc := schema.New()
+	if err := sc.Extract(m); err != nil {
 		resp["success"] = false
 		resp["message"] = err.Error()
 		json.NewEncoder(w).Encode(resp)
@@ -0,0 +1,368 @@
+package jsval
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/lestrrat-go/pdebug"
+)
+
+var errStreamStopped = errors.New("stream validation stopped")
+
+// streamer validates a JSON document token by token, as it is being
+// read from a json.Decoder. Objects and arrays are walked one member
+// at a time. Values that can't be validated that way (scalars, and
+// values whose constraints need to look at the entire value, such
+// as uniqueItems or anyOf) are decoded and validated as a whole.
+type streamer struct {
+	dec      *json.Decoder
+	fn       func(error) bool
+	maxDepth int
+}
+
+// ValidateStream validates the JSON document read from r without
+// decoding it into memory as a whole, which makes it possible to
+// validate documents that are larger than the available memory.
+//
+// Each validation error is passed to fn as soon as it is found, e.g.
+// when an invalid element of a large array is read. The errors are
+// `*ValidationError`s that point to the offending value. Validation
+// stops if fn returns false.
+//
+// Only the values whose constraints need to see the entire value,
+// such as `uniqueItems`, `contains` or a combination of constraints,
+// are buffered in memory. Default values are not applied.
+//
+// The returned error reports problems reading or decoding the
+// input, and is nil otherwise.
+func (v *JSVal) ValidateStream(r io.Reader, fn func(error) bool) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	s := &streamer{dec: dec, fn: fn, maxDepth: v.maxDepth}
+	if err := s.value(v.root, ""); err != nil {
+		if err == errStreamStopped {
+			return nil
+		}
+		return decodeError(err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("failed to decode JSON: extra data after the top-level value")
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.New("failed to decode JSON: " + err.Error())
+}
+
+// report passes err to the callback. It returns errStreamStopped
+// if the callback asked to stop.
+func (s *streamer) report(err error, ptr string) error {
+	if s.fn(withPointerPrefix(err, ptr)) {
+		return nil
+	}
+	return errStreamStopped
+}
+
+// value validates the next value in the stream against c
+func (s *streamer) value(c Constraint, ptr string) error {
+	if rc, ok := c.(*ReferenceConstraint); ok {
+		resolved, err := rc.Resolved()
+		if err != nil {
+			if err := s.report(err, ptr); err != nil {
+				return err
+			}
+			return s.skip(0)
+		}
+		c = resolved
+	}
+
+	switch c := c.(type) {
+	case emptyConstraint:
+		return s.skip(0)
+	case *ObjectConstraint:
+		if c.unevaluatedProperties == nil && len(c.schemadeps) == 0 {
+			return s.object(c, ptr)
+		}
+	case *ArrayConstraint:
+		if !c.uniqueItems && c.unevaluatedItems == nil {
+			return s.array(c, ptr)
+		}
+	}
+	return s.buffered(ptr, c)
+}
+
+// validate validates v against c, and returns all of the errors found
+func (s *streamer) validate(c Constraint, v interface{}) []error {
+	l := errorList{collect: true, maxDepth: s.maxDepth}
+	return l.run(c, v)
+}
+
+// buffered decodes the next value in the stream, and validates it
+// against all of the given constraints
+func (s *streamer) buffered(ptr string, l ...Constraint) error {
+	if pdebug.Enabled {
+		pdebug.Printf("Buffering value at '%s'", ptr)
+	}
+
+	var v interface{}
+	if err := s.dec.Decode(&v); err != nil {
+		return err
+	}
+
+	for _, c := range l {
+		for _, err := range s.validate(c, v) {
+			if err := s.report(err, ptr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skip discards the rest of the value that is nested depth levels
+// deep in the stream. Use depth 0 to discard the next value
+func (s *streamer) skip(depth int) error {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth <= 0 {
+			return nil
+		}
+	}
+}
+
+// start reads the token that starts the next value. If the value
+// is not a container of the expected kind, it is validated against
+// c (scalars), or reported as a type mismatch (containers) and skipped.
+// It returns false if the caller should not proceed.
+func (s *streamer) start(c Constraint, delim json.Delim, typ string, ptr string) (bool, error) {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return false, err
+	}
+
+	switch tok {
+	case delim:
+		return true, nil
+	case json.Delim('{'), json.Delim('['):
+		if err := s.report(newValidationError("type", typ, nil, "value is not an "+typ), ptr); err != nil {
+			return false, err
+		}
+		return false, s.skip(1)
+	}
+
+	for _, err := range s.validate(c, tok) {
+		if err := s.report(err, ptr); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (s *streamer) object(o *ObjectConstraint, ptr string) error {
+	if pdebug.Enabled {
+		g := pdebug.IPrintf("START streamer.object (%s)", ptr)
+		defer g.IRelease("END streamer.object")
+	}
+
+	if ok, err := s.start(o, '{', "object", ptr); !ok {
+		return err
+	}
+
+	var names []string
+	present := make(map[string]struct{})
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		pname := tok.(string)
+		pptr := ptr + "/" + escapePointerToken(pname)
+		if _, ok := present[pname]; !ok {
+			names = append(names, pname)
+			present[pname] = struct{}{}
+		}
+
+		if c := o.propertyNames; c != nil {
+			for _, err := range s.validate(c, pname) {
+				if err := s.report(err, pptr); err != nil {
+					return err
+				}
+			}
+		}
+
+		var l []Constraint
+		if c := o.getPropConstraint(pname); c != nil {
+			l = append(l, c)
+		}
+		l = append(l, o.getPatternPropConstraints(pname)...)
+		if len(l) == 0 {
+			if o.additionalProperties == nil {
+				var v interface{}
+				if err := s.dec.Decode(&v); err != nil {
+					return err
+				}
+				if err := s.report(newValidationError("additionalProperties", false, v, "additional properties are not allowed"), pptr); err != nil {
+					return err
+				}
+				continue
+			}
+			l = append(l, o.additionalProperties)
+		}
+
+		if len(l) == 1 {
+			err = s.value(l[0], pptr)
+		} else {
+			err = s.buffered(pptr, l...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// consume the closing '}'
+	if _, err := s.dec.Token(); err != nil {
+		return err
+	}
+
+	if n := len(names); o.minProperties > -1 && n < o.minProperties {
+		if err := s.report(newValidationError("minProperties", o.minProperties, n, "fewer properties than minProperties"), ptr); err != nil {
+			return err
+		}
+	}
+	if n := len(names); o.maxProperties > -1 && n > o.maxProperties {
+		if err := s.report(newValidationError("maxProperties", o.maxProperties, n, "more properties than maxProperties"), ptr); err != nil {
+			return err
+		}
+	}
+
+	o.reqlock.Lock()
+	reqnames := make([]string, 0, len(o.required))
+	for pname := range o.required {
+		reqnames = append(reqnames, pname)
+	}
+	o.reqlock.Unlock()
+	sort.Strings(reqnames)
+
+	for _, pname := range reqnames {
+		if _, ok := present[pname]; !ok {
+			if err := s.report(newValidationError("required", pname, nil, "object property '"+pname+"' is required"), ptr); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, pname := range names {
+		for _, dep := range o.GetPropDependencies(pname) {
+			if _, ok := present[dep]; !ok {
+				if err := s.report(newValidationError("dependencies", dep, nil, "required dependency '"+dep+"' is mising"), ptr); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *streamer) array(c *ArrayConstraint, ptr string) error {
+	if pdebug.Enabled {
+		g := pdebug.IPrintf("START streamer.array (%s)", ptr)
+		defer g.IRelease("END streamer.array")
+	}
+
+	if ok, err := s.start(c, '[', "array", ptr); !ok {
+		return err
+	}
+
+	lp := len(c.positionalItems)
+	found := c.contains == nil
+	n := 0
+	for ; s.dec.More(); n++ {
+		iptr := ptr + "/" + strconv.Itoa(n)
+
+		var celem Constraint
+		switch {
+		case c.items != nil:
+			celem = c.items
+		case n < lp:
+			celem = c.positionalItems[n]
+		case lp > 0:
+			celem = c.additionalItems
+			if celem == nil {
+				var v interface{}
+				if err := s.dec.Decode(&v); err != nil {
+					return err
+				}
+				if n == lp {
+					if err := s.report(newValidationError("additionalItems", false, v, "additional elements found in array"), iptr); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		default:
+			celem = EmptyConstraint
+		}
+
+		if found {
+			if err := s.value(celem, iptr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The element is needed as a whole to check against contains
+		var v interface{}
+		if err := s.dec.Decode(&v); err != nil {
+			return err
+		}
+		if c.contains.Validate(v) == nil {
+			found = true
+		}
+		for _, err := range s.validate(celem, v) {
+			if err := s.report(err, iptr); err != nil {
+				return err
+			}
+		}
+	}
+
+	// consume the closing ']'
+	if _, err := s.dec.Token(); err != nil {
+		return err
+	}
+
+	if mi := c.minItems; mi > -1 && n < mi {
+		if err := s.report(newValidationError("minItems", mi, n, "fewer items than minItems"), ptr); err != nil {
+			return err
+		}
+	}
+	if mi := c.maxItems; mi > -1 && n > mi {
+		if err := s.report(newValidationError("maxItems", mi, n, "more items than maxItems"), ptr); err != nil {
+			return err
+		}
+	}
+	if !found {
+		if err := s.report(newValidationError("contains", nil, nil, "no element matches the contains constraint"), ptr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
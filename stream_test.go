@@ -0,0 +1,138 @@
+package jsval_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+func collectStream(v *jsval.JSVal, r io.Reader) ([]string, error) {
+	var l []string
+	err := v.ValidateStream(r, func(err error) bool {
+		verr := err.(*jsval.ValidationError)
+		l = append(l, verr.Pointer+" "+verr.Keyword)
+		return true
+	})
+	return l, err
+}
+
+func TestValidateStream(t *testing.T) {
+	record := jsval.Object().
+		AddProp(`id`, jsval.Integer().Minimum(1)).
+		AddProp(`name`, jsval.String().MaxLength(5)).
+		AddProp(`tags`, jsval.Array().Items(jsval.String()).UniqueItems(true)).
+		PatternPropertiesString(`^x-`, jsval.String()).
+		PropDependency(`name`, `id`).
+		Required(`name`)
+	v := jsval.New().SetRoot(jsval.Array().Items(record).MinItems(1))
+
+	t.Run("Records", func(t *testing.T) {
+		const src = `[
+  {"id": 1, "name": "foo", "tags": ["a", "b"]},
+  {"id": 0, "name": "foobar"},
+  {"name": "bar", "tags": ["a", "a"], "x-foo": 1},
+  {"id": 3},
+  "foo",
+  [1, 2]
+]`
+		l, err := collectStream(v, strings.NewReader(src))
+		if !assert.NoError(t, err, "ValidateStream should succeed") {
+			return
+		}
+
+		expected := []string{
+			"/1/id minimum",
+			"/1/name maxLength",
+			"/2/tags/1 uniqueItems",
+			"/2/x-foo type",
+			"/2 dependencies",
+			"/3 required",
+			"/4 type",
+			"/5 type",
+		}
+		if !assert.Equal(t, expected, l, "errors match") {
+			return
+		}
+	})
+
+	t.Run("Whole array", func(t *testing.T) {
+		l, err := collectStream(v, strings.NewReader(`[]`))
+		if !assert.NoError(t, err, "ValidateStream should succeed") {
+			return
+		}
+		if !assert.Equal(t, []string{" minItems"}, l, "errors match") {
+			return
+		}
+	})
+
+	t.Run("Large input", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i := 0; i < 10000; i++ {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			id := i + 1
+			if i%1000 == 999 {
+				id = 0
+			}
+			fmt.Fprintf(&buf, `{"id": %d, "name": "rec"}`, id)
+		}
+		buf.WriteString("]")
+
+		l, err := collectStream(v, &buf)
+		if !assert.NoError(t, err, "ValidateStream should succeed") {
+			return
+		}
+		if !assert.Len(t, l, 10, "one error every 1000 records") {
+			return
+		}
+		if !assert.Equal(t, "/999/id minimum", l[0], "first error matches") {
+			return
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		count := 0
+		err := v.ValidateStream(strings.NewReader(`[{"id": 0, "name": "x"}, {"id": 0, "name": "y"}]`), func(err error) bool {
+			count++
+			return false
+		})
+		if !assert.NoError(t, err, "ValidateStream should succeed") {
+			return
+		}
+		if !assert.Equal(t, 1, count, "stopped after the first error") {
+			return
+		}
+	})
+
+	t.Run("Positional items and contains", func(t *testing.T) {
+		v := jsval.New().SetRoot(
+			jsval.Array().
+				PositionalItems([]jsval.Constraint{jsval.String(), jsval.Integer()}).
+				AdditionalItems(nil).
+				Contains(jsval.Integer().Minimum(10)),
+		)
+		l, err := collectStream(v, strings.NewReader(`["a", 1, true, null]`))
+		if !assert.NoError(t, err, "ValidateStream should succeed") {
+			return
+		}
+		if !assert.Equal(t, []string{"/2 additionalItems", " contains"}, l, "errors match") {
+			return
+		}
+	})
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		for _, src := range []string{`[{"id": 1, "name": "x"}`, `[] []`, `[{"id": }]`} {
+			_, err := collectStream(v, strings.NewReader(src))
+			if !assert.Error(t, err, "ValidateStream should fail for %q", src) {
+				return
+			}
+		}
+	})
+}
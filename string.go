@@ -1,14 +1,10 @@
 package jsval
 
 import (
-	"errors"
-	"net"
-	"net/mail"
-	"net/url"
 	"reflect"
 	"regexp"
 	"strconv"
-	"time"
+	"unicode/utf8"
 
 	"github.com/lestrrat-go/pdebug"
 )
@@ -38,26 +34,30 @@ func (sc *StringConstraint) Validate(v interface{}) (err error) {
 			}
 		}()
 	}
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		rv = rv.Elem()
-	}
 
-	switch rv.Kind() {
-	case reflect.String:
-	default:
-		return errors.New("value is not a string (Kind: " + rv.Kind().String() + ")")
+	l := errorList{}
+	sc.validate(v, &l)
+	return l.err()
+}
+
+func (sc *StringConstraint) validate(v interface{}, l *errorList) {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	if kind != jsonString {
+		l.add(newValidationError("type", "string", v, "value is not a string (was: "+kind.String()+")"))
+		return
 	}
 
+	// Lengths are counted in characters, not bytes
 	str := rv.String()
-	ls := len(str)
+	ls := utf8.RuneCountInString(str)
 	if sc.maxLength > -1 {
 		if pdebug.Enabled {
 			pdebug.Printf("Checking MaxLength (%d)", sc.maxLength)
 		}
 		if ls > sc.maxLength {
-			return errors.New("string longer than maxLength " + strconv.Itoa(sc.maxLength))
+			if l.add(newValidationError("maxLength", sc.maxLength, str, "string longer than maxLength "+strconv.Itoa(sc.maxLength))) {
+				return
+			}
 		}
 	}
 
@@ -66,51 +66,14 @@ func (sc *StringConstraint) Validate(v interface{}) (err error) {
 			pdebug.Printf("Checking MinLength (%d)", sc.minLength)
 		}
 		if ls < sc.minLength {
-			return errors.New("string shorter than minLength " + strconv.Itoa(sc.minLength))
+			if l.add(newValidationError("minLength", sc.minLength, str, "string shorter than minLength "+strconv.Itoa(sc.minLength))) {
+				return
+			}
 		}
 	}
 
-	switch sc.format {
-	case "datetime":
-		if _, err = time.Parse(time.RFC3339, str); err != nil {
-			return errors.New("invalid datetime")
-		}
-	case "email":
-		if _, err = mail.ParseAddress(str); err != nil {
-			return errors.New("invalid email address: " + err.Error())
-		}
-	case "hostname":
-		if !isDomainName(str) {
-			return errors.New("invalid hostname")
-		}
-	case "ipv4":
-		// Should only contain numbers and "."
-		for _, r := range str {
-			switch {
-			case r == 0x2E || 0x30 <= r && r <= 0x39:
-			default:
-				return errors.New("invalid IPv4 address")
-			}
-		}
-		if addr := net.ParseIP(str); addr == nil {
-			return errors.New("invalid IPv4 address")
-		}
-	case "ipv6":
-		// Should only contain numbers and ":"
-		for _, r := range str {
-			switch {
-			case r == 0x3A || 0x30 <= r && r <= 0x39:
-			default:
-				return errors.New("invalid IPv6 address")
-			}
-		}
-		if addr := net.ParseIP(str); addr == nil {
-			return errors.New("invalid IPv6 address")
-		}
-	case "uri":
-		if _, err = url.Parse(str); err != nil {
-			return errors.New("invalid URI")
-		}
+	if l.add(sc.validateFormat(str)) {
+		return
 	}
 
 	if rx := sc.regexp; rx != nil {
@@ -118,66 +81,29 @@ func (sc *StringConstraint) Validate(v interface{}) (err error) {
 			pdebug.Printf("Checking Regexp (rs: %s, target: %s)", rx.String(), str)
 		}
 		if !rx.MatchString(str) {
-			return errors.New("string '" + str + "' does not match regular expression '" + rx.String() + "'")
+			if l.add(newValidationError("pattern", rx.String(), str, "string '"+str+"' does not match regular expression '"+rx.String()+"'")) {
+				return
+			}
 		}
 	}
 
 	if enum := sc.enums; enum != nil {
-		if err := enum.Validate(str); err != nil {
-			return err
+		if l.check(enum, str) {
+			return
 		}
 	}
-
-	return nil
 }
 
-// stolen from src/net/dnsclient.go
-func isDomainName(s string) bool {
-	// See RFC 1035, RFC 3696.
-	if len(s) == 0 {
-		return false
-	}
-	if len(s) > 255 {
-		return false
+func (sc *StringConstraint) validateFormat(str string) error {
+	if sc.format == "" {
+		return nil
 	}
 
-	last := byte('.')
-	ok := false // Ok once we've seen a letter.
-	partlen := 0
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		switch {
-		default:
-			return false
-		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_':
-			ok = true
-			partlen++
-		case '0' <= c && c <= '9':
-			// fine
-			partlen++
-		case c == '-':
-			// Byte before dash cannot be dot.
-			if last == '.' {
-				return false
-			}
-			partlen++
-		case c == '.':
-			// Byte before dot cannot be dot, dash.
-			if last == '.' || last == '-' {
-				return false
-			}
-			if partlen > 63 || partlen == 0 {
-				return false
-			}
-			partlen = 0
-		}
-		last = c
-	}
-	if last == '-' || partlen > 63 {
-		return false
+	r := sc.formats
+	if r == nil {
+		r = DefaultFormatRegistry
 	}
-
-	return ok
+	return r.check(sc.format, str)
 }
 
 // Enum specifies the enumeration of the possible values
@@ -220,6 +146,13 @@ func (sc *StringConstraint) Format(f string) *StringConstraint {
 	return sc
 }
 
+// Formats specifies the FormatRegistry to look up the format in.
+// If unspecified, DefaultFormatRegistry is used.
+func (sc *StringConstraint) Formats(r *FormatRegistry) *StringConstraint {
+	sc.formats = r
+	return sc
+}
+
 // String creates a new StringConstraint. It unfortunately overlaps
 // the `Stringer` interface :/
 func String() *StringConstraint {
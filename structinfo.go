@@ -1,7 +1,9 @@
 package jsval
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/lestrrat-go/pdebug"
@@ -12,23 +14,125 @@ type PropInfo struct {
 	FieldName string
 	// IsMaybe is true if this property implements the Maybe interface
 	IsMaybe bool
+	// FieldIndex is the index sequence of the field, as accepted by
+	// reflect.Value.FieldByIndex. Fields of embedded structs have
+	// more than one index.
+	FieldIndex []int
+	// OmitEmpty is true if the property is not present when the field
+	// holds an empty value (the `omitempty` option)
+	OmitEmpty bool
+	// AsString is true if the value of the field is encoded as a
+	// JSON string (the `string` option)
+	AsString bool
 }
 
 type StructInfo struct {
-	lock  sync.RWMutex
-	props map[string]PropInfo
+	lock            sync.RWMutex
+	props           map[string]PropInfo
+	caseInsensitive bool
 }
 
+// StructInfoRegistry holds the mapping between property names and
+// struct fields for each struct type that has been validated. The
+// property names are read from struct tags, `json` by default.
 type StructInfoRegistry struct {
-	lock     sync.RWMutex
-	registry map[reflect.Type]StructInfo
+	lock            sync.RWMutex
+	registry        map[reflect.Type]*StructInfo
+	tags            []string
+	caseInsensitive bool
 }
 
-func (si *StructInfo) FieldName(pname string) (string, bool) {
+// DefaultStructInfoRegistry is the registry used by ObjectConstraint
+// objects that were not given their own registry via `StructInfo`.
+// It reads property names from `json` struct tags.
+var DefaultStructInfoRegistry = NewStructInfoRegistry()
+
+// DefaultFieldNameFromName returns the name of the struct field that
+// holds the property pname, according to DefaultStructInfoRegistry.
+// It returns an empty string if there is no such field.
+func DefaultFieldNameFromName(rv reflect.Value, pname string) string {
+	return DefaultStructInfoRegistry.FieldNameFromName(rv, pname)
+}
+
+// DefaultFieldNamesFromStruct returns the names of the properties that
+// are present in the struct, according to DefaultStructInfoRegistry
+func DefaultFieldNamesFromStruct(rv reflect.Value) []string {
+	return DefaultStructInfoRegistry.FieldNamesFromStruct(rv)
+}
+
+// NewStructInfoRegistry creates a new StructInfoRegistry that reads
+// property names from `json` struct tags
+func NewStructInfoRegistry() *StructInfoRegistry {
+	return &StructInfoRegistry{
+		registry: make(map[reflect.Type]*StructInfo),
+		tags:     []string{"json"},
+	}
+}
+
+// SetTags specifies the struct tags that property names are read
+// from, such as "yaml", "bson" or "msgpack". If a field has more than
+// one of the tags, the one that comes first is used. Fields that have
+// none of the tags are named after the field itself.
+func (r *StructInfoRegistry) SetTags(tags ...string) *StructInfoRegistry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tags = append([]string(nil), tags...)
+	r.registry = make(map[reflect.Type]*StructInfo)
+	return r
+}
+
+// SetCaseInsensitive specifies if property names that do not exactly
+// match any field should be matched case-insensitively, the same way
+// encoding/json does when it decodes a struct.
+func (r *StructInfoRegistry) SetCaseInsensitive(b bool) *StructInfoRegistry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.caseInsensitive = b
+	r.registry = make(map[reflect.Type]*StructInfo)
+	return r
+}
+
+// FieldNameFromName returns the name of the struct field that holds
+// the property pname. It returns an empty string if there is no such
+// field. It can be used as ObjectConstraint.FieldNameFromName.
+func (r *StructInfoRegistry) FieldNameFromName(rv reflect.Value, pname string) string {
+	si := r.lookupOrRegister(rv.Type())
+	fn, _ := si.FieldName(pname)
+	return fn
+}
+
+// FieldNamesFromStruct returns the names of the properties that are
+// present in the struct. It can be used as
+// ObjectConstraint.FieldNamesFromStruct.
+func (r *StructInfoRegistry) FieldNamesFromStruct(rv reflect.Value) []string {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	si := r.lookupOrRegister(rv.Type())
+	return si.PropNames(rv)
+}
+
+func (si *StructInfo) lookup(pname string) (PropInfo, bool) {
 	si.lock.RLock()
 	defer si.lock.RUnlock()
 
 	pinfo, ok := si.props[pname]
+	if ok || !si.caseInsensitive {
+		return pinfo, ok
+	}
+
+	for name, pinfo := range si.props {
+		if strings.EqualFold(name, pname) {
+			return pinfo, true
+		}
+	}
+	return PropInfo{}, false
+}
+
+func (si *StructInfo) FieldName(pname string) (string, bool) {
+	pinfo, ok := si.lookup(pname)
 	if !ok {
 		return "", false
 	}
@@ -44,8 +148,13 @@ func (si *StructInfo) PropNames(rv reflect.Value) []string {
 
 	pnames := make([]string, 0, len(si.props))
 	for pname, pinfo := range si.props {
+		fv := fieldByIndex(rv, pinfo.FieldIndex)
+		if fv == zeroval {
+			// The field is in an embedded struct pointer that is nil
+			continue
+		}
+
 		if pinfo.IsMaybe {
-			fv := rv.FieldByName(pinfo.FieldName)
 			mv := fv.MethodByName("Valid")
 			out := mv.Call(nil)
 			if !out[0].Bool() {
@@ -53,12 +162,55 @@ func (si *StructInfo) PropNames(rv reflect.Value) []string {
 			}
 		}
 
+		if pinfo.OmitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
 		pnames = append(pnames, pname)
 	}
 	return pnames
 }
 
-func (r *StructInfoRegistry) Lookup(t reflect.Type) (StructInfo, bool) {
+// Field returns the field that holds the property pname, regardless
+// of its value. It returns the zero reflect.Value if there is no such
+// field.
+func (si *StructInfo) Field(rv reflect.Value, pname string) reflect.Value {
+	pinfo, ok := si.lookup(pname)
+	if !ok {
+		return zeroval
+	}
+	return fieldByIndex(rv, pinfo.FieldIndex)
+}
+
+// Value returns the value of the property pname, as it would be
+// encoded to JSON. It returns the zero reflect.Value if the property
+// is not present.
+func (si *StructInfo) Value(rv reflect.Value, pname string) reflect.Value {
+	pinfo, ok := si.lookup(pname)
+	if !ok {
+		return zeroval
+	}
+
+	fv := fieldByIndex(rv, pinfo.FieldIndex)
+	if fv == zeroval {
+		return zeroval
+	}
+
+	if pinfo.OmitEmpty && isEmptyValue(fv) {
+		return zeroval
+	}
+
+	if pinfo.AsString {
+		buf, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return zeroval
+		}
+		return reflect.ValueOf(string(buf))
+	}
+	return fv
+}
+
+func (r *StructInfoRegistry) Lookup(t reflect.Type) (*StructInfo, bool) {
 	switch t.Kind() {
 	case reflect.Ptr, reflect.Interface:
 		t = t.Elem()
@@ -71,7 +223,7 @@ func (r *StructInfoRegistry) Lookup(t reflect.Type) (StructInfo, bool) {
 	return si, ok
 }
 
-func (r *StructInfoRegistry) Register(t reflect.Type) StructInfo {
+func (r *StructInfoRegistry) Register(t reflect.Type) *StructInfo {
 	if pdebug.Enabled {
 		g := pdebug.Marker("StructInfoRegistry.Register (%s)", t.Name())
 		defer g.End()
@@ -84,13 +236,15 @@ func (r *StructInfoRegistry) Register(t reflect.Type) StructInfo {
 
 	r.lock.RLock()
 	si, ok := r.registry[t]
+	tags := r.tags
+	caseInsensitive := r.caseInsensitive
 	r.lock.RUnlock()
 
 	if ok {
 		return si
 	}
 
-	props := extract(t)
+	props := extract(t, tags)
 	if pdebug.Enabled {
 		pdebug.Printf("Extracted struct with the following properties:")
 		for pname, pinfo := range props {
@@ -102,35 +256,106 @@ func (r *StructInfoRegistry) Register(t reflect.Type) StructInfo {
 		}
 	}
 
-	si = StructInfo{props: props}
+	si = &StructInfo{props: props, caseInsensitive: caseInsensitive}
 	r.lock.Lock()
 	r.registry[t] = si
 	r.lock.Unlock()
 	return si
 }
 
+func (r *StructInfoRegistry) lookupOrRegister(t reflect.Type) *StructInfo {
+	if si, ok := r.Lookup(t); ok {
+		return si
+	}
+	return r.Register(t)
+}
+
 var maybeif = reflect.TypeOf((*Maybe)(nil)).Elem()
 
-func extract(t reflect.Type) map[string]PropInfo {
+// propCandidate is a field that may be the one that holds a property.
+// As with encoding/json, if more than one field is named the same,
+// the least nested one wins, and tagged fields win over untagged ones.
+type propCandidate struct {
+	info   PropInfo
+	depth  int
+	tagged bool
+}
+
+func extract(t reflect.Type, tags []string) map[string]PropInfo {
+	candidates := make(map[string][]propCandidate)
+	extractFields(t, tags, nil, map[reflect.Type]struct{}{}, candidates)
+
 	props := make(map[string]PropInfo)
+	for pname, l := range candidates {
+		if c, ok := dominantCandidate(l); ok {
+			props[pname] = c.info
+		}
+	}
+	return props
+}
+
+func dominantCandidate(l []propCandidate) (propCandidate, bool) {
+	var best []propCandidate
+	for _, c := range l {
+		switch {
+		case len(best) == 0 || c.depth < best[0].depth:
+			best = []propCandidate{c}
+		case c.depth == best[0].depth:
+			best = append(best, c)
+		}
+	}
+
+	if len(best) == 1 {
+		return best[0], true
+	}
+
+	var tagged []propCandidate
+	for _, c := range best {
+		if c.tagged {
+			tagged = append(tagged, c)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	// Ambiguous. encoding/json ignores these fields, and so do we
+	return propCandidate{}, false
+}
+
+func extractFields(t reflect.Type, tags []string, index []int, visited map[reflect.Type]struct{}, candidates map[string][]propCandidate) {
+	if _, ok := visited[t]; ok {
+		return
+	}
+	visited[t] = struct{}{}
+	defer delete(visited, t)
+
 	for i := 0; i < t.NumField(); i++ {
 		fv := t.Field(i)
-		if fv.Anonymous {
-			info := extract(fv.Type)
-			for k, v := range info {
-				props[k] = v
-			}
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		// Inspect the struct tag so we know what they want to call
+		// this field from JSON.
+		tag := lookupTag(fv.Tag, tags)
+		if tag == "-" { // "Ignore me", says the struct
 			continue
 		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i > -1 {
+			name, opts = tag[:i], tag[i+1:]
+		}
 
-		if fv.PkgPath != "" { // not exported
-			continue
+		if fv.Anonymous && name == "" {
+			ft := fv.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				extractFields(ft, tags, fieldIndex, visited, candidates)
+				continue
+			}
 		}
 
-		// Inspect the JSON struct tag so we know what they want to call
-		// this field from JSON.
-		tag := fv.Tag.Get("json")
-		if tag == "-" { // "Ignore me", says the struct
+		if fv.PkgPath != "" { // not exported
 			continue
 		}
 
@@ -141,25 +366,93 @@ func extract(t reflect.Type) map[string]PropInfo {
 			pdebug.Printf("Checking if field '%s' implements the Maybe interface -> %t", fv.Name, isMaybe)
 		}
 
-		if tag == "" || tag[0] == ',' {
-			props[fv.Name] = PropInfo{
-				FieldName: fv.Name,
-				IsMaybe:   isMaybe,
-			}
-			continue
+		tagged := name != ""
+		if !tagged {
+			name = fv.Name
 		}
 
-		flen := 0
-		for j := 0; j < len(tag); j++ {
-			if tag[j] == ',' {
-				break
+		info := PropInfo{
+			FieldName:  fv.Name,
+			IsMaybe:    isMaybe,
+			FieldIndex: fieldIndex,
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				info.OmitEmpty = true
+			case "string":
+				switch fv.Type.Kind() {
+				case reflect.Bool, reflect.String,
+					reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+					reflect.Float32, reflect.Float64:
+					info.AsString = true
+				}
 			}
-			flen = j
 		}
-		props[tag[:flen+1]] = PropInfo{
-			FieldName: fv.Name,
-			IsMaybe:   isMaybe,
+
+		candidates[name] = append(candidates[name], propCandidate{
+			info:   info,
+			depth:  len(fieldIndex),
+			tagged: tagged,
+		})
+	}
+}
+
+// lookupTag returns the value of the first of the tags that is
+// present in the struct tag
+func lookupTag(st reflect.StructTag, tags []string) string {
+	for _, name := range tags {
+		if tag, ok := st.Lookup(name); ok {
+			return tag
+		}
+	}
+	return ""
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, except that it
+// returns the zero reflect.Value instead of panicking when it goes
+// through an embedded struct pointer that is nil
+func fieldByIndex(rv reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return zeroval
+			}
+			rv = rv.Elem()
 		}
+		rv = rv.Field(x)
 	}
-	return props
+	return rv
+}
+
+// fieldByName is like reflect.Value.FieldByName, except that it
+// returns the zero reflect.Value instead of panicking when it goes
+// through an embedded struct pointer that is nil
+func fieldByName(rv reflect.Value, name string) reflect.Value {
+	sf, ok := rv.Type().FieldByName(name)
+	if !ok {
+		return zeroval
+	}
+	return fieldByIndex(rv, sf.Index)
+}
+
+// isEmptyValue reports whether the value is omitted by the
+// `omitempty` option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
 }
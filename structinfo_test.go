@@ -0,0 +1,146 @@
+package jsval_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jsschema"
+	"github.com/lestrrat-go/jsval"
+	"github.com/lestrrat-go/jsval/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+type structInfoTestMeta struct {
+	Owner   string `json:"owner" bson:"owner_id"`
+	Version int    `json:"version,string"`
+}
+
+type structInfoTestRecord struct {
+	*structInfoTestMeta
+	ID    string   `json:"id" bson:"_id"`
+	Name  string   `json:"name,omitempty" bson:"name,omitempty" yaml:"title"`
+	Tags  []string `json:",omitempty"`
+	Notes string   `json:"-" bson:"notes"`
+}
+
+func sortedStrings(l []string) []string {
+	sort.Strings(l)
+	return l
+}
+
+func TestStructInfoRegistry(t *testing.T) {
+	t.Run("json tags", func(t *testing.T) {
+		r := jsval.NewStructInfoRegistry()
+		rec := structInfoTestRecord{ID: "foo"}
+		if !assert.Equal(t, []string{"id"}, sortedStrings(r.FieldNamesFromStruct(reflect.ValueOf(rec))), "empty fields and nil embedded structs are omitted") {
+			return
+		}
+
+		rec = structInfoTestRecord{
+			structInfoTestMeta: &structInfoTestMeta{Owner: "bar"},
+			ID:                 "foo",
+			Name:               "baz",
+			Tags:               []string{"a"},
+		}
+		if !assert.Equal(t, []string{"Tags", "id", "name", "owner", "version"}, sortedStrings(r.FieldNamesFromStruct(reflect.ValueOf(rec))), "property names match") {
+			return
+		}
+		if !assert.Equal(t, "Owner", r.FieldNameFromName(reflect.ValueOf(rec), "owner"), "field name matches") {
+			return
+		}
+		if !assert.Equal(t, "", r.FieldNameFromName(reflect.ValueOf(rec), "Notes"), "ignored field is not found") {
+			return
+		}
+	})
+
+	t.Run("Alternate tags", func(t *testing.T) {
+		r := jsval.NewStructInfoRegistry().SetTags("yaml", "bson")
+		rec := structInfoTestRecord{structInfoTestMeta: &structInfoTestMeta{}}
+		if !assert.Equal(t, []string{"Tags", "Version", "_id", "notes", "owner_id", "title"}, sortedStrings(r.FieldNamesFromStruct(reflect.ValueOf(rec))), "property names match") {
+			return
+		}
+	})
+
+	t.Run("Case insensitive", func(t *testing.T) {
+		r := jsval.NewStructInfoRegistry().SetCaseInsensitive(true)
+		if !assert.Equal(t, "ID", r.FieldNameFromName(reflect.ValueOf(structInfoTestRecord{}), "Id"), "field name matches") {
+			return
+		}
+
+		r = jsval.NewStructInfoRegistry()
+		if !assert.Equal(t, "", r.FieldNameFromName(reflect.ValueOf(structInfoTestRecord{}), "Id"), "field name does not match") {
+			return
+		}
+	})
+}
+
+func TestObjectStructInfo(t *testing.T) {
+	const src = `{
+  "type": "object",
+  "properties": {
+    "_id": { "type": "string", "minLength": 1 },
+    "name": { "type": "string", "default": "anonymous" },
+    "owner_id": { "type": "string" },
+    "version": { "type": "string", "pattern": "^[0-9]+$" }
+  },
+  "required": ["_id", "owner_id"]
+}`
+
+	s, err := schema.Read(strings.NewReader(src))
+	if !assert.NoError(t, err, "reading schema should succeed") {
+		return
+	}
+
+	t.Run("bson tags via the builder", func(t *testing.T) {
+		v, err := builder.New().
+			SetStructInfoRegistry(jsval.NewStructInfoRegistry().SetTags("bson", "json")).
+			Build(s)
+		if !assert.NoError(t, err, "Builder.Build should succeed") {
+			return
+		}
+
+		rec := structInfoTestRecord{
+			structInfoTestMeta: &structInfoTestMeta{Owner: "bar", Version: 2},
+			ID:                 "foo",
+		}
+		if !assert.NoError(t, v.Validate(&rec), "validation passes") {
+			return
+		}
+		if !assert.Equal(t, "anonymous", rec.Name, "default is set") {
+			return
+		}
+
+		if !assert.Error(t, v.Validate(structInfoTestRecord{ID: "foo"}), "owner_id is required") {
+			return
+		}
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		var names []string
+		c := jsval.Object().
+			AddProp(`id`, jsval.String().MinLength(3)).
+			Required(`id`)
+		c.FieldNamesFromStruct = func(rv reflect.Value) []string {
+			return []string{"id"}
+		}
+		c.FieldNameFromName = func(rv reflect.Value, pname string) string {
+			names = append(names, pname)
+			if pname == "id" {
+				return "Name"
+			}
+			return ""
+		}
+
+		if !assert.Error(t, c.Validate(structInfoTestRecord{ID: "foo", Name: "x"}), "validation fails") {
+			return
+		}
+		if !assert.NoError(t, c.Validate(structInfoTestRecord{Name: "foo"}), "validation passes") {
+			return
+		}
+		if !assert.Contains(t, names, "id", "FieldNameFromName is called") {
+			return
+		}
+	})
+}
@@ -0,0 +1,403 @@
+package jsval
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/lestrrat-go/pdebug"
+)
+
+// jsonKind is the JSON type of a Go value
+type jsonKind int
+
+const (
+	jsonInvalid jsonKind = iota
+	jsonNull
+	jsonBoolean
+	jsonNumber
+	jsonString
+	jsonArray
+	jsonObject
+)
+
+func (k jsonKind) String() string {
+	switch k {
+	case jsonNull:
+		return "null"
+	case jsonBoolean:
+		return "boolean"
+	case jsonNumber:
+		return "number"
+	case jsonString:
+		return "string"
+	case jsonArray:
+		return "array"
+	case jsonObject:
+		return "object"
+	}
+	return "invalid"
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	maybeValuerType   = reflect.TypeOf((*maybeValuer)(nil)).Elem()
+)
+
+// maybeValuer is the read-only part of the Maybe interface
+type maybeValuer interface {
+	Valid() bool
+	Value() interface{}
+}
+
+// jsonValueOf is the single place where Go values are mapped to JSON
+// values. Every constraint looks at the value it validates through it,
+// so that a value validates the same way regardless of how it was
+// obtained (decoded from JSON or YAML, or filled in a struct).
+//
+// Pointers and interfaces are dereferenced, Maybe values are replaced
+// by their values (or null, if they are not valid), and values that
+// implement json.Marshaler (including json.RawMessage) are marshaled
+// and decoded again. Values that implement encoding.TextMarshaler are
+// strings. Structs, and maps with keys that encoding/json can handle
+// (strings, integers, encoding.TextMarshaler), as well as interface{}
+// keys such as those produced by gopkg.in/yaml.v2, are objects.
+//
+// The returned value may be different from rv, and should be accessed
+// according to its kind. Objects should be accessed via propNames and
+// propValue, and arrays via Len and Index.
+func jsonValueOf(rv reflect.Value) (reflect.Value, jsonKind) {
+	for {
+		if !rv.IsValid() {
+			return rv, jsonNull
+		}
+
+		switch t := rv.Type(); t {
+		case bigIntType, bigFloatType:
+			if rv.IsNil() {
+				return rv, jsonNull
+			}
+			return rv, jsonNumber
+		case jsonNumberType:
+			return rv, jsonNumber
+		}
+
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if rv.IsNil() {
+				return rv, jsonNull
+			}
+			rv = rv.Elem()
+			continue
+		}
+
+		if !rv.CanInterface() {
+			return rv, jsonInvalid
+		}
+
+		if rv.Type().Implements(maybeValuerType) {
+			mv := rv.Interface().(maybeValuer)
+			if !mv.Valid() {
+				return reflect.Value{}, jsonNull
+			}
+			rv = reflect.ValueOf(mv.Value())
+			continue
+		}
+
+		if m, ok := asMarshaler(rv, jsonMarshalerType); ok {
+			x, err := remarshalJSON(m.(json.Marshaler))
+			if err != nil {
+				if pdebug.Enabled {
+					pdebug.Printf("failed to marshal %s: %s", rv.Type(), err)
+				}
+				return rv, jsonInvalid
+			}
+			rv = reflect.ValueOf(x)
+			continue
+		}
+
+		if rv.Kind() != reflect.String {
+			if m, ok := asMarshaler(rv, textMarshalerType); ok {
+				b, err := m.(encoding.TextMarshaler).MarshalText()
+				if err != nil {
+					return rv, jsonInvalid
+				}
+				return reflect.ValueOf(string(b)), jsonString
+			}
+		}
+
+		switch rv.Kind() {
+		case reflect.Bool:
+			return rv, jsonBoolean
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			return rv, jsonNumber
+		case reflect.String:
+			return rv, jsonString
+		case reflect.Slice:
+			if rv.IsNil() {
+				return rv, jsonNull
+			}
+			return rv, jsonArray
+		case reflect.Array:
+			return rv, jsonArray
+		case reflect.Map:
+			if rv.IsNil() {
+				return rv, jsonNull
+			}
+			if isObjectKeyType(rv.Type().Key()) {
+				return rv, jsonObject
+			}
+		case reflect.Struct:
+			return rv, jsonObject
+		}
+		return rv, jsonInvalid
+	}
+}
+
+// asMarshaler returns the value of rv as an interface of type t, if rv
+// or a pointer to it implements t
+func asMarshaler(rv reflect.Value, t reflect.Type) (interface{}, bool) {
+	if rv.Type().Implements(t) {
+		return rv.Interface(), true
+	}
+	if rv.CanAddr() && reflect.PtrTo(rv.Type()).Implements(t) {
+		return rv.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+func remarshalJSON(m json.Marshaler) (interface{}, error) {
+	buf, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func isObjectKeyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshalerType)
+}
+
+// mapKeyString converts a map key to a property name, the same way
+// encoding/json does
+func mapKeyString(k reflect.Value) (string, error) {
+	for k.Kind() == reflect.Interface {
+		if k.IsNil() {
+			return "", errors.New("cannot use nil as a property name")
+		}
+		k = k.Elem()
+	}
+
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.CanInterface() && k.Type().Implements(textMarshalerType) {
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", errors.New("cannot use map key of type " + k.Type().String() + " as a property name")
+}
+
+// propNames returns the property names of an object, as returned by
+// jsonValueOf
+func propNames(rv reflect.Value) ([]string, error) {
+	switch rv.Kind() {
+	case reflect.Map:
+		vk := rv.MapKeys()
+		keys := make([]string, len(vk))
+		for i, k := range vk {
+			s, err := mapKeyString(k)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = s
+		}
+		return keys, nil
+	case reflect.Struct:
+		return structPropNames(rv, nil, nil), nil
+	}
+	return nil, errors.New("cannot get property names from this value (Kind: " + rv.Kind().String() + ")")
+}
+
+// structPropNames returns the property names of a struct. Unless the
+// struct knows its property names by itself, they are obtained from
+// hook, or if hook is nil, from r. If r is nil,
+// DefaultStructInfoRegistry is used.
+func structPropNames(rv reflect.Value, r *StructInfoRegistry, hook func(reflect.Value) []string) []string {
+	if gpv, ok := addrInterface(rv).(GetPropNameser); ok {
+		pv, err := gpv.GetPropNames()
+		if err == nil {
+			return pv
+		}
+	}
+
+	if hook != nil {
+		return hook(rv)
+	}
+
+	if r == nil {
+		r = DefaultStructInfoRegistry
+	}
+	return r.FieldNamesFromStruct(rv)
+}
+
+// propValue returns the value of the property pname of an object, as
+// returned by jsonValueOf. It returns the zero reflect.Value if there
+// is no such property.
+func propValue(rv reflect.Value, pname string) reflect.Value {
+	switch rv.Kind() {
+	case reflect.Map:
+		kt := rv.Type().Key()
+		if kt.Kind() == reflect.String {
+			return rv.MapIndex(reflect.ValueOf(pname).Convert(kt))
+		}
+		for _, k := range rv.MapKeys() {
+			if s, err := mapKeyString(k); err == nil && s == pname {
+				return rv.MapIndex(k)
+			}
+		}
+		return zeroval
+	case reflect.Struct:
+		return structPropValue(rv, nil, nil, pname)
+	}
+	return zeroval
+}
+
+// structPropValue returns the value of the property pname of a struct.
+// Unless the struct knows how to get the value by itself, the field is
+// looked up using hook, or if hook is nil, using r. If r is nil,
+// DefaultStructInfoRegistry is used.
+func structPropValue(rv reflect.Value, r *StructInfoRegistry, hook func(reflect.Value, string) string, pname string) reflect.Value {
+	// This guy knows how to grab the value, given a name. Use that
+	if gpv, ok := addrInterface(rv).(GetPropValuer); ok {
+		pv, err := gpv.GetPropValue(pname)
+		if err == nil {
+			return reflect.ValueOf(pv)
+		}
+	}
+
+	if hook != nil {
+		fn := hook(rv, pname)
+		if fn == "" {
+			return zeroval
+		}
+		return fieldByName(rv, fn)
+	}
+
+	if r == nil {
+		r = DefaultStructInfoRegistry
+	}
+	si := r.lookupOrRegister(rv.Type())
+	fv := si.Value(rv, pname)
+	if fv == zeroval && pdebug.Enabled {
+		pdebug.Printf("Could not resolve name '%s'", pname)
+	}
+	return fv
+}
+
+// addrInterface returns a pointer to the value held by rv if it is
+// addressable, so that methods with pointer receivers can be found,
+// and the value itself otherwise
+func addrInterface(rv reflect.Value) interface{} {
+	if rv.CanAddr() {
+		return rv.Addr().Interface()
+	}
+	return rv.Interface()
+}
+
+// mapKeyFor returns the key under which the property pname should be
+// stored in the map rv
+func mapKeyFor(rv reflect.Value, pname string) reflect.Value {
+	kt := rv.Type().Key()
+	if kt.Kind() == reflect.String {
+		return reflect.ValueOf(pname).Convert(kt)
+	}
+	for _, k := range rv.MapKeys() {
+		if s, err := mapKeyString(k); err == nil && s == pname {
+			return k
+		}
+	}
+	return reflect.ValueOf(pname)
+}
+
+// JSONValue converts v to the kind of value that encoding/json decodes
+// JSON into: nil, bool, float64, string, []interface{} and
+// map[string]interface{}, so that it can be inspected without
+// reflection. Numbers that are not float64 (e.g. int64 or json.Number)
+// are returned as is. Only the top level value is converted: the
+// elements of arrays and objects are left untouched. Maybe values that
+// are not valid are left out of objects. Values that are not JSON
+// values (e.g. channels) are returned as is.
+//
+// This is used by the code generated by the Compiler, to validate
+// values other than the ones decoded from JSON.
+func JSONValue(v interface{}) interface{} {
+	rv, kind := jsonValueOf(reflect.ValueOf(v))
+	switch kind {
+	case jsonNull:
+		return nil
+	case jsonBoolean:
+		return rv.Bool()
+	case jsonString:
+		return rv.String()
+	case jsonNumber:
+		if rv.Kind() == reflect.Float64 {
+			return rv.Float()
+		}
+		return rv.Interface()
+	case jsonArray:
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = rv.Index(i).Interface()
+		}
+		return l
+	case jsonObject:
+		pnames, err := propNames(rv)
+		if err != nil {
+			return v
+		}
+		m := make(map[string]interface{}, len(pnames))
+		for _, pname := range pnames {
+			pv := propValue(rv, pname)
+			if !pv.IsValid() || !pv.CanInterface() {
+				continue
+			}
+			x := pv.Interface()
+			if mv, ok := x.(maybeValuer); ok && !mv.Valid() {
+				continue
+			}
+			m[pname] = x
+		}
+		return m
+	}
+	return v
+}
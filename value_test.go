@@ -0,0 +1,170 @@
+package jsval_test
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jsval"
+	"github.com/stretchr/testify/assert"
+)
+
+type valueTestRecord struct {
+	Name  string         `json:"name"`
+	Count jsval.MaybeInt `json:"count"`
+	Tags  *[]string      `json:"tags"`
+}
+
+// ipKey is a map key that implements encoding.TextMarshaler
+type ipKey struct {
+	ip [4]byte
+}
+
+func (k ipKey) MarshalText() ([]byte, error) {
+	return []byte(net.IP(k.ip[:]).String()), nil
+}
+
+// upper marshals itself as an upper case JSON string
+type upper string
+
+func (u upper) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToUpper(string(u)))
+}
+
+func TestValueAdapter(t *testing.T) {
+	record := jsval.New().SetRoot(jsval.Object().
+		AddProp(`name`, jsval.String().MinLength(1)).
+		AddProp(`count`, jsval.Integer().Minimum(0)).
+		AddProp(`tags`, jsval.Array().Items(jsval.String()).MaxItems(2)).
+		Required(`name`, `count`),
+	)
+
+	t.Run("Same result for struct, map and YAML values", func(t *testing.T) {
+		tags := []string{"a", "b", "c"}
+		values := []interface{}{
+			valueTestRecord{Name: "foo", Count: jsval.MaybeInt{ValidFlag: true, Int: -1}, Tags: &tags},
+			&valueTestRecord{Name: "foo", Count: jsval.MaybeInt{ValidFlag: true, Int: -1}, Tags: &tags},
+			map[string]interface{}{"name": "foo", "count": -1, "tags": tags},
+			map[interface{}]interface{}{"name": "foo", "count": -1, "tags": []interface{}{"a", "b", "c"}},
+			json.RawMessage(`{"name": "foo", "count": -1, "tags": ["a", "b", "c"]}`),
+		}
+		for _, v := range values {
+			if !assert.Len(t, record.ValidateAll(v), 2, "minimum and maxItems fail for %#v", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("Missing Maybe field", func(t *testing.T) {
+		tags := []string{}
+		for _, v := range []interface{}{valueTestRecord{Name: "foo", Tags: &tags}, map[interface{}]interface{}{"name": "foo"}} {
+			if !assert.Error(t, record.Validate(v), "count is required in %#v", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("Pointers, arrays and Maybe values", func(t *testing.T) {
+		b := true
+		s := "foo"
+		arr := [2]int{1, 2}
+		slice := []int{1, 2}
+		valid := []struct {
+			c jsval.Constraint
+			v interface{}
+		}{
+			{jsval.Boolean(), &b},
+			{jsval.Boolean(), jsval.MaybeBool{ValidFlag: true, Bool: true}},
+			{jsval.String(), &s},
+			{jsval.String(), jsval.MaybeString{ValidFlag: true, String: "foo"}},
+			{jsval.String(), upper("foo")},
+			{jsval.String().Enum("FOO"), upper("foo")},
+			{jsval.String(), ipKey{ip: [4]byte{127, 0, 0, 1}}},
+			{jsval.String(), time.Unix(0, 0)},
+			{jsval.Array().MaxItems(2), arr},
+			{jsval.Array().MaxItems(2), &slice},
+			{jsval.Array().Items(jsval.Integer()), json.RawMessage(`[1, 2]`)},
+			{jsval.NullConstraint, (*int)(nil)},
+			{jsval.NullConstraint, jsval.MaybeInt{}},
+			{jsval.NullConstraint, json.RawMessage(`null`)},
+		}
+		for _, data := range valid {
+			if !assert.NoError(t, data.c.Validate(data.v), "%#v should be valid", data.v) {
+				return
+			}
+		}
+
+		invalid := []struct {
+			c jsval.Constraint
+			v interface{}
+		}{
+			{jsval.Boolean(), &s},
+			{jsval.String(), jsval.MaybeString{}},
+			{jsval.Array().MaxItems(1), arr},
+			{jsval.Array(), json.RawMessage(`{}`)},
+			{jsval.Object(), json.RawMessage(`[`)},
+			{jsval.NullConstraint, jsval.MaybeInt{ValidFlag: true}},
+		}
+		for _, data := range invalid {
+			if !assert.Error(t, data.c.Validate(data.v), "%#v should be invalid", data.v) {
+				return
+			}
+		}
+	})
+
+	t.Run("Map keys", func(t *testing.T) {
+		c := jsval.Object().
+			PropertyNames(jsval.String().RegexpString(`^[0-9.]+$`)).
+			AddProp(`127.0.0.1`, jsval.String().Enum(`localhost`)).
+			AdditionalProperties(jsval.EmptyConstraint)
+
+		valid := []interface{}{
+			map[ipKey]string{{ip: [4]byte{127, 0, 0, 1}}: "localhost"},
+			map[int]string{1: "one"},
+			map[interface{}]interface{}{1: "one", "127.0.0.1": "localhost"},
+		}
+		for _, v := range valid {
+			if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+				return
+			}
+		}
+
+		invalid := []interface{}{
+			map[ipKey]string{{ip: [4]byte{127, 0, 0, 1}}: "example.com"},
+			map[interface{}]interface{}{"foo": 1},
+			map[interface{}]interface{}{1.5: 1},
+		}
+		for _, v := range invalid {
+			if !assert.Error(t, c.Validate(v), "%#v should be invalid", v) {
+				return
+			}
+		}
+	})
+
+	t.Run("Defaults in YAML values", func(t *testing.T) {
+		c := jsval.Object().AddProp(`name`, jsval.String().Default(`foo`))
+		m := map[interface{}]interface{}{}
+		if !assert.NoError(t, c.Validate(m), "Validate should succeed") {
+			return
+		}
+		if !assert.Equal(t, "foo", m["name"], "default is set") {
+			return
+		}
+	})
+
+	t.Run("Equality", func(t *testing.T) {
+		c := jsval.Enum(map[string]interface{}{"a": []interface{}{1, "x"}})
+		valid := []interface{}{
+			map[interface{}]interface{}{"a": []interface{}{1.0, "x"}},
+			json.RawMessage(`{"a": [1, "x"]}`),
+			&map[string][2]interface{}{"a": {json.Number("1"), "x"}},
+		}
+		for _, v := range valid {
+			if !assert.NoError(t, c.Validate(v), "%#v should be valid", v) {
+				return
+			}
+		}
+	})
+}